@@ -1,5 +1,6 @@
 import { HttpStatus, httpStatusTextByCode } from "http-status-ts";
 import { TSMap } from "typescript-map";
+import { IToken } from "@/store/models/token";
 
 export const ProtoHTTP = "http";
 export const ProtoWS = "ws";
@@ -28,3 +29,10 @@ export function successResponse(resp: IResponse): boolean {
 export function unauthResponse(resp: IResponse): boolean {
   return resp.status_code == HttpStatus.UNAUTHORIZED;
 }
+
+export function authHeader(token: IToken): TSMap<string, string | number> {
+  return new TSMap<string, string | number>([
+    ["Content-Type", "application/json"],
+    ["Authorization", "Bearer " + token.id],
+  ]);
+}
@@ -1,9 +1,10 @@
 import WSSocket from "../socket/wssocket";
-import { IRequest, IResponse } from "./reqresp";
+import { IRequest, IResponse, authHeader } from "./reqresp";
 import { TSMap } from "typescript-map";
 import { HttpStatus, httpStatusTextByCode } from "http-status-ts";
 import { chattingEndpoint } from "@/store/endpoints/endpoints";
 import { IToken } from "@/store/models/token";
+import { maxEventVersion } from "@/store/models/event";
 
 const sleep = (ms: number) => new Promise((r) => setTimeout(r, ms));
 
@@ -11,6 +12,7 @@ export async function Connect(
   socket: WSSocket,
   token: IToken,
   timeout: number,
+  lastSeenSeq: number | undefined,
 
   onConnect: () => void,
   onData: (data: Buffer) => void,
@@ -29,10 +31,13 @@ export async function Connect(
   const request: IRequest = {
     method: "ws",
     proto: "ws",
-    header: new TSMap<string, string>([["Content-Type", "application/json"]]),
+    header: authHeader(token),
 
     url: chattingEndpoint,
-    body: JSON.stringify(token),
+    body: JSON.stringify({
+      last_seen_seq: lastSeenSeq,
+      max_event_version: maxEventVersion,
+    }),
   };
 
   socket.setOnConnect(() => {
@@ -16,7 +16,7 @@ export const chattingEndpoint = "/api/v1/chatting";
 
 export const messagesEndpoint = "/api/v1/messages";
 export interface IMessagesRequest {
-  auth_token: IToken;
+  conversation_id: number;
   timestamp: string;
   limit: number;
 }
@@ -24,4 +24,51 @@ export interface IMessagesResponse {
   messages: Array<IMessage>;
 }
 
+// threadRepliesEndpoint returns url of replies of the thread of the message
+export const threadRepliesEndpoint = (id: string) =>
+  `/api/v1/messages/${id}/replies`;
+export interface IThreadRepliesRequest {
+  timestamp?: string;
+  limit: number;
+}
+// response_body: IMessagesResponse
+
 export const memberEndpoint = "/api/v1/member";
+
+export const conversationsEndpoint = "/api/v1/conversations";
+// response_body: Array<IConversation>
+export interface ICreateGroupRequest {
+  title: string;
+  color?: string;
+  member_ids: Array<number>;
+}
+// response_body: IConversation
+
+export const directConversationEndpoint = "/api/v1/conversations/direct";
+export interface IDirectConversationRequest {
+  user_id: number;
+}
+// response_body: IConversation
+
+export const conversationInvitesEndpoint = "/api/v1/conversations/invites";
+// response_body: Array<IConversationInvite>
+
+// conversationMembersEndpoint returns url of members of the conversation,
+// GET returns ids of members, POST adds members, DELETE with user id removes member
+export const conversationMembersEndpoint = (id: number) =>
+  `/api/v1/conversations/${id}/members`;
+export interface IAddMembersRequest {
+  user_ids: Array<number>;
+}
+
+export const leaveConversationEndpoint = (id: number) =>
+  `/api/v1/conversations/${id}/leave`;
+
+export const inviteToConversationEndpoint = (id: number) =>
+  `/api/v1/conversations/${id}/invites`;
+export interface IInviteRequest {
+  user_id: number;
+}
+
+export const acceptInviteEndpoint = (id: number) =>
+  `/api/v1/conversations/${id}/invites/accept`;
@@ -53,6 +53,64 @@ const store: Store<IState> = createStore({
     unshiftMessages(state, payload) {
       state.messages?.unshift(...payload);
     },
+    // editMessage replaces text of the message in place, deleted message
+    // is kept as tombstone without reactions
+    editMessage(state, payload) {
+      const msg = state.messages?.find((m: IMessage) => m.id === payload.id);
+      if (!msg) {
+        return;
+      }
+      if (payload.deleted_at) {
+        msg.message = "";
+        msg.reactions = undefined;
+        msg.deleted_at = payload.deleted_at;
+        msg.updated_at = payload.deleted_at;
+      } else {
+        msg.message = payload.message;
+        msg.updated_at = payload.updated_at;
+      }
+    },
+    // countReply counts new reply of the thread root if it is loaded
+    countReply(state, payload) {
+      const root = state.messages?.find(
+        (m: IMessage) => m.id === payload.thread_id
+      );
+      if (!root) {
+        return;
+      }
+      root.reply_count = (root.reply_count ?? 0) + 1;
+      root.last_reply_at = payload.created_at;
+      root.new_replies = (root.new_replies ?? 0) + 1;
+    },
+    // react adds or removes reaction of the user on the loaded message
+    react(state, { added, reaction }) {
+      const msg = state.messages?.find(
+        (m: IMessage) => m.id === reaction.message_id
+      );
+      if (!msg) {
+        return;
+      }
+      msg.reactions = msg.reactions ?? [];
+      let summary = msg.reactions.find((r) => r.emoji === reaction.emoji);
+      if (!summary) {
+        if (!added) {
+          return;
+        }
+        summary = { emoji: reaction.emoji, count: 0, user_ids: [] };
+        msg.reactions.push(summary);
+      }
+      const idx = summary.user_ids.indexOf(reaction.user_id);
+      if (added && idx < 0) {
+        summary.user_ids.push(reaction.user_id);
+      } else if (!added && idx >= 0) {
+        summary.user_ids.splice(idx, 1);
+      }
+      summary.count = summary.user_ids.length;
+      msg.reactions = msg.reactions.filter((r) => r.count > 0);
+    },
+    clearMessages(state) {
+      state.messages?.splice(0);
+    },
     updateMessages(state, payload) {
       state.messages = payload.messages;
     },
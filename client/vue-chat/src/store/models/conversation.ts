@@ -8,3 +8,22 @@ export interface IConversation {
 
 export const ConversationP2PKind = 0;
 export const ConversationGroupKind = 1;
+
+// CommonConversationID is id of the conversation of all users
+export const CommonConversationID = 0;
+
+export interface IConversationInvite {
+  conversation_id: number;
+  user_id: number;
+  inviter_id: number;
+  created_at: string;
+}
+
+// IConversationMemberEvent is payload of the ConversationMemberEvent,
+// action is one of "added", "removed", "left", "invited", "joined"
+export interface IConversationMemberEvent {
+  conversation_id: number;
+  action: string;
+  actor_id: number;
+  user_ids: Array<number>;
+}
@@ -1,4 +1,9 @@
+// maxEventVersion is the highest version of events that client understands
+export const maxEventVersion = 1;
+
 export interface IEvent {
+  seq?: number;
   type: string;
+  version?: number;
   payload: any;
 }
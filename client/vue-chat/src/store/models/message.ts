@@ -1,12 +1,41 @@
 export interface IMessage {
   id: string;
+  client_id?: string;
+  conversation_id: number;
   sender_id: number;
   message_kind: number;
   message: string;
   created_at: string;
   updated_at: string;
+  deleted_at?: string;
+  reply_to?: string;
+  thread_id?: string;
+  reply_count: number;
+  last_reply_at?: string;
+  // new_replies is count of replies received since the thread is loaded
+  new_replies?: number;
+  reactions?: Array<IReactionSummary>;
+}
+
+// IReactionSummary is reactions with the same emoji on the message
+export interface IReactionSummary {
+  emoji: string;
+  count: number;
+  user_ids: Array<number>;
+}
+
+// IMessageReactionEvent is payload of ReactionAddedEvent and ReactionRemovedEvent
+export interface IMessageReactionEvent {
+  message_id: string;
+  client_id?: string;
+  conversation_id: number;
+  user_id: number;
+  emoji: string;
 }
 
 export const CreateConversationMessage = 0;
 export const AddingUserMessage = 1;
 export const UserTextMessage = 2;
+export const RemovingUserMessage = 3;
+export const LeavingUserMessage = 4;
+export const InvitingUserMessage = 5;
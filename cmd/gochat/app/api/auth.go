@@ -3,7 +3,6 @@ package api
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
@@ -26,8 +25,7 @@ func (api *Api) SignUp(resp *tcpws.Response, req *tcpws.Request) {
 	// unmurshal auth data
 	err := json.Unmarshal([]byte(req.Body), &authUser)
 	if err != nil {
-		resp.StatusCode = http.StatusBadRequest
-		resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+		api.badRequest(resp, err)
 		return
 	}
 
@@ -36,11 +34,9 @@ func (api *Api) SignUp(resp *tcpws.Response, req *tcpws.Request) {
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrUserLoginAlreadyExists):
-			resp.StatusCode = http.StatusBadRequest
-			resp.Status = service.ErrUserLoginAlreadyExists.Error()
+			resp.Error(http.StatusBadRequest, ErrCodeUserLoginExists, err.Error(), nil)
 		default:
-			resp.StatusCode = http.StatusInternalServerError
-			resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+			api.internalError(resp, req, op, err)
 		}
 		return
 	}
@@ -48,15 +44,13 @@ func (api *Api) SignUp(resp *tcpws.Response, req *tcpws.Request) {
 	// prepare user
 	user, err := api.app.AuthService.SignUp(req.Ctx(), &authUser)
 	if err != nil {
-		resp.StatusCode = http.StatusInternalServerError
-		resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+		api.internalError(resp, req, op, err)
 		return
 	}
 
 	_, err = api.app.UserService.Create(req.Ctx(), user)
 	if err != nil {
-		resp.StatusCode = http.StatusInternalServerError
-		resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+		api.internalError(resp, req, op, err)
 		return
 	}
 
@@ -73,8 +67,7 @@ func (api *Api) SignIn(resp *tcpws.Response, req *tcpws.Request) {
 	// unmurshal auth data
 	err := json.Unmarshal([]byte(req.Body), &authUser)
 	if err != nil {
-		resp.StatusCode = http.StatusBadRequest
-		resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+		api.badRequest(resp, err)
 		return
 	}
 
@@ -83,11 +76,9 @@ func (api *Api) SignIn(resp *tcpws.Response, req *tcpws.Request) {
 	if err != nil {
 		switch {
 		case errors.Is(err, repo.ErrUserNotFound):
-			resp.StatusCode = http.StatusNotFound
-			resp.Status = repo.ErrUserNotFound.Error()
+			resp.Error(http.StatusNotFound, ErrCodeUserNotFound, err.Error(), nil)
 		default:
-			resp.StatusCode = http.StatusInternalServerError
-			resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+			api.internalError(resp, req, op, err)
 		}
 		return
 	}
@@ -96,11 +87,9 @@ func (api *Api) SignIn(resp *tcpws.Response, req *tcpws.Request) {
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrInvalidPassword):
-			resp.StatusCode = http.StatusBadRequest
-			resp.Status = service.ErrInvalidPassword.Error()
+			resp.Error(http.StatusBadRequest, ErrCodeInvalidPassword, err.Error(), nil)
 		default:
-			resp.StatusCode = http.StatusInternalServerError
-			resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+			api.internalError(resp, req, op, err)
 		}
 		return
 	}
@@ -115,8 +104,7 @@ func (api *Api) SignIn(resp *tcpws.Response, req *tcpws.Request) {
 		User:      *user,
 	})
 	if err != nil {
-		resp.StatusCode = http.StatusInternalServerError
-		resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+		api.internalError(resp, req, op, err)
 		return
 	}
 
@@ -132,8 +120,7 @@ func (api *Api) SignInByToken(resp *tcpws.Response, req *tcpws.Request) {
 	var token entity.Token
 	err := json.Unmarshal([]byte(req.Body), &token)
 	if err != nil {
-		resp.StatusCode = http.StatusBadRequest
-		resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+		api.badRequest(resp, err)
 		return
 	}
 
@@ -141,11 +128,9 @@ func (api *Api) SignInByToken(resp *tcpws.Response, req *tcpws.Request) {
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrInvalidToken):
-			resp.StatusCode = http.StatusUnauthorized
-			resp.Status = err.Error()
+			resp.Error(http.StatusUnauthorized, ErrCodeInvalidToken, err.Error(), nil)
 		default:
-			resp.StatusCode = http.StatusInternalServerError
-			resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+			api.internalError(resp, req, op, err)
 		}
 
 		return
@@ -153,15 +138,13 @@ func (api *Api) SignInByToken(resp *tcpws.Response, req *tcpws.Request) {
 
 	user, err := api.app.UserService.FindById(req.Ctx(), token.UserId)
 	if err != nil {
-		resp.StatusCode = http.StatusInternalServerError
-		resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+		api.internalError(resp, req, op, err)
 		return
 	}
 
 	response, err := json.Marshal(*user)
 	if err != nil {
-		resp.StatusCode = http.StatusInternalServerError
-		resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+		api.internalError(resp, req, op, err)
 		return
 	}
 
@@ -1,16 +1,21 @@
 package api
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net"
 	"net/http"
-	"time"
+	"strconv"
+
+	"github.com/gofrs/uuid"
 
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/service"
+	"github.com/sazonovItas/gochat-tcp/internal/middleware"
 	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
 )
 
@@ -18,135 +23,460 @@ const (
 	ProtoNotSupported   = "not supported protocol"
 	ReadyForMessages    = "ready for messages"
 	UnauthorizedMessage = "token expired"
+	ServerShuttingDown  = "server shutting down"
+	SubscriberLagging   = "too many unsent events"
+	HistoryGapTooLarge  = "gap too large, refetch history"
 )
 
+// SubscriberQueueSize is size of the events queue of chatting connection,
+// connection is closed if client does not keep up with it
+const SubscriberQueueSize = 64
+
+// DeduplicatorSize is count of the last sent event ids of chatting connection,
+// events published by the outbox more than once are not sent again
+const DeduplicatorSize = 1024
+
+// chattingHandshake is optional body of chatting request
+type chattingHandshake struct {
+	// LastSeenSeq is sequence number of the last event received by client, sequence
+	// numbers are shared by all event types, retained events after it are sent
+	// before new ones
+	LastSeenSeq *uint64 `json:"last_seen_seq"`
+
+	// MaxEventVersion is the highest version of events that client understands,
+	// it is the first version if it is not set
+	MaxEventVersion *int `json:"max_event_version"`
+}
+
+// chattingAccepted is body of chatting response
+type chattingAccepted struct {
+	// EventVersion is the highest version of events sent to client
+	EventVersion int `json:"event_version"`
+}
+
 // /api/v1/chatting
 func (api *Api) Chatting(resp *tcpws.Response, req *tcpws.Request) {
 	const op = "gochat.app.api.chatting.Chatting"
 
 	if req.Proto != tcpws.ProtoWS {
-		resp.StatusCode = http.StatusBadRequest
-		resp.Status = ProtoNotSupported
+		resp.Error(http.StatusBadRequest, ErrCodeProtoNotSupported, ProtoNotSupported, nil)
+		return
+	}
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
 		return
 	}
 
-	var token entity.Token
-	if err := json.Unmarshal([]byte(req.Body), &token); err != nil {
-		resp.StatusCode = http.StatusBadRequest
-		resp.Status = ProtoNotSupported
+	var handshake chattingHandshake
+	if req.Body != "" {
+		if err := json.Unmarshal([]byte(req.Body), &handshake); err != nil {
+			api.badRequest(resp, err)
+			return
+		}
+	}
+
+	// events are sent with the highest version understood by both sides
+	eventVersion := 1
+	if maxVersion := handshake.MaxEventVersion; maxVersion != nil {
+		if *maxVersion < 1 {
+			api.badRequest(resp, fmt.Errorf("%w: %d", service.ErrUnsupportedVersion, *maxVersion))
+			return
+		}
+		eventVersion = min(*maxVersion, api.app.EventService.MaxVersion())
+	}
+
+	// events are sent only of conversations of the user
+	convs, err := api.app.ConversationService.GetByMember(req.Ctx(), user.ID)
+	if err != nil {
+		api.internalError(resp, req, op, err)
 		return
 	}
+	filter := chattingFilter(user.ID, newMemberConversations(convs))
 
-	if err := api.app.AuthService.ValidateToken(req.Ctx(), token); err != nil {
-		resp.StatusCode = http.StatusUnauthorized
-		resp.Status = UnauthorizedMessage
+	accepted, err := json.Marshal(chattingAccepted{EventVersion: eventVersion})
+	if err != nil {
+		api.internalError(resp, req, op, err)
 		return
 	}
 
 	resp.StatusCode = http.StatusOK
 	resp.Status = ReadyForMessages
+	resp.Body = string(accepted)
 	if err := resp.Write(); err != nil {
 		return
 	}
 
-	stopch := make(chan struct{})
-	defer close(stopch)
+	// events are replayed before subscribing, so queue of the subscriber
+	// is not filled by new events while retained ones are sent
+	var (
+		lastSeq   uint64
+		replayErr error
+	)
+	dedup := service.NewEventDeduplicator(DeduplicatorSize)
+	if handshake.LastSeenSeq != nil {
+		lastSeq, replayErr = api.replayEvents(
+			resp,
+			req,
+			dedup,
+			filter,
+			*handshake.LastSeenSeq,
+			eventVersion,
+		)
+	}
+
+	subscription, eventch := api.app.EventService.Subscribe("*", &service.SubscriberOpts{
+		QueueSize: SubscriberQueueSize,
+		Policy:    service.Disconnect,
+		Filter:    filter,
+	})
 
-	eventch := make(chan entity.Event, 5)
-	subscriberId := api.app.EventService.Subscribe(service.NewMessageEventType, eventch)
+	// stopch is closed when reading is stopped, so closing of the queue
+	// after it means unsubscribe and not disconnect of the lagging subscriber
+	stopch, sentch := make(chan struct{}), make(chan struct{})
 	defer func() {
-		api.app.EventService.Unsubscribe(service.NewMessageEventType, subscriberId)
-		close(eventch)
-
-		stopch <- struct{}{}
+		close(stopch)
+		api.app.EventService.Unsubscribe(subscription)
+		<-sentch
 	}()
 
 	api.app.Logger.Info(
-		"new subscriber on NewMessageEvent",
+		"new chatting subscriber",
 		"subscriber_id",
-		subscriberId,
+		subscription.String(),
 		"user_id",
-		token.UserId,
+		user.ID,
 	)
 
-	// send events
-	go func() {
-		for {
-			select {
-			case <-stopch:
-				return
-			case event := <-eventch:
-				api.app.Logger.Info("new event", "event", event)
-				sendEvent := api.app.EventService.CreatePublicEvent(&event)
-
-				msg, err := json.Marshal(*sendEvent)
-				if err != nil {
-					api.app.Logger.Error(
-						"json marshal send event",
-						"error",
-						fmt.Errorf("%s: %w", op, err).Error(),
-					)
-					continue
-				}
-
-				_, err = resp.Conn.Write(msg)
-				if err != nil {
-					api.app.Logger.Error(
-						"message send",
-						"error",
-						fmt.Errorf("%s: %w", op, err).Error(),
-					)
-					continue
-				}
+	// events published between replay and subscribing are sent before queued ones,
+	// history gap is sent after subscribing, so events after history fetched by
+	// client are not missed
+	if handshake.LastSeenSeq != nil && replayErr == nil {
+		lastSeq, replayErr = api.replayEvents(resp, req, dedup, filter, lastSeq, eventVersion)
+	}
+	if replayErr != nil {
+		api.historyGap(resp, op, lastSeq, replayErr)
+		lastSeq = 0
+	}
+
+	// send events, panic of the sender closes the connection
+	middleware.Go(api.app.Logger, resp, req, func() {
+		defer close(sentch)
+
+		for event := range eventch {
+			// events up to lastSeq are already sent by replay
+			if event.Seq <= lastSeq {
+				continue
+			}
+			if isDuplicate(dedup, &event) {
+				continue
 			}
+
+			api.app.Logger.Debug(
+				"send event",
+				"type",
+				event.Type,
+				"id",
+				event.ID.String(),
+				"seq",
+				event.Seq,
+			)
+			api.writeEvent(resp, &event, eventVersion)
 		}
-	}()
+
+		select {
+		case <-stopch:
+		default:
+			// client should reconnect and fetch missed messages
+			api.app.Logger.Warn(
+				"disconnect lagging subscriber",
+				"subscriber_id",
+				subscription.String(),
+				"user_id",
+				user.ID,
+			)
+			api.sendEvent(
+				resp,
+				service.CloseEventType,
+				entity.CloseEvent{Reason: SubscriberLagging},
+			)
+			_ = resp.Conn.Close()
+		}
+	})
 
 	// read events
-	api.app.Logger.Info("start read messages")
+	api.app.Logger.Debug("start read messages", "user_id", user.ID)
 	for {
 		frame, err := resp.Conn.ReadFrame()
 		if err != nil {
 			switch {
-			case errors.Is(err, io.EOF):
-				api.app.Logger.Info("disconnection from user", "user_id", token.UserId)
+			case req.Ctx().Err() != nil:
+				api.app.Logger.Info("close chatting on shutdown", "user_id", user.ID)
+				api.sendEvent(
+					resp,
+					service.CloseEventType,
+					entity.CloseEvent{Reason: ServerShuttingDown},
+				)
+			case errors.Is(err, io.EOF), errors.Is(err, net.ErrClosed):
+				api.app.Logger.Info("disconnection from user", "user_id", user.ID)
 			default:
 				api.app.Logger.Error("read frame", "error", err.Error())
 			}
-			// stopch <- struct{}{}
 			break
 		}
 
-		var receivedEvent entity.PublicEvent
+		var receivedEvent entity.RawEvent
 		if err := json.Unmarshal(frame, &receivedEvent); err != nil {
 			api.app.Logger.Error("json unmarshal", "error", fmt.Errorf("%s: %w", op, err).Error())
 			continue
 		}
 
+		clientId := eventClientId(&receivedEvent)
+		if !api.allowMessage(resp, req, user, clientId) {
+			continue
+		}
+
 		event, err := api.app.EventService.CreateEvent(&receivedEvent)
+		if err == nil {
+			err = checkSender(event, user.ID)
+		}
 		if err != nil {
-			api.app.Logger.Error("create event", "error", fmt.Errorf("%s: %w", op, err).Error())
+			api.rejectEvent(resp, op, clientId, err)
 			continue
 		}
+		api.app.Logger.Debug(
+			"receive event",
+			"type",
+			event.Type,
+			"id",
+			event.ID.String(),
+			"user_id",
+			user.ID,
+		)
+
+		// handled event is published through the outbox
+		if err := api.app.EventService.HandleEvent(req.Ctx(), event); err != nil {
+			api.rejectEvent(resp, op, clientId, err)
+			continue
+		}
+
+		api.ackEvent(resp, event)
+	}
+}
+
+// checkSender checks that user sends, edits, deletes and reacts on messages only
+// on behalf of themselves
+func checkSender(event *entity.Event, userId int64) error {
+	senderId := userId
+	switch payload := event.Payload.(type) {
+	case entity.NewMessageEvent:
+		senderId = payload.SenderID
+	case entity.MessageEditedEvent:
+		senderId = payload.SenderID
+	case entity.MessageDeletedEvent:
+		senderId = payload.SenderID
+	case entity.MessageReactionEvent:
+		senderId = payload.UserID
+	}
+
+	if senderId != userId {
+		return fmt.Errorf("%w: %w", service.ErrInvalidPayload, service.ErrInvalidSender)
+	}
+
+	return nil
+}
+
+// eventClientId returns client id of the received event payload if it is set,
+// so rejection of the event could be matched by client with the sent event
+func eventClientId(event *entity.RawEvent) string {
+	var payload struct {
+		ClientID string `json:"client_id"`
+	}
+	_ = json.Unmarshal(event.Payload, &payload)
+
+	return payload.ClientID
+}
+
+// ackEvent confirms to the sender that message with client id is stored,
+// retries of the message are acknowledged with the same id and creation time
+func (api *Api) ackEvent(resp *tcpws.Response, event *entity.Event) {
+	msg, ok := event.Payload.(entity.NewMessageEvent)
+	if !ok || msg.ClientID == "" {
+		return
+	}
+
+	api.sendEvent(resp, service.MessageAckEventType, entity.MessageAckEvent{
+		ClientID:  msg.ClientID,
+		ID:        msg.ID,
+		CreatedAt: msg.CreatedAt,
+	})
+}
+
+// isDuplicate reports whether event with the same id is already sent
+func isDuplicate(dedup *service.EventDeduplicator, event *entity.Event) bool {
+	return event.ID != uuid.Nil && dedup.Seen(event.ID)
+}
+
+// replayEvents sends retained events accepted by filter after the sequence number
+// until there are no newer ones and returns sequence number of the last replayed
+// event, it is returned with the error if replay is failed
+// Errors: service.ErrSeqExpired, unknown
+func (api *Api) replayEvents(
+	resp *tcpws.Response,
+	req *tcpws.Request,
+	dedup *service.EventDeduplicator,
+	filter service.EventFilter,
+	lastSeenSeq uint64,
+	eventVersion int,
+) (uint64, error) {
+	lastSeq := lastSeenSeq
+	for {
+		events, err := api.app.EventService.Replay(req.Ctx(), lastSeq)
+		if err != nil {
+			return lastSeq, err
+		}
 
-		if msg, ok := event.Payload.(entity.NewMessageEvent); ok {
-			msg.CreatedAt = time.Now()
-			msg.UpdateAt = time.Now()
-			id, err := api.app.MessageService.Create(context.Background(), &entity.Message{
-				SenderID:    msg.SenderID,
-				MessageKind: msg.MessageKind,
-				Message:     msg.Message,
-				CreatedAt:   msg.CreatedAt,
-			})
-			if err != nil {
-				api.app.Logger.Error("create msg", "error", fmt.Errorf("%s: %w", op, err).Error())
+		if len(events) == 0 {
+			return lastSeq, nil
+		}
+
+		for i := range events {
+			lastSeq = events[i].Seq
+			if !filter(&events[i]) || isDuplicate(dedup, &events[i]) {
 				continue
 			}
 
-			msg.ID = id.String()
-			event.Payload = msg
+			api.writeEvent(resp, &events[i], eventVersion)
 		}
+	}
+}
+
+// historyGap notifies client that events after the sequence number are not
+// retained and it should fetch history of messages, errors except expired
+// sequence number are logged
+func (api *Api) historyGap(resp *tcpws.Response, op string, lastSeenSeq uint64, err error) {
+	if !errors.Is(err, service.ErrSeqExpired) {
+		api.app.Logger.Error("replay events", "error", fmt.Errorf("%s: %w", op, err).Error())
+	}
+
+	api.sendEvent(resp, service.HistoryGapEventType, entity.HistoryGapEvent{
+		LastSeenSeq: lastSeenSeq,
+		Reason:      HistoryGapTooLarge,
+	})
+}
+
+// writeEvent sends event with the highest version that is not greater than
+// event version of the client
+func (api *Api) writeEvent(resp *tcpws.Response, event *entity.Event, eventVersion int) {
+	const op = "gochat.app.api.chatting.writeEvent"
+
+	publicEvent, err := api.app.EventService.CreatePublicEvent(event, eventVersion)
+	if err != nil {
+		api.app.Logger.Error(
+			"create public event",
+			"error",
+			fmt.Errorf("%s: %w", op, err).Error(),
+		)
+		return
+	}
+
+	msg, err := json.Marshal(publicEvent)
+	if err != nil {
+		api.app.Logger.Error(
+			"json marshal send event",
+			"error",
+			fmt.Errorf("%s: %w", op, err).Error(),
+		)
+		return
+	}
+
+	if _, err := resp.Conn.Write(msg); err != nil {
+		api.app.Logger.Error("message send", "error", fmt.Errorf("%s: %w", op, err).Error())
+	}
+}
+
+// rejectEvent notifies client that received event is not published,
+// internal errors are logged and not sent to client
+func (api *Api) rejectEvent(resp *tcpws.Response, op string, clientId string, err error) {
+	errorEvent := entity.ErrorEvent{Message: err.Error(), ClientID: clientId}
+	switch {
+	case errors.Is(err, service.ErrUnknownEventType):
+		errorEvent.Code = ErrCodeUnknownEventType
+	case errors.Is(err, service.ErrEventNotAccepted):
+		errorEvent.Code = ErrCodeEventNotAccepted
+	case errors.Is(err, service.ErrInvalidPayload):
+		errorEvent.Code = ErrCodeInvalidEventPayload
+	case errors.Is(err, service.ErrUnsupportedVersion):
+		errorEvent.Code = ErrCodeUnsupportedEventVersion
+	case errors.Is(err, service.ErrNotConversationMember):
+		errorEvent.Code = ErrCodeNotConversationMember
+	case errors.Is(err, repo.ErrMessageNotFound):
+		errorEvent.Code = ErrCodeMessageNotFound
+	case errors.Is(err, service.ErrNotMessageSender):
+		errorEvent.Code = ErrCodeNotMessageSender
+	case errors.Is(err, service.ErrMessageDeleted):
+		errorEvent.Code = ErrCodeMessageDeleted
+	case errors.Is(err, service.ErrEditWindowExpired):
+		errorEvent.Code = ErrCodeEditWindowExpired
+	case errors.Is(err, service.ErrInvalidMessageKind),
+		errors.Is(err, service.ErrInvalidReply),
+		errors.Is(err, service.ErrInvalidEmoji),
+		errors.Is(err, service.ErrEmptyMessage),
+		errors.Is(err, service.ErrMessageTooLong):
+		errorEvent.Code = ErrCodeInvalidEventPayload
+	default:
+		api.app.Logger.Error("handle event", "error", fmt.Errorf("%s: %w", op, err).Error())
+		errorEvent.Code = tcpws.ErrCodeInternal
+		errorEvent.Message = http.StatusText(http.StatusInternalServerError)
+	}
+
+	api.sendEvent(resp, service.ErrorEventType, errorEvent)
+}
+
+// allowMessage checks chat messages limit of the user,
+// if it is exceeded notifies client with error event
+func (api *Api) allowMessage(
+	resp *tcpws.Response,
+	req *tcpws.Request,
+	user *entity.User,
+	clientId string,
+) bool {
+	const op = "gochat.app.api.chatting.allowMessage"
+
+	res, err := api.app.MessageLimiter.Allow(req.Ctx(), strconv.FormatInt(user.ID, 10))
+	if err != nil {
+		api.app.Logger.Error("rate limit", "error", fmt.Errorf("%s: %w", op, err).Error())
+		return true
+	}
+
+	if !res.Allowed {
+		api.sendEvent(resp, service.ErrorEventType, entity.ErrorEvent{
+			Code:    tcpws.ErrCodeTooManyRequests,
+			Message: "too many messages",
+			Details: map[string]interface{}{
+				"retry_after": int64(math.Ceil(res.RetryAfter.Seconds())),
+			},
+			ClientID: clientId,
+		})
+	}
+
+	return res.Allowed
+}
+
+// sendEvent sends public event of the type to the client
+func (api *Api) sendEvent(resp *tcpws.Response, eventType string, payload interface{}) {
+	const op = "gochat.app.api.chatting.sendEvent"
+
+	msg, err := json.Marshal(entity.PublicEvent{
+		Type:    eventType,
+		Payload: payload,
+	})
+	if err != nil {
+		api.app.Logger.Error("json marshal event", "error", fmt.Errorf("%s: %w", op, err).Error())
+		return
+	}
 
-		api.app.EventService.Publish(*event)
+	if _, err := resp.Conn.Write(msg); err != nil {
+		api.app.Logger.Error("event send", "error", fmt.Errorf("%s: %w", op, err).Error())
 	}
 }
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/service"
+)
+
+// memberConversations is set of conversations of the chatting user,
+// it is safe for concurrent use
+type memberConversations struct {
+	mu  sync.RWMutex
+	ids map[int64]struct{}
+}
+
+func newMemberConversations(convs []entity.Conversation) *memberConversations {
+	mc := &memberConversations{ids: make(map[int64]struct{}, len(convs))}
+	for i := range convs {
+		mc.ids[convs[i].ID] = struct{}{}
+	}
+
+	return mc
+}
+
+func (mc *memberConversations) has(id int64) bool {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	_, ok := mc.ids[id]
+	return ok
+}
+
+func (mc *memberConversations) add(id int64) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.ids[id] = struct{}{}
+}
+
+func (mc *memberConversations) remove(id int64) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	delete(mc.ids, id)
+}
+
+// chattingFilter accepts events of conversations of the user, conversations
+// the user becomes member of are added by the filter itself, so events of them
+// published right after are accepted too, and conversations the user is removed
+// from or left are removed, so their events are not accepted anymore. Membership
+// events of the user itself are always accepted, e.g. invites to the conversation.
+func chattingFilter(userId int64, convs *memberConversations) service.EventFilter {
+	return func(event *entity.Event) bool {
+		switch payload := event.Payload.(type) {
+		case entity.NewMessageEvent:
+			return convs.has(payload.ConversationID)
+		case entity.MessageEditedEvent:
+			return convs.has(payload.ConversationID)
+		case entity.MessageDeletedEvent:
+			return convs.has(payload.ConversationID)
+		case entity.MessageReactionEvent:
+			return convs.has(payload.ConversationID)
+		case entity.ConversationCreatedEvent:
+			if !slices.Contains(payload.MemberIDs, userId) {
+				return false
+			}
+
+			convs.add(payload.ID)
+			return true
+		case entity.ConversationMemberEvent:
+			if !slices.Contains(payload.UserIDs, userId) {
+				return convs.has(payload.ConversationID)
+			}
+
+			switch payload.Action {
+			case entity.MemberAdded, entity.MemberJoined:
+				convs.add(payload.ConversationID)
+			case entity.MemberRemoved, entity.MemberLeft:
+				convs.remove(payload.ConversationID)
+			}
+			return true
+		default:
+			return false
+		}
+	}
+}
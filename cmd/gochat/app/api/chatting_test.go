@@ -0,0 +1,436 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	gotcpws "github.com/sazonovItas/go-tcpws"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/core"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/service"
+	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
+	"github.com/sazonovItas/gochat-tcp/pkg/ratelimit"
+)
+
+// testMessageService is message service that keeps messages in memory,
+// messages of the sender with the same client id are stored once
+type testMessageService struct {
+	service.MessageService
+
+	mu       sync.Mutex
+	messages []entity.Message
+}
+
+func (ms *testMessageService) Create(_ context.Context, msg *entity.Message) (uuid.UUID, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, stored := range ms.messages {
+		if msg.ClientID != "" && stored.SenderID == msg.SenderID &&
+			stored.ClientID == msg.ClientID {
+			*msg = stored
+			return stored.ID, nil
+		}
+	}
+
+	msg.ID = uuid.Must(uuid.NewV4())
+	ms.messages = append(ms.messages, *msg)
+	return msg.ID, nil
+}
+
+func (ms *testMessageService) count() int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return len(ms.messages)
+}
+
+// testConversationService is conversation service with the common conversation only
+type testConversationService struct {
+	service.ConversationService
+}
+
+func (cs *testConversationService) GetByMember(
+	_ context.Context,
+	_ int64,
+) ([]entity.Conversation, error) {
+	return []entity.Conversation{{ID: entity.DefaultConversationID}}, nil
+}
+
+func (cs *testConversationService) CheckMember(
+	_ context.Context,
+	conversationId, _ int64,
+) error {
+	if conversationId != entity.DefaultConversationID {
+		return service.ErrNotConversationMember
+	}
+
+	return nil
+}
+
+// testChattingEvent is public event received by chatting client
+type testChattingEvent struct {
+	Seq     uint64          `json:"seq"`
+	Type    string          `json:"type"`
+	Version int             `json:"version"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// testChatting is chatting server of the user with in-memory event bus
+type testChatting struct {
+	events   service.EventService
+	messages *testMessageService
+	addr     string
+}
+
+// newTestChatting starts chatting server of the user on free address
+func newTestChatting(t *testing.T, user *entity.User) *testChatting {
+	t.Helper()
+
+	messages := &testMessageService{}
+	convs := &testConversationService{}
+
+	registry := service.NewEventRegistry()
+	service.Register(
+		registry,
+		service.NewMessageEventType,
+		service.NewMessageEventDef(messages, convs),
+	)
+	service.Register(
+		registry,
+		service.ConversationCreatedEventType,
+		service.EventDef[entity.ConversationCreatedEvent]{
+			Version: service.ConversationCreatedEventVersion,
+		},
+	)
+	events := service.NewEventService(service.NewEventBus(), registry)
+
+	api := NewApi(&core.Core{
+		Logger:              slog.New(slog.NewTextHandler(io.Discard, nil)),
+		ConversationService: convs,
+		EventService:        events,
+		MessageLimiter:      ratelimit.NewMemoryLimiter(ratelimit.PerMinute(100, 100)),
+	})
+
+	mux := tcpws.NewMuxHandler()
+	mux.Use(withTestUser(user))
+	mux.HandleFunc(tcpws.ProtoWS, "/chatting", api.Chatting)
+
+	return &testChatting{events: events, messages: messages, addr: startTestServer(t, mux)}
+}
+
+// withTestUser puts the user to the context of requests as authorized one
+func withTestUser(user *entity.User) tcpws.Middleware {
+	return func(next tcpws.HandlerFunc) tcpws.HandlerFunc {
+		return func(resp *tcpws.Response, req *tcpws.Request) {
+			ctx := context.WithValue(req.Ctx(), UserKey, user)
+			next(resp, req.WithContext(ctx))
+		}
+	}
+}
+
+// startTestServer starts server with mux on free address and returns its address
+func startTestServer(t *testing.T, mux *tcpws.MuxHandler) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	srv := tcpws.NewServer(addr, mux)
+	go func() { _ = srv.ListenAndServe() }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	})
+
+	// wait for server is listening
+	for i := 0; i < 100; i++ {
+		c, err := net.Dial("tcp", addr)
+		if err == nil {
+			c.Close()
+			return addr
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	t.Fatal("server is not listening")
+	return ""
+}
+
+// connect opens chatting connection with the handshake and returns response of it
+func (tc *testChatting) connect(t *testing.T, handshake string) (*gotcpws.Conn, int) {
+	t.Helper()
+
+	c, err := net.Dial("tcp", tc.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := gotcpws.NewFrameConnection(c, nil, nil, 0, true)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	req, err := json.Marshal(map[string]string{
+		"method": tcpws.ProtoWS,
+		"proto":  tcpws.ProtoWS,
+		"url":    "/chatting",
+		"body":   handshake,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(req); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp struct {
+		StatusCode int `json:"status_code"`
+	}
+	readTestFrame(t, conn, &resp)
+
+	return conn, resp.StatusCode
+}
+
+// publish publishes new message event of the conversation and returns its id
+func (tc *testChatting) publish(t *testing.T, conversationId int64) string {
+	t.Helper()
+
+	id := uuid.Must(uuid.NewV4())
+	err := tc.events.Publish(entity.Event{
+		ID:      id,
+		Type:    service.NewMessageEventType,
+		Version: service.NewMessageEventVersion,
+		Payload: entity.NewMessageEvent{
+			ID:             id.String(),
+			ConversationID: conversationId,
+			SenderID:       2,
+			MessageKind:    entity.UserTextMessage,
+			Message:        "hello",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id.String()
+}
+
+// readTestFrame reads frame of the connection to v
+func readTestFrame(t *testing.T, conn *gotcpws.Conn, v interface{}) {
+	t.Helper()
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	frame, err := conn.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(frame, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readTestEvent reads public event of the connection
+func readTestEvent(t *testing.T, conn *gotcpws.Conn) testChattingEvent {
+	t.Helper()
+
+	var event testChattingEvent
+	readTestFrame(t, conn, &event)
+	return event
+}
+
+// sendTestEvent sends event of the type with payload to the server
+func sendTestEvent(t *testing.T, conn *gotcpws.Conn, event entity.PublicEvent) {
+	t.Helper()
+
+	frame, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readTestError reads error event of the connection
+func readTestError(t *testing.T, conn *gotcpws.Conn) entity.ErrorEvent {
+	t.Helper()
+
+	event := readTestEvent(t, conn)
+	assert.Equal(t, service.ErrorEventType, event.Type, "should be error event")
+
+	var errorEvent entity.ErrorEvent
+	if err := json.Unmarshal(event.Payload, &errorEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	return errorEvent
+}
+
+func TestChatting_Replay(t *testing.T) {
+	user := &entity.User{ID: 1, Login: "user1", Name: "user1"}
+	tc := newTestChatting(t, user)
+
+	// sequence numbers are shared by event types
+	tc.publish(t, entity.DefaultConversationID)
+	err := tc.events.Publish(entity.Event{
+		ID:      uuid.Must(uuid.NewV4()),
+		Type:    service.ConversationCreatedEventType,
+		Version: service.ConversationCreatedEventVersion,
+		Payload: entity.ConversationCreatedEvent{
+			Conversation: entity.Conversation{ID: 7},
+			MemberIDs:    []int64{1, 2},
+		},
+	})
+	assert.Equal(t, nil, err, "should not be error")
+	tc.publish(t, 8)
+	replayed := tc.publish(t, 7)
+
+	t.Run("check replay after last seq", func(t *testing.T) {
+		conn, status := tc.connect(t, `{"last_seen_seq":1}`)
+		assert.Equal(t, http.StatusOK, status, "should be ready for messages")
+
+		event := readTestEvent(t, conn)
+		assert.Equal(t, service.ConversationCreatedEventType, event.Type, "should be next type")
+		assert.Equal(t, uint64(2), event.Seq, "should be next sequence number")
+
+		event = readTestEvent(t, conn)
+		assert.Equal(t, service.NewMessageEventType, event.Type, "should skip other conversation")
+		assert.Equal(t, uint64(4), event.Seq, "should be sequence number of the message")
+		assert.Contains(t, string(event.Payload), replayed, "should be replayed message")
+
+		live := tc.publish(t, entity.DefaultConversationID)
+		event = readTestEvent(t, conn)
+		assert.Equal(t, uint64(5), event.Seq, "should not send replayed events again")
+		assert.Contains(t, string(event.Payload), live, "should be new message")
+	})
+
+	t.Run("check expired seq", func(t *testing.T) {
+		conn, status := tc.connect(t, `{"last_seen_seq":100}`)
+		assert.Equal(t, http.StatusOK, status, "should be ready for messages")
+
+		event := readTestEvent(t, conn)
+		assert.Equal(t, service.HistoryGapEventType, event.Type, "should be history gap")
+
+		var gap entity.HistoryGapEvent
+		assert.Equal(t, nil, json.Unmarshal(event.Payload, &gap), "should not be error")
+		assert.Equal(t, uint64(100), gap.LastSeenSeq, "should be last seen seq of the client")
+
+		live := tc.publish(t, entity.DefaultConversationID)
+		event = readTestEvent(t, conn)
+		assert.Equal(t, service.NewMessageEventType, event.Type, "should send new events")
+		assert.Contains(t, string(event.Payload), live, "should be new message")
+	})
+}
+
+func TestChatting_Events(t *testing.T) {
+	user := &entity.User{ID: 1, Login: "user1", Name: "user1"}
+	tc := newTestChatting(t, user)
+
+	t.Run("check unsupported handshake version", func(t *testing.T) {
+		_, status := tc.connect(t, `{"max_event_version":0}`)
+		assert.Equal(t, http.StatusBadRequest, status, "should be bad request")
+	})
+
+	conn, status := tc.connect(t, `{"max_event_version":1}`)
+	assert.Equal(t, http.StatusOK, status, "should be ready for messages")
+
+	newMessage := func(clientId, text string) entity.NewMessageEvent {
+		return entity.NewMessageEvent{
+			ClientID:       clientId,
+			ConversationID: entity.DefaultConversationID,
+			SenderID:       user.ID,
+			MessageKind:    entity.UserTextMessage,
+			Message:        text,
+		}
+	}
+
+	t.Run("check ack of duplicate client id", func(t *testing.T) {
+		var acks [2]entity.MessageAckEvent
+		for i := range acks {
+			sendTestEvent(t, conn, entity.PublicEvent{
+				Type:    service.NewMessageEventType,
+				Payload: newMessage("client1", "hello"),
+			})
+
+			event := readTestEvent(t, conn)
+			assert.Equal(t, service.MessageAckEventType, event.Type, "should be ack")
+			assert.Equal(t, nil, json.Unmarshal(event.Payload, &acks[i]), "should not be error")
+		}
+
+		assert.Equal(t, "client1", acks[0].ClientID, "should be client id of the message")
+		assert.Equal(t, acks[0], acks[1], "should ack retry with the stored message")
+		assert.Equal(t, 1, tc.messages.count(), "should store message once")
+	})
+
+	t.Run("check unsupported event version", func(t *testing.T) {
+		sendTestEvent(t, conn, entity.PublicEvent{
+			Type:    service.NewMessageEventType,
+			Version: 99,
+			Payload: newMessage("client2", "hello"),
+		})
+
+		errorEvent := readTestError(t, conn)
+		assert.Equal(t, ErrCodeUnsupportedEventVersion, errorEvent.Code, "should be code")
+		assert.Equal(t, "client2", errorEvent.ClientID, "should be client id of the event")
+	})
+
+	t.Run("check unknown event", func(t *testing.T) {
+		sendTestEvent(t, conn, entity.PublicEvent{
+			Type:    "UnknownEvent",
+			Payload: map[string]string{"client_id": "client3"},
+		})
+
+		errorEvent := readTestError(t, conn)
+		assert.Equal(t, ErrCodeUnknownEventType, errorEvent.Code, "should be code")
+		assert.Equal(t, "client3", errorEvent.ClientID, "should be client id of the event")
+	})
+
+	t.Run("check invalid event", func(t *testing.T) {
+		sendTestEvent(t, conn, entity.PublicEvent{
+			Type:    service.NewMessageEventType,
+			Payload: newMessage("client4", " "),
+		})
+
+		errorEvent := readTestError(t, conn)
+		assert.Equal(t, ErrCodeInvalidEventPayload, errorEvent.Code, "should be code")
+
+		other := newMessage("client5", "hello")
+		other.SenderID = 2
+		sendTestEvent(t, conn, entity.PublicEvent{
+			Type:    service.NewMessageEventType,
+			Payload: other,
+		})
+
+		errorEvent = readTestError(t, conn)
+		assert.Equal(t, ErrCodeInvalidEventPayload, errorEvent.Code, "should not send as other")
+	})
+
+	t.Run("check event not accepted from clients", func(t *testing.T) {
+		sendTestEvent(t, conn, entity.PublicEvent{
+			Type: service.ConversationCreatedEventType,
+			Payload: entity.ConversationCreatedEvent{
+				Conversation: entity.Conversation{ID: 7},
+				MemberIDs:    []int64{1},
+			},
+		})
+
+		errorEvent := readTestError(t, conn)
+		assert.Equal(t, ErrCodeEventNotAccepted, errorEvent.Code, "should be code")
+		assert.Equal(t, 1, tc.messages.count(), "should not store rejected events")
+	})
+}
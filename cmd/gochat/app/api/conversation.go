@@ -0,0 +1,319 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
+)
+
+// /api/v1/conversations
+func (api *Api) GetConversations(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.conversation.GetConversations"
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	convs, err := api.app.ConversationService.GetByMember(req.Ctx(), user.ID)
+	if err != nil {
+		api.internalError(resp, req, op, err)
+		return
+	}
+
+	api.writeJSON(resp, req, op, http.StatusOK, convs)
+}
+
+// /api/v1/conversations/{id}
+func (api *Api) GetConversationById(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.conversation.GetConversationById"
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	convId, err := req.ParamInt64("id")
+	if err != nil {
+		api.invalidParam(resp, err)
+		return
+	}
+
+	conv, err := api.app.ConversationService.FindById(req.Ctx(), convId, user.ID)
+	if err != nil {
+		api.conversationError(resp, req, op, err)
+		return
+	}
+
+	api.writeJSON(resp, req, op, http.StatusOK, conv)
+}
+
+// /api/v1/conversations
+func (api *Api) CreateGroupConversation(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.conversation.CreateGroupConversation"
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	type request struct {
+		Title     string  `json:"title"`
+		Color     string  `json:"color"`
+		MemberIDs []int64 `json:"member_ids"`
+	}
+
+	var r request
+	if err := json.Unmarshal([]byte(req.Body), &r); err != nil {
+		api.badRequest(resp, err)
+		return
+	}
+
+	conv := &entity.Conversation{Title: r.Title, Color: r.Color, CreatorID: user.ID}
+	if _, err := api.app.ConversationService.CreateGroup(req.Ctx(), conv, r.MemberIDs); err != nil {
+		api.conversationError(resp, req, op, err)
+		return
+	}
+
+	api.writeJSON(resp, req, op, http.StatusCreated, conv)
+}
+
+// /api/v1/conversations/direct
+func (api *Api) CreateDirectConversation(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.conversation.CreateDirectConversation"
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	type request struct {
+		UserID int64 `json:"user_id"`
+	}
+
+	var r request
+	if err := json.Unmarshal([]byte(req.Body), &r); err != nil {
+		api.badRequest(resp, err)
+		return
+	}
+
+	conv, err := api.app.ConversationService.Direct(req.Ctx(), user.ID, r.UserID)
+	if err != nil {
+		api.conversationError(resp, req, op, err)
+		return
+	}
+
+	api.writeJSON(resp, req, op, http.StatusOK, conv)
+}
+
+// /api/v1/conversations/{id}/members
+func (api *Api) GetConversationMembers(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.conversation.GetConversationMembers"
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	convId, err := req.ParamInt64("id")
+	if err != nil {
+		api.invalidParam(resp, err)
+		return
+	}
+
+	memberIds, err := api.app.ConversationService.GetMembers(req.Ctx(), convId, user.ID)
+	if err != nil {
+		api.conversationError(resp, req, op, err)
+		return
+	}
+
+	api.writeJSON(resp, req, op, http.StatusOK, memberIds)
+}
+
+// /api/v1/conversations/{id}/members
+func (api *Api) AddConversationMembers(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.conversation.AddConversationMembers"
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	convId, err := req.ParamInt64("id")
+	if err != nil {
+		api.invalidParam(resp, err)
+		return
+	}
+
+	type request struct {
+		UserIDs []int64 `json:"user_ids"`
+	}
+
+	var r request
+	if err := json.Unmarshal([]byte(req.Body), &r); err != nil {
+		api.badRequest(resp, err)
+		return
+	}
+
+	err = api.app.ConversationService.AddMembers(req.Ctx(), convId, user.ID, r.UserIDs)
+	if err != nil {
+		api.conversationError(resp, req, op, err)
+		return
+	}
+
+	resp.StatusCode = http.StatusNoContent
+	resp.Status = http.StatusText(http.StatusNoContent)
+}
+
+// /api/v1/conversations/{id}/members/{user_id}
+func (api *Api) RemoveConversationMember(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.conversation.RemoveConversationMember"
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	convId, err := req.ParamInt64("id")
+	if err != nil {
+		api.invalidParam(resp, err)
+		return
+	}
+
+	memberId, err := req.ParamInt64("user_id")
+	if err != nil {
+		api.invalidParam(resp, err)
+		return
+	}
+
+	err = api.app.ConversationService.RemoveMember(req.Ctx(), convId, user.ID, memberId)
+	if err != nil {
+		api.conversationError(resp, req, op, err)
+		return
+	}
+
+	resp.StatusCode = http.StatusNoContent
+	resp.Status = http.StatusText(http.StatusNoContent)
+}
+
+// /api/v1/conversations/{id}/leave
+func (api *Api) LeaveConversation(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.conversation.LeaveConversation"
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	convId, err := req.ParamInt64("id")
+	if err != nil {
+		api.invalidParam(resp, err)
+		return
+	}
+
+	if err := api.app.ConversationService.Leave(req.Ctx(), convId, user.ID); err != nil {
+		api.conversationError(resp, req, op, err)
+		return
+	}
+
+	resp.StatusCode = http.StatusNoContent
+	resp.Status = http.StatusText(http.StatusNoContent)
+}
+
+// /api/v1/conversations/invites
+func (api *Api) GetConversationInvites(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.conversation.GetConversationInvites"
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	invites, err := api.app.ConversationService.GetInvites(req.Ctx(), user.ID)
+	if err != nil {
+		api.internalError(resp, req, op, err)
+		return
+	}
+
+	api.writeJSON(resp, req, op, http.StatusOK, invites)
+}
+
+// /api/v1/conversations/{id}/invites
+func (api *Api) InviteToConversation(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.conversation.InviteToConversation"
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	convId, err := req.ParamInt64("id")
+	if err != nil {
+		api.invalidParam(resp, err)
+		return
+	}
+
+	type request struct {
+		UserID int64 `json:"user_id"`
+	}
+
+	var r request
+	if err := json.Unmarshal([]byte(req.Body), &r); err != nil {
+		api.badRequest(resp, err)
+		return
+	}
+
+	err = api.app.ConversationService.Invite(req.Ctx(), convId, user.ID, r.UserID)
+	if err != nil {
+		api.conversationError(resp, req, op, err)
+		return
+	}
+
+	resp.StatusCode = http.StatusCreated
+	resp.Status = http.StatusText(http.StatusCreated)
+}
+
+// /api/v1/conversations/{id}/invites/accept
+func (api *Api) AcceptConversationInvite(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.conversation.AcceptConversationInvite"
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	convId, err := req.ParamInt64("id")
+	if err != nil {
+		api.invalidParam(resp, err)
+		return
+	}
+
+	if err := api.app.ConversationService.AcceptInvite(req.Ctx(), convId, user.ID); err != nil {
+		api.conversationError(resp, req, op, err)
+		return
+	}
+
+	resp.StatusCode = http.StatusNoContent
+	resp.Status = http.StatusText(http.StatusNoContent)
+}
+
+// writeJSON replies with status code and body encoded to json
+func (api *Api) writeJSON(
+	resp *tcpws.Response,
+	req *tcpws.Request,
+	op string,
+	statusCode int,
+	body interface{},
+) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		api.internalError(resp, req, op, err)
+		return
+	}
+
+	resp.StatusCode = statusCode
+	resp.Status = http.StatusText(statusCode)
+	resp.Body = string(data)
+}
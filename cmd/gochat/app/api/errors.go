@@ -0,0 +1,113 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/service"
+	"github.com/sazonovItas/gochat-tcp/internal/middleware"
+	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
+)
+
+// Error codes of api error body
+const (
+	ErrCodeProtoNotSupported = "proto_not_supported"
+	ErrCodeUserLoginExists   = "user_login_exists"
+	ErrCodeUserNotFound      = "user_not_found"
+	ErrCodeInvalidPassword   = "invalid_password"
+	ErrCodeInvalidToken      = "invalid_token"
+
+	ErrCodeConversationNotFound  = "conversation_not_found"
+	ErrCodeNotConversationMember = "not_conversation_member"
+	ErrCodeInvalidConversation   = "invalid_conversation"
+	ErrCodeMembershipForbidden   = "membership_forbidden"
+	ErrCodeMemberNotFound        = "member_not_found"
+	ErrCodeAlreadyMember         = "already_member"
+	ErrCodeInviteNotFound        = "invite_not_found"
+	ErrCodeInviteExists          = "invite_exists"
+
+	ErrCodeMessageNotFound   = "message_not_found"
+	ErrCodeNotMessageSender  = "not_message_sender"
+	ErrCodeMessageDeleted    = "message_deleted"
+	ErrCodeEditWindowExpired = "edit_window_expired"
+
+	ErrCodeUnknownEventType        = "unknown_event_type"
+	ErrCodeInvalidEventPayload     = "invalid_event_payload"
+	ErrCodeUnsupportedEventVersion = "unsupported_event_version"
+	ErrCodeEventNotAccepted        = "event_not_accepted"
+)
+
+// badRequest replies with error of decoding request body
+func (api *Api) badRequest(resp *tcpws.Response, err error) {
+	resp.Error(
+		http.StatusBadRequest,
+		tcpws.ErrCodeBadRequest,
+		"invalid request body",
+		map[string]string{"error": err.Error()},
+	)
+}
+
+// invalidParam replies with error of url param or query value validation
+func (api *Api) invalidParam(resp *tcpws.Response, err error) {
+	details := map[string]string{"error": err.Error()}
+
+	var paramErr *tcpws.ParamError
+	if errors.As(err, &paramErr) {
+		details["param"] = paramErr.Name
+	}
+
+	resp.Error(http.StatusBadRequest, tcpws.ErrCodeBadRequest, "invalid param", details)
+}
+
+// conversationError replies with error of access to the conversation or its members
+func (api *Api) conversationError(
+	resp *tcpws.Response,
+	req *tcpws.Request,
+	op string,
+	err error,
+) {
+	switch {
+	case errors.Is(err, service.ErrNotConversationMember):
+		resp.Error(http.StatusForbidden, ErrCodeNotConversationMember, err.Error(), nil)
+	case errors.Is(err, repo.ErrConversationNotFound):
+		resp.Error(http.StatusNotFound, ErrCodeConversationNotFound, err.Error(), nil)
+	case errors.Is(err, repo.ErrUserNotFound):
+		resp.Error(http.StatusNotFound, ErrCodeUserNotFound, err.Error(), nil)
+	case errors.Is(err, service.ErrInvalidConversation):
+		resp.Error(http.StatusBadRequest, ErrCodeInvalidConversation, err.Error(), nil)
+	case errors.Is(err, service.ErrMembershipForbidden):
+		resp.Error(http.StatusForbidden, ErrCodeMembershipForbidden, err.Error(), nil)
+	case errors.Is(err, repo.ErrMemberNotFound):
+		resp.Error(http.StatusNotFound, ErrCodeMemberNotFound, err.Error(), nil)
+	case errors.Is(err, service.ErrAlreadyMember):
+		resp.Error(http.StatusConflict, ErrCodeAlreadyMember, err.Error(), nil)
+	case errors.Is(err, repo.ErrInviteNotFound):
+		resp.Error(http.StatusNotFound, ErrCodeInviteNotFound, err.Error(), nil)
+	case errors.Is(err, repo.ErrInviteExists):
+		resp.Error(http.StatusConflict, ErrCodeInviteExists, err.Error(), nil)
+	default:
+		api.internalError(resp, req, op, err)
+	}
+}
+
+// internalError logs wrapped error and replies with internal error,
+// so internal details are not sent to client
+func (api *Api) internalError(resp *tcpws.Response, req *tcpws.Request, op string, err error) {
+	requestId, _ := req.Ctx().Value(middleware.RequestIdKey).(uint64)
+	api.app.Logger.Error(
+		"internal error",
+		"request_id",
+		requestId,
+		"error",
+		fmt.Errorf("%s: %w", op, err).Error(),
+	)
+
+	resp.Error(
+		http.StatusInternalServerError,
+		tcpws.ErrCodeInternal,
+		http.StatusText(http.StatusInternalServerError),
+		nil,
+	)
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/sazonovItas/gochat-tcp/internal/middleware"
+	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
+)
+
+// healthStatus is body of health response, it reports only liveness of the server
+type healthStatus struct {
+	Status string `json:"status"`
+}
+
+// serverStats is body of stats response, it is available only for authorized users
+type serverStats struct {
+	// Panics is count of recovered panics of handlers since start of the server
+	Panics uint64 `json:"panics"`
+
+	// Subscribers are lag metrics of event subscribers of the instance
+	Subscribers []subscriberStats `json:"subscribers"`
+}
+
+// subscriberStats is public view of service.SubscriberStats
+type subscriberStats struct {
+	Subscription string `json:"subscription"`
+	Pattern      string `json:"pattern"`
+	Policy       string `json:"policy"`
+	Queued       int    `json:"queued"`
+	Capacity     int    `json:"capacity"`
+	Enqueued     uint64 `json:"enqueued"`
+	Dropped      uint64 `json:"dropped"`
+	Filtered     uint64 `json:"filtered"`
+}
+
+// /api/v1/health
+func (api *Api) Health(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.health.Health"
+
+	api.writeJSON(resp, req, op, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// /api/v1/stats
+func (api *Api) Stats(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.health.Stats"
+
+	stats := api.app.EventService.Stats()
+	subscribers := make([]subscriberStats, 0, len(stats))
+	for _, s := range stats {
+		subscribers = append(subscribers, subscriberStats{
+			Subscription: s.Subscription.String(),
+			Pattern:      s.Pattern,
+			Policy:       s.Policy.String(),
+			Queued:       s.Queued,
+			Capacity:     s.Capacity,
+			Enqueued:     s.Enqueued,
+			Dropped:      s.Dropped,
+			Filtered:     s.Filtered,
+		})
+	}
+
+	// the most lagging subscribers first
+	sort.Slice(subscribers, func(i, j int) bool {
+		return subscribers[i].Queued > subscribers[j].Queued
+	})
+
+	api.writeJSON(resp, req, op, http.StatusOK, serverStats{
+		Panics:      middleware.PanicCount(),
+		Subscribers: subscribers,
+	})
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/core"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/service"
+	"github.com/sazonovItas/gochat-tcp/internal/middleware"
+	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
+)
+
+func TestHealth(t *testing.T) {
+	api := NewApi(&core.Core{})
+
+	resp := &tcpws.Response{Header: map[string]interface{}{}}
+	api.Health(resp, &tcpws.Request{Method: "GET", Proto: tcpws.ProtoHTTP})
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "should be ok")
+	assert.Equal(t, `{"status":"ok"}`, resp.Body, "should be only status")
+}
+
+func TestStats(t *testing.T) {
+	events := service.NewEventService(service.NewEventBus(), service.NewEventRegistry())
+	api := NewApi(&core.Core{EventService: events})
+
+	sub, _ := events.Subscribe("*", &service.SubscriberOpts{
+		QueueSize: 4,
+		Policy:    service.Disconnect,
+	})
+	defer events.Unsubscribe(sub)
+
+	resp := &tcpws.Response{Header: map[string]interface{}{}}
+	api.Stats(resp, &tcpws.Request{Method: "GET", Proto: tcpws.ProtoHTTP})
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "should be ok")
+
+	var body serverStats
+	if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, middleware.PanicCount(), body.Panics, "should be count of panics")
+	if assert.Equal(t, 1, len(body.Subscribers), "should be stats of subscriber") {
+		assert.Equal(t, sub.String(), body.Subscribers[0].Subscription, "should be subscription")
+		assert.Equal(t, "disconnect", body.Subscribers[0].Policy, "should be policy")
+		assert.Equal(t, 4, body.Subscribers[0].Capacity, "should be capacity of the queue")
+	}
+}
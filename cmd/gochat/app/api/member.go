@@ -3,9 +3,7 @@ package api
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
-	"strconv"
 
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
 	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
@@ -15,10 +13,9 @@ import (
 func (api *Api) GetChatMemberById(resp *tcpws.Response, req *tcpws.Request) {
 	const op = "gochat.app.api.user.GetChatMemberById"
 
-	userId, err := strconv.ParseInt(req.ParamByName("id"), 10, 64)
+	userId, err := req.ParamInt64("id")
 	if err != nil {
-		resp.StatusCode = http.StatusBadRequest
-		resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+		api.invalidParam(resp, err)
 		return
 	}
 
@@ -26,11 +23,9 @@ func (api *Api) GetChatMemberById(resp *tcpws.Response, req *tcpws.Request) {
 	if err != nil {
 		switch {
 		case errors.Is(err, repo.ErrUserNotFound):
-			resp.StatusCode = http.StatusNotFound
-			resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+			resp.Error(http.StatusNotFound, ErrCodeUserNotFound, err.Error(), nil)
 		default:
-			resp.StatusCode = http.StatusInternalServerError
-			resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+			api.internalError(resp, req, op, err)
 		}
 
 		return
@@ -38,8 +33,7 @@ func (api *Api) GetChatMemberById(resp *tcpws.Response, req *tcpws.Request) {
 
 	response, err := json.Marshal(*user)
 	if err != nil {
-		resp.StatusCode = http.StatusInternalServerError
-		resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+		api.internalError(resp, req, op, err)
 		return
 	}
 
@@ -54,15 +48,13 @@ func (api *Api) GetChatMembers(resp *tcpws.Response, req *tcpws.Request) {
 
 	users, err := api.app.UserService.GetPublicUsers(req.Ctx())
 	if err != nil {
-		resp.StatusCode = http.StatusInternalServerError
-		resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+		api.internalError(resp, req, op, err)
 		return
 	}
 
 	response, err := json.Marshal(users)
 	if err != nil {
-		resp.StatusCode = http.StatusInternalServerError
-		resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+		api.internalError(resp, req, op, err)
 		return
 	}
 
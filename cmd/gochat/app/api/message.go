@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/gofrs/uuid"
+
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
 	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
@@ -16,59 +19,193 @@ const (
 	NoMoreMessages = "no more messages"
 )
 
+const (
+	// DefaultPageLimit is count of messages of the page if limit is not set
+	DefaultPageLimit = 50
+
+	// MaxPageLimit is max count of messages of the page
+	MaxPageLimit = 200
+)
+
+// ErrLimitOutOfRange is returned if limit of the page is not positive or too large
+var ErrLimitOutOfRange = fmt.Errorf("limit should be from 1 to %d", MaxPageLimit)
+
 // /api/v1/messages
 func (api *Api) GetMessagesPrevTimestamp(resp *tcpws.Response, req *tcpws.Request) {
 	const op = "gochat.app.api.messages.MessagesPrevTimestamp"
 
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	// messages of the common conversation are returned if conversation is not set
 	type request struct {
-		Token     entity.Token `json:"auth_token"`
-		Timestamp time.Time    `json:"timestamp"`
-		Limit     int          `json:"limit"`
+		ConversationID int64     `json:"conversation_id"`
+		Timestamp      time.Time `json:"timestamp"`
+		Limit          *int64    `json:"limit"`
 	}
 
 	var r request
 	if err := json.Unmarshal([]byte(req.Body), &r); err != nil {
-		resp.StatusCode = http.StatusBadRequest
-		resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+		api.badRequest(resp, err)
 		return
 	}
 
-	if err := api.app.AuthService.ValidateToken(req.Ctx(), r.Token); err != nil {
-		resp.StatusCode = http.StatusUnauthorized
-		resp.Status = UnauthorizedMessage
+	// limit could be set by query e.g. /api/v1/messages?limit=50
+	limit, err := pageLimit(req, r.Limit)
+	if err != nil {
+		api.invalidParam(resp, err)
+		return
+	}
+
+	err = api.app.ConversationService.CheckMember(req.Ctx(), r.ConversationID, user.ID)
+	if err != nil {
+		api.conversationError(resp, req, op, err)
 		return
 	}
 
 	messages, err := api.app.MessageService.GetConvMessagesPrevTimestamp(
 		req.Ctx(),
+		r.ConversationID,
 		r.Timestamp,
-		r.Limit,
+		limit,
 	)
 	if err != nil {
 		switch {
 		case errors.Is(err, repo.ErrNoMessages):
 			resp.StatusCode = http.StatusOK
 			resp.Status = NoMoreMessages
-			resp.Body = "{messages:[]}"
+			resp.Body = `{"messages":[]}`
 		default:
-			resp.StatusCode = http.StatusInternalServerError
-			resp.Status = fmt.Errorf("%s: %w", op, err).Error()
-			return
+			api.internalError(resp, req, op, err)
 		}
+
+		return
+	}
+
+	if err := api.app.ReactionService.Attach(req.Ctx(), messages); err != nil {
+		api.internalError(resp, req, op, err)
+		return
 	}
 
 	type response struct {
 		Messages []entity.Message `json:"messages"`
 	}
 
-	data, err := json.Marshal(response{Messages: messages})
+	api.writeJSON(resp, req, op, http.StatusOK, response{Messages: messages})
+}
+
+// /api/v1/messages/{id}/replies
+func (api *Api) GetThreadReplies(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.messages.GetThreadReplies"
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	value := req.ParamByName("id")
+	messageId, err := uuid.FromString(value)
+	if err != nil {
+		api.invalidParam(resp, &tcpws.ParamError{Name: "id", Value: value, Err: err})
+		return
+	}
+
+	// replies are returned from the beginning of the thread if timestamp is not set
+	type request struct {
+		Timestamp time.Time `json:"timestamp"`
+		Limit     *int64    `json:"limit"`
+	}
+
+	var r request
+	if req.Body != "" {
+		if err := json.Unmarshal([]byte(req.Body), &r); err != nil {
+			api.badRequest(resp, err)
+			return
+		}
+	}
+
+	// limit could be set by query e.g. /api/v1/messages/{id}/replies?limit=50
+	limit, err := pageLimit(req, r.Limit)
 	if err != nil {
-		resp.StatusCode = http.StatusInternalServerError
-		resp.Status = fmt.Errorf("%s: %w", op, err).Error()
+		api.invalidParam(resp, err)
 		return
 	}
 
-	resp.StatusCode = http.StatusOK
-	resp.Status = http.StatusText(http.StatusOK)
-	resp.Body = string(data)
+	root, err := api.app.MessageService.FindById(req.Ctx(), messageId)
+	if err != nil {
+		switch {
+		case errors.Is(err, repo.ErrMessageNotFound):
+			resp.Error(http.StatusNotFound, ErrCodeMessageNotFound, err.Error(), nil)
+		default:
+			api.internalError(resp, req, op, err)
+		}
+
+		return
+	}
+
+	err = api.app.ConversationService.CheckMember(req.Ctx(), root.ConversationID, user.ID)
+	if err != nil {
+		api.conversationError(resp, req, op, err)
+		return
+	}
+
+	// replies of the thread of the reply are returned if message is reply
+	threadId := root.ID
+	if root.ThreadID != nil {
+		threadId = *root.ThreadID
+	}
+
+	messages, err := api.app.MessageService.GetThreadReplies(
+		req.Ctx(),
+		threadId,
+		r.Timestamp,
+		limit,
+	)
+	if err != nil && !errors.Is(err, repo.ErrNoMessages) {
+		api.internalError(resp, req, op, err)
+		return
+	}
+
+	if err := api.app.ReactionService.Attach(req.Ctx(), messages); err != nil {
+		api.internalError(resp, req, op, err)
+		return
+	}
+
+	type response struct {
+		Messages []entity.Message `json:"messages"`
+	}
+
+	if messages == nil {
+		messages = []entity.Message{}
+	}
+	api.writeJSON(resp, req, op, http.StatusOK, response{Messages: messages})
+}
+
+// pageLimit returns limit of the page set by query or body of the request,
+// limit of the query overrides the body one, if it is not set default one is returned
+// Errors: *tcpws.ParamError with ErrLimitOutOfRange or ErrParamNotFound or strconv error
+func pageLimit(req *tcpws.Request, bodyLimit *int64) (int, error) {
+	limit := int64(DefaultPageLimit)
+	if bodyLimit != nil {
+		limit = *bodyLimit
+	}
+
+	if req.Query().Has("limit") {
+		var err error
+		if limit, err = req.QueryInt64("limit"); err != nil {
+			return 0, err
+		}
+	}
+
+	if limit <= 0 || limit > MaxPageLimit {
+		return 0, &tcpws.ParamError{
+			Name:  "limit",
+			Value: strconv.FormatInt(limit, 10),
+			Err:   ErrLimitOutOfRange,
+		}
+	}
+
+	return int(limit), nil
 }
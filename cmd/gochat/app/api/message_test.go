@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	gotcpws "github.com/sazonovItas/go-tcpws"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/core"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/service"
+	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
+)
+
+// testPageMessageService returns stored messages as any page and keeps limit of the last one
+type testPageMessageService struct {
+	testMessageService
+
+	limit int
+}
+
+func (ms *testPageMessageService) GetConvMessagesPrevTimestamp(
+	_ context.Context,
+	_ int64,
+	_ time.Time,
+	limit int,
+) ([]entity.Message, error) {
+	ms.limit = limit
+	if len(ms.messages) == 0 {
+		return nil, repo.ErrNoMessages
+	}
+
+	return ms.messages, nil
+}
+
+func (ms *testPageMessageService) FindById(
+	_ context.Context,
+	id uuid.UUID,
+) (*entity.Message, error) {
+	for i := range ms.messages {
+		if ms.messages[i].ID == id {
+			msg := ms.messages[i]
+			return &msg, nil
+		}
+	}
+
+	return nil, repo.ErrMessageNotFound
+}
+
+func (ms *testPageMessageService) GetThreadReplies(
+	_ context.Context,
+	threadId uuid.UUID,
+	_ time.Time,
+	limit int,
+) ([]entity.Message, error) {
+	ms.limit = limit
+
+	var replies []entity.Message
+	for _, msg := range ms.messages {
+		if msg.ThreadID != nil && *msg.ThreadID == threadId {
+			replies = append(replies, msg)
+		}
+	}
+	if len(replies) == 0 {
+		return nil, repo.ErrNoMessages
+	}
+
+	return replies[:min(limit, len(replies))], nil
+}
+
+// testReactionService is reaction service of messages without reactions
+type testReactionService struct {
+	service.ReactionService
+}
+
+func (rs *testReactionService) Attach(_ context.Context, _ []entity.Message) error {
+	return nil
+}
+
+// testMessagesResponse is response of the messages request
+type testMessagesResponse struct {
+	StatusCode int    `json:"status_code"`
+	Body       string `json:"body"`
+}
+
+// newTestMessagesApi starts server with messages routes of the user and returns its address
+func newTestMessagesApi(t *testing.T, messages service.MessageService) string {
+	t.Helper()
+
+	api := NewApi(&core.Core{
+		Logger:              slog.New(slog.NewTextHandler(io.Discard, nil)),
+		MessageService:      messages,
+		ConversationService: &testConversationService{},
+		ReactionService:     &testReactionService{},
+	})
+
+	mux := tcpws.NewMuxHandler()
+	mux.Use(withTestUser(&entity.User{ID: 1, Login: "user1", Name: "user1"}))
+	mux.HandleFunc("GET", "/messages", api.GetMessagesPrevTimestamp)
+	mux.HandleFunc("GET", "/messages/{id}/replies", api.GetThreadReplies)
+
+	return startTestServer(t, mux)
+}
+
+// requestTestMessages sends request with the body to the server and returns response
+func requestTestMessages(t *testing.T, addr, url, body string) testMessagesResponse {
+	t.Helper()
+
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := gotcpws.NewFrameConnection(c, nil, nil, 0, true)
+	defer conn.Close()
+
+	req, err := json.Marshal(map[string]string{
+		"method": "GET",
+		"proto":  tcpws.ProtoHTTP,
+		"url":    url,
+		"body":   body,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(req); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp testMessagesResponse
+	readTestFrame(t, conn, &resp)
+	return resp
+}
+
+func TestGetMessagesPrevTimestamp_Limit(t *testing.T) {
+	messages := &testPageMessageService{}
+	messages.messages = []entity.Message{{Message: "hello"}}
+	addr := newTestMessagesApi(t, messages)
+
+	t.Run("check default limit", func(t *testing.T) {
+		resp := requestTestMessages(t, addr, "/messages", `{}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "should be ok")
+		assert.Equal(t, DefaultPageLimit, messages.limit, "should be default limit")
+	})
+
+	t.Run("check limit of the query", func(t *testing.T) {
+		resp := requestTestMessages(t, addr, "/messages?limit=20", `{"limit":10}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "should be ok")
+		assert.Equal(t, 20, messages.limit, "should override limit of the body")
+	})
+
+	t.Run("check limit out of range", func(t *testing.T) {
+		for _, url := range []string{
+			"/messages?limit=0",
+			"/messages?limit=-1",
+			"/messages?limit=201",
+			"/messages?limit=9223372036854775807",
+		} {
+			resp := requestTestMessages(t, addr, url, `{}`)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "should be bad request")
+
+			var body tcpws.ErrorBody
+			assert.Equal(t, nil, json.Unmarshal([]byte(resp.Body), &body), "should be error body")
+			assert.Equal(t, tcpws.ErrCodeBadRequest, body.Code, "should be bad request code")
+		}
+
+		resp := requestTestMessages(t, addr, "/messages", `{"limit":-5}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "should check limit of the body")
+	})
+}
+
+func TestGetMessagesPrevTimestamp_NoMessages(t *testing.T) {
+	addr := newTestMessagesApi(t, &testPageMessageService{})
+
+	resp := requestTestMessages(t, addr, "/messages", `{}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "should be ok")
+
+	var body struct {
+		Messages []entity.Message `json:"messages"`
+	}
+	assert.Equal(t, nil, json.Unmarshal([]byte(resp.Body), &body), "should be valid json")
+	assert.Equal(t, []entity.Message{}, body.Messages, "should be empty messages")
+}
+
+func TestGetThreadReplies_Limit(t *testing.T) {
+	root := entity.Message{ID: uuid.Must(uuid.NewV4()), Message: "root"}
+	reply := entity.Message{ID: uuid.Must(uuid.NewV4()), Message: "reply", ThreadID: &root.ID}
+
+	messages := &testPageMessageService{}
+	messages.messages = []entity.Message{root, reply}
+	addr := newTestMessagesApi(t, messages)
+	url := "/messages/" + root.ID.String() + "/replies"
+
+	t.Run("check replies without limit", func(t *testing.T) {
+		resp := requestTestMessages(t, addr, url, "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "should be ok")
+		assert.Equal(t, DefaultPageLimit, messages.limit, "should be default limit")
+
+		var body struct {
+			Messages []entity.Message `json:"messages"`
+		}
+		assert.Equal(t, nil, json.Unmarshal([]byte(resp.Body), &body), "should not be error")
+		if assert.Equal(t, 1, len(body.Messages), "should be replies of the thread") {
+			assert.Equal(t, reply.ID, body.Messages[0].ID, "should be reply")
+		}
+	})
+
+	t.Run("check limit out of range", func(t *testing.T) {
+		for _, limit := range []string{"0", "-1", "201"} {
+			resp := requestTestMessages(t, addr, url+"?limit="+limit, "")
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "should be bad request")
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/service"
+	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
+)
+
+// HeaderAuthorization is request header with token id e.g. "Bearer <token id>"
+const HeaderAuthorization = "Authorization"
+
+// Type for the context
+type CtxKeyUser int
+
+const UserKey CtxKeyUser = 0
+
+// Auth authenticates request by token from authorization header and puts
+// user to the request context, unauthenticated requests are rejected
+func (api *Api) Auth() tcpws.Middleware {
+	return func(next tcpws.HandlerFunc) tcpws.HandlerFunc {
+		fn := func(resp *tcpws.Response, req *tcpws.Request) {
+			const op = "gochat.app.api.middleware.Auth"
+
+			tokenId, ok := authorizationToken(req)
+			if !ok {
+				resp.Error(
+					http.StatusUnauthorized,
+					tcpws.ErrCodeUnauthorized,
+					"authorization token is required",
+					nil,
+				)
+				return
+			}
+
+			user, err := api.app.AuthService.Authenticate(req.Ctx(), tokenId)
+			if err != nil {
+				switch {
+				case errors.Is(err, service.ErrInvalidToken):
+					resp.Error(http.StatusUnauthorized, ErrCodeInvalidToken, UnauthorizedMessage, nil)
+				default:
+					api.internalError(resp, req, op, err)
+				}
+				return
+			}
+
+			ctx := context.WithValue(req.Ctx(), UserKey, user)
+			next.Serve(resp, req.WithContext(ctx))
+		}
+
+		return fn
+	}
+}
+
+// UserFromCtx returns authenticated user from the context
+func UserFromCtx(ctx context.Context) (*entity.User, bool) {
+	user, ok := ctx.Value(UserKey).(*entity.User)
+	return user, ok
+}
+
+// KeyByUser returns id of authenticated user as rate limiting key
+func KeyByUser(resp *tcpws.Response, req *tcpws.Request) string {
+	user, ok := UserFromCtx(req.Ctx())
+	if !ok {
+		return ""
+	}
+
+	return strconv.FormatInt(user.ID, 10)
+}
+
+// authUser returns authenticated user of the request,
+// if there is no user replies with unauthorized error
+func (api *Api) authUser(resp *tcpws.Response, req *tcpws.Request) (*entity.User, bool) {
+	user, ok := UserFromCtx(req.Ctx())
+	if !ok {
+		resp.Error(http.StatusUnauthorized, tcpws.ErrCodeUnauthorized, UnauthorizedMessage, nil)
+		return nil, false
+	}
+
+	return user, true
+}
+
+// authorizationToken returns token id from authorization header
+func authorizationToken(req *tcpws.Request) (entity.TokenID, bool) {
+	header, ok := req.Header[HeaderAuthorization].(string)
+	if !ok {
+		return "", false
+	}
+
+	tokenId := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	if tokenId == "" {
+		return "", false
+	}
+
+	return entity.TokenID(tokenId), true
+}
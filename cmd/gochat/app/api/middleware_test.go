@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/core"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/service"
+	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
+	"github.com/sazonovItas/gochat-tcp/pkg/cache"
+)
+
+type testUserStorage map[int64]*entity.User
+
+func (s testUserStorage) FindById(_ context.Context, id int64) (*entity.User, error) {
+	user, ok := s[id]
+	if !ok {
+		return nil, repo.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// newTestAuth returns api with auth service that keeps tokens in miniredis
+// and token repository to sign in users of the storage
+func newTestAuth(
+	t *testing.T,
+	users testUserStorage,
+) (*Api, repo.TokenRepository, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	tokens := repo.NewTokenRepository(
+		cache.NewCache[entity.Token](&cache.CacheOpts{
+			Client:            client,
+			KeyPrefix:         "auth_token",
+			DefaultExpiration: time.Minute,
+		}),
+		users,
+	)
+
+	api := NewApi(&core.Core{
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		AuthService: service.NewAuthService(tokens),
+	})
+
+	return api, tokens, mr
+}
+
+func TestAuth(t *testing.T) {
+	user := &entity.User{ID: 1, Login: "user1", Name: "user1"}
+	api, tokens, mr := newTestAuth(t, testUserStorage{user.ID: user})
+
+	var authorized *entity.User
+	handler := api.Auth()(func(resp *tcpws.Response, req *tcpws.Request) {
+		authorized, _ = UserFromCtx(req.Ctx())
+		resp.StatusCode = http.StatusOK
+	})
+
+	serve := func(header map[string]interface{}) (*tcpws.Response, tcpws.ErrorBody) {
+		authorized = nil
+
+		resp := &tcpws.Response{Header: map[string]interface{}{}}
+		handler.Serve(resp, &tcpws.Request{
+			Method: "GET",
+			Proto:  tcpws.ProtoHTTP,
+			Header: header,
+		})
+
+		var body tcpws.ErrorBody
+		_ = json.Unmarshal([]byte(resp.Body), &body)
+		return resp, body
+	}
+
+	signIn := func(userId int64, expiration time.Duration) string {
+		token, err := tokens.CreateToken(context.Background(), userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tokens.SaveToken(context.Background(), token, expiration); err != nil {
+			t.Fatal(err)
+		}
+
+		return "Bearer " + token.ID.String()
+	}
+
+	t.Run("check missing header", func(t *testing.T) {
+		resp, body := serve(nil)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "should be unauthorized")
+		assert.Equal(t, tcpws.ErrCodeUnauthorized, body.Code, "should be unauthorized code")
+		assert.Equal(t, (*entity.User)(nil), authorized, "should not serve request")
+	})
+
+	t.Run("check malformed header", func(t *testing.T) {
+		for _, header := range []interface{}{"", "Bearer ", "  ", 42} {
+			resp, body := serve(map[string]interface{}{HeaderAuthorization: header})
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "should be unauthorized")
+			assert.Equal(t, tcpws.ErrCodeUnauthorized, body.Code, "should be unauthorized code")
+		}
+		assert.Equal(t, (*entity.User)(nil), authorized, "should not serve request")
+	})
+
+	t.Run("check unknown token", func(t *testing.T) {
+		resp, body := serve(map[string]interface{}{HeaderAuthorization: "Bearer unknown"})
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "should be unauthorized")
+		assert.Equal(t, ErrCodeInvalidToken, body.Code, "should be invalid token code")
+		assert.Equal(t, (*entity.User)(nil), authorized, "should not serve request")
+	})
+
+	t.Run("check token of unknown user", func(t *testing.T) {
+		header := map[string]interface{}{HeaderAuthorization: signIn(2, time.Minute)}
+
+		resp, body := serve(header)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "should be unauthorized")
+		assert.Equal(t, ErrCodeInvalidToken, body.Code, "should be invalid token code")
+	})
+
+	t.Run("check expired token", func(t *testing.T) {
+		header := map[string]interface{}{HeaderAuthorization: signIn(user.ID, time.Minute)}
+
+		resp, _ := serve(header)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "should serve request before expiration")
+
+		mr.FastForward(2 * time.Minute)
+
+		resp, body := serve(header)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "should be unauthorized")
+		assert.Equal(t, ErrCodeInvalidToken, body.Code, "should be invalid token code")
+		assert.Equal(t, (*entity.User)(nil), authorized, "should not serve request")
+	})
+
+	t.Run("check valid token", func(t *testing.T) {
+		resp, _ := serve(map[string]interface{}{HeaderAuthorization: signIn(user.ID, time.Minute)})
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "should serve request")
+		assert.Equal(t, user, authorized, "should put user to the context")
+	})
+}
+
+func TestKeyByUser(t *testing.T) {
+	t.Run("check request without user", func(t *testing.T) {
+		key := KeyByUser(&tcpws.Response{}, &tcpws.Request{})
+		assert.Equal(t, "", key, "should be empty key")
+	})
+
+	t.Run("check request of user", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), UserKey, &entity.User{ID: 42})
+		key := KeyByUser(&tcpws.Response{}, (&tcpws.Request{}).WithContext(ctx))
+		assert.Equal(t, "42", key, "should be id of the user")
+	})
+
+	t.Run("check context without user", func(t *testing.T) {
+		_, ok := UserFromCtx(context.Background())
+		assert.Equal(t, false, ok, "should not be user")
+	})
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
+	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
+)
+
+// /api/v1/conversations/unread
+func (api *Api) GetUnreadCounters(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.unread.GetUnreadCounters"
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	counters, err := api.app.UnreadService.GetUnreadCounters(req.Ctx(), user.ID)
+	if err != nil {
+		api.internalError(resp, req, op, err)
+		return
+	}
+
+	api.writeJSON(resp, req, op, http.StatusOK, counters)
+}
+
+// /api/v1/conversations/{id}/last_message
+func (api *Api) GetLastMessage(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.unread.GetLastMessage"
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	convId, err := req.ParamInt64("id")
+	if err != nil {
+		api.invalidParam(resp, err)
+		return
+	}
+
+	err = api.app.ConversationService.CheckMember(req.Ctx(), convId, user.ID)
+	if err != nil {
+		api.conversationError(resp, req, op, err)
+		return
+	}
+
+	msg, err := api.app.UnreadService.GetLastMessage(req.Ctx(), convId)
+	if err != nil {
+		switch {
+		case errors.Is(err, repo.ErrMessageNotFound):
+			resp.Error(http.StatusNotFound, ErrCodeMessageNotFound, err.Error(), nil)
+		default:
+			api.internalError(resp, req, op, err)
+		}
+		return
+	}
+
+	api.writeJSON(resp, req, op, http.StatusOK, msg)
+}
+
+// /api/v1/conversations/{id}/read
+func (api *Api) MarkConversationRead(resp *tcpws.Response, req *tcpws.Request) {
+	const op = "gochat.app.api.unread.MarkConversationRead"
+
+	user, ok := api.authUser(resp, req)
+	if !ok {
+		return
+	}
+
+	convId, err := req.ParamInt64("id")
+	if err != nil {
+		api.invalidParam(resp, err)
+		return
+	}
+
+	err = api.app.ConversationService.CheckMember(req.Ctx(), convId, user.ID)
+	if err != nil {
+		api.conversationError(resp, req, op, err)
+		return
+	}
+
+	if err := api.app.UnreadService.MarkRead(req.Ctx(), convId, user.ID); err != nil {
+		api.internalError(resp, req, op, err)
+		return
+	}
+
+	resp.StatusCode = http.StatusNoContent
+	resp.Status = http.StatusText(http.StatusNoContent)
+}
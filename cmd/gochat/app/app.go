@@ -1,12 +1,17 @@
 package app
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"io"
 	"log/slog"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/api"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/config"
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/core"
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/storage"
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/storage/postgres"
@@ -14,6 +19,7 @@ import (
 	"github.com/sazonovItas/gochat-tcp/internal/logger/sl"
 	"github.com/sazonovItas/gochat-tcp/internal/middleware"
 	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
+	"github.com/sazonovItas/gochat-tcp/pkg/ratelimit"
 )
 
 type Application struct {
@@ -22,6 +28,10 @@ type Application struct {
 
 	listenAddr string
 	mux        *tcpws.MuxHandler
+	server     *tcpws.Server
+
+	shutdownTimeout time.Duration
+	tls             config.TLS
 
 	storage      *storage.Storage
 	cacheStorage *redis.Client
@@ -57,54 +67,176 @@ func InitApp(cfg *Config) (*Application, error) {
 	// init core
 	app.Core = core.New(db, cache, app.Logger)
 
+	// init rate limiters
+	app.RequestLimiter = newLimiter(&cfg.RateLimit, cache, "rate_limit:request",
+		ratelimit.PerMinute(cfg.RateLimit.Requests, cfg.RateLimit.RequestsBurst))
+	app.UserRequestLimiter = newLimiter(&cfg.RateLimit, cache, "rate_limit:user_request",
+		ratelimit.PerMinute(cfg.RateLimit.UserRequests, cfg.RateLimit.UserRequestsBurst))
+	app.MessageLimiter = newLimiter(&cfg.RateLimit, cache, "rate_limit:message",
+		ratelimit.PerMinute(cfg.RateLimit.Messages, cfg.RateLimit.MessagesBurst))
+
 	// setup server address and mux handler routes
 	app.listenAddr = cfg.TCPServer.Addr
 	app.mux = tcpws.NewMuxHandler()
+	if cfg.TCPServer.KeepAlive {
+		app.mux.KeepAlive(cfg.TCPServer.IdleTimeout)
+		app.mux.Pipeline(cfg.TCPServer.MaxPipeline)
+	}
 
 	// setup middlewares for mux handler
 	app.mux.Use(middleware.RequestId())
+	app.mux.Use(middleware.Recoverer(app.Logger))
 	app.mux.Use(middleware.Logger(app.Logger))
+	app.mux.Use(middleware.RateLimit(
+		app.RequestLimiter,
+		middleware.Keys(middleware.KeyByRemoteAddr, middleware.KeyByRoute),
+	))
 	app.mux.Use(middleware.Timeout(cfg.TCPServer.Timeout))
 
 	// init routes for app
 	InitRoutes(app.mux, app.Core)
 
+	app.server = tcpws.NewServer(app.listenAddr, app.mux)
+	app.shutdownTimeout = cfg.TCPServer.ShutdownTimeout
+
+	// setup tls and client certificates verification
+	app.tls = cfg.TCPServer.TLS
+	if app.tls.Enabled {
+		app.server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+
+		if app.tls.ClientCAFile != "" {
+			clientCAs, err := tcpws.LoadClientCAs(app.tls.ClientCAFile)
+			if err != nil {
+				return nil, err
+			}
+
+			app.server.TLSConfig.ClientCAs = clientCAs
+			app.server.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
 	return &app, nil
 }
 
 func (app *Application) Run() error {
 	defer func() {
+		if err := app.Core.Close(); err != nil {
+			app.Logger.Error("close core", "error", err.Error())
+		}
 		app.storage.Close()
 		app.cacheStorage.Close()
 
 		app.Logger.Info("server stopped")
 	}()
 
-	app.Logger.Info("server start running", "address", app.listenAddr)
-	return tcpws.ListenAndServe(app.listenAddr, app.mux)
+	var err error
+	if app.tls.Enabled {
+		app.Logger.Info("server start running with tls", "address", app.listenAddr)
+		err = app.server.ListenAndServeTLS(app.tls.CertFile, app.tls.KeyFile)
+	} else {
+		app.Logger.Info("server start running", "address", app.listenAddr)
+		err = app.server.ListenAndServe()
+	}
+	if errors.Is(err, tcpws.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Shutdown gracefully shuts down the server, waiting for serving
+// connections until shutdown timeout is expired
+func (app *Application) Shutdown(ctx context.Context) error {
+	app.Logger.Info("server shutting down", "timeout", app.shutdownTimeout.String())
+
+	ctx, cancel := context.WithTimeout(ctx, app.shutdownTimeout)
+	defer cancel()
+
+	return app.server.Shutdown(ctx)
 }
 
 func InitRoutes(mux *tcpws.MuxHandler, core *core.Core) *tcpws.MuxHandler {
 	handlers := api.NewApi(core)
 
+	v1 := mux.Group("/api/v1")
+
+	// health handler
+	v1.HandleFunc("GET", "/health", handlers.Health)
+
 	// auths handlers
-	mux.HandleFunc("POST", "/api/v1/signup", handlers.SignUp)
-	mux.HandleFunc("POST", "/api/v1/signin", handlers.SignIn)
-	mux.HandleFunc("POST", "/api/v1/signin/token", handlers.SignInByToken)
+	v1.HandleFunc("POST", "/signup", handlers.SignUp)
+	v1.HandleFunc("POST", "/signin", handlers.SignIn)
+	v1.HandleFunc("POST", "/signin/token", handlers.SignInByToken)
+
+	// handlers that require authorization token
+	authorized := v1.Group(
+		"/",
+		handlers.Auth(),
+		middleware.RateLimit(
+			core.UserRequestLimiter,
+			middleware.Keys(api.KeyByUser, middleware.KeyByRoute),
+		),
+	)
+
+	// stats handler
+	authorized.HandleFunc("GET", "/stats", handlers.Stats)
 
 	// chatting handler
-	mux.HandleFunc(tcpws.ProtoWS, "/api/v1/chatting", handlers.Chatting)
+	authorized.HandleFunc(tcpws.ProtoWS, "/chatting", handlers.Chatting)
 
 	// messages handler
-	mux.HandleFunc("GET", "/api/v1/messages", handlers.GetMessagesPrevTimestamp)
+	authorized.HandleFunc("GET", "/messages", handlers.GetMessagesPrevTimestamp)
+	authorized.HandleFunc("GET", "/messages/{id}/replies", handlers.GetThreadReplies)
+
+	// conversations handlers
+	authorized.HandleFunc("GET", "/conversations", handlers.GetConversations)
+	authorized.HandleFunc("GET", "/conversations/{id}", handlers.GetConversationById)
+	authorized.HandleFunc("POST", "/conversations", handlers.CreateGroupConversation)
+	authorized.HandleFunc("POST", "/conversations/direct", handlers.CreateDirectConversation)
+	authorized.HandleFunc("GET", "/conversations/invites", handlers.GetConversationInvites)
+	authorized.HandleFunc("GET", "/conversations/unread", handlers.GetUnreadCounters)
+	authorized.HandleFunc("GET", "/conversations/{id}/last_message", handlers.GetLastMessage)
+	authorized.HandleFunc("POST", "/conversations/{id}/read", handlers.MarkConversationRead)
+	authorized.HandleFunc("GET", "/conversations/{id}/members", handlers.GetConversationMembers)
+	authorized.HandleFunc("POST", "/conversations/{id}/members", handlers.AddConversationMembers)
+	authorized.HandleFunc(
+		"DELETE",
+		"/conversations/{id}/members/{user_id}",
+		handlers.RemoveConversationMember,
+	)
+	authorized.HandleFunc("POST", "/conversations/{id}/leave", handlers.LeaveConversation)
+	authorized.HandleFunc("POST", "/conversations/{id}/invites", handlers.InviteToConversation)
+	authorized.HandleFunc(
+		"POST",
+		"/conversations/{id}/invites/accept",
+		handlers.AcceptConversationInvite,
+	)
 
 	// user handler
-	mux.HandleFunc("GET", "/api/v1/member/{id}", handlers.GetChatMemberById)
-	mux.HandleFunc("GET", "/api/v1/member", handlers.GetChatMembers)
+	authorized.HandleFunc("GET", "/member/{id}", handlers.GetChatMemberById)
+	authorized.HandleFunc("GET", "/member", handlers.GetChatMembers)
 
 	return mux
 }
 
+// newLimiter creates rate limiter with buckets in the store specified by config
+func newLimiter(
+	cfg *config.RateLimit,
+	client *redis.Client,
+	keyPrefix string,
+	limit ratelimit.Limit,
+) ratelimit.Limiter {
+	if cfg.Store == "memory" {
+		return ratelimit.NewMemoryLimiter(limit)
+	}
+
+	return ratelimit.NewRedisLimiter(&ratelimit.RedisOpts{
+		Client:    client,
+		KeyPrefix: keyPrefix,
+		Limit:     limit,
+	})
+}
+
 // Create new logger that is specified by env
 func NewLogger(env string, out io.Writer) *slog.Logger {
 	var opts sl.HandlerOptions
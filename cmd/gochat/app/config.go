@@ -18,6 +18,7 @@ type Config struct {
 	TCPServer    config.TCPServer
 	CacheStorage config.Redis
 	Storage      config.Storage
+	RateLimit    config.RateLimit
 
 	Options *Options
 }
@@ -40,10 +41,16 @@ func InitAppConfig(opts *Options) (*Config, error) {
 		return nil, fmt.Errorf("%s: error load redis config %w", op, err)
 	}
 
+	rateLimitCfg, err := utils.LoadCfgFromEnv[config.RateLimit]()
+	if err != nil {
+		return nil, fmt.Errorf("%s: error load rate limit config %w", op, err)
+	}
+
 	return &Config{
 		TCPServer:    *serverCfg,
 		Storage:      *storageCfg,
 		CacheStorage: *redisCfg,
+		RateLimit:    *rateLimitCfg,
 		Options:      opts,
 	}, nil
 }
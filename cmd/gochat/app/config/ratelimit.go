@@ -0,0 +1,18 @@
+package config
+
+type RateLimit struct {
+	// Store is storage of the buckets: "redis" or "memory"
+	Store string `yaml:"store" env:"RATE_LIMIT_STORE" env-default:"redis"`
+
+	// Requests per minute by client address and route
+	Requests      int `yaml:"requests"       env:"RATE_LIMIT_REQUESTS"       env-default:"120"`
+	RequestsBurst int `yaml:"requests_burst" env:"RATE_LIMIT_REQUESTS_BURST" env-default:"30"`
+
+	// Requests per minute by user and route
+	UserRequests      int `yaml:"user_requests"       env:"RATE_LIMIT_USER_REQUESTS"       env-default:"60"`
+	UserRequestsBurst int `yaml:"user_requests_burst" env:"RATE_LIMIT_USER_REQUESTS_BURST" env-default:"20"`
+
+	// Chat messages per minute by user
+	Messages      int `yaml:"messages"       env:"RATE_LIMIT_MESSAGES"       env-default:"30"`
+	MessagesBurst int `yaml:"messages_burst" env:"RATE_LIMIT_MESSAGES_BURST" env-default:"10"`
+}
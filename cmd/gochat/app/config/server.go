@@ -5,4 +5,21 @@ import "time"
 type TCPServer struct {
 	Addr    string        `yaml:"addr"    env:"SERVER_ADDR"`
 	Timeout time.Duration `yaml:"timeout" env:"SERVER_TIMEOUT"`
+
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" env-default:"10s"`
+
+	KeepAlive   bool          `yaml:"keep_alive"   env:"SERVER_KEEP_ALIVE"`
+	IdleTimeout time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+	MaxPipeline int           `yaml:"max_pipeline" env:"SERVER_MAX_PIPELINE"`
+
+	TLS TLS `yaml:"tls"`
+}
+
+type TLS struct {
+	Enabled  bool   `yaml:"enabled"   env:"SERVER_TLS_ENABLED"`
+	CertFile string `yaml:"cert_file" env:"SERVER_TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file"  env:"SERVER_TLS_KEY_FILE"`
+
+	// ClientCAFile enables verification of client certificates (mTLS)
+	ClientCAFile string `yaml:"client_ca_file" env:"SERVER_TLS_CLIENT_CA_FILE"`
 }
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"context"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -11,6 +13,9 @@ import (
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/service"
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/storage"
 	"github.com/sazonovItas/gochat-tcp/pkg/cache"
+	"github.com/sazonovItas/gochat-tcp/pkg/es"
+	espostgres "github.com/sazonovItas/gochat-tcp/pkg/es/postgres"
+	"github.com/sazonovItas/gochat-tcp/pkg/ratelimit"
 )
 
 type Core struct {
@@ -18,19 +23,92 @@ type Core struct {
 	Logger *slog.Logger
 
 	// Services that using by app
-	MessageService service.MessageService
-	UserService    service.UserService
-	AuthService    service.AuthService
-	EventService   service.EventService
+	MessageService      service.MessageService
+	ConversationService service.ConversationService
+	ReactionService     service.ReactionService
+	UserService         service.UserService
+	AuthService         service.AuthService
+	EventService        service.EventService
+	UnreadService       service.UnreadService
+
+	// Limiters of requests by client address, requests by user and chat messages by user
+	RequestLimiter     ratelimit.Limiter
+	UserRequestLimiter ratelimit.Limiter
+	MessageLimiter     ratelimit.Limiter
+
+	// Projector builds read models from the event store
+	Projector *es.Projector
+
+	// Outbox publishes events of the stored changes to the event bus
+	Outbox *service.OutboxDispatcher
+
+	eventBus       *service.RedisEventBus
+	stopBackground context.CancelFunc
+	background     sync.WaitGroup
 }
 
+// snapshotFrequency is count of events of the aggregate between snapshots
+const snapshotFrequency = 50
+
 func New(storage *storage.Storage, cacheStorage *redis.Client, lg *slog.Logger) *Core {
 	var core Core
 
 	core.Logger = lg
 
+	// init event store of message streams, events of stored messages are
+	// appended to the streams by the outbox dispatcher
+	streamRegistry := service.NewMessageStreamRegistry()
+	eventStore := espostgres.NewEventStore(storage.DB, "chat.events", streamRegistry)
+	streams := service.NewMessageStreams(es.NewSnapshotAggregateStore(
+		eventStore,
+		espostgres.NewSnapshotStore(storage.DB, "chat.snapshots", streamRegistry),
+		snapshotFrequency,
+	))
+
+	// init event service with bus shared between instances of the app,
+	// event types are registered after services they depend on
+	registry := service.NewEventRegistry()
+	core.eventBus = service.NewRedisEventBus(&service.RedisEventBusOpts{
+		Client:   cacheStorage,
+		Logger:   lg,
+		Registry: registry,
+	})
+	core.EventService = service.NewEventService(core.eventBus, registry)
+	core.Outbox = service.NewOutboxDispatcher(&service.OutboxDispatcherOpts{
+		Repository:   repo.NewOutboxRepository(storage),
+		Bus:          core.eventBus,
+		Registry:     registry,
+		Consumers:    []service.OutboxConsumer{streams},
+		Logger:       lg,
+		PollInterval: 100 * time.Millisecond,
+	})
+
 	// init message service
-	core.MessageService = service.NewMessageService(repo.NewMessageRepository(storage), nil)
+	core.MessageService = service.NewMessageService(
+		repo.NewMessageRepository(storage),
+		core.Outbox,
+		nil,
+	)
+
+	// init projections of message streams
+	unreadCounters := repo.NewUnreadCounterRepository(storage)
+	lastMessages := repo.NewLastMessageRepository(storage)
+	core.UnreadService = service.NewUnreadService(streams, unreadCounters, lastMessages)
+	checkpoints := espostgres.NewCheckpointStore(
+		storage.DB,
+		"chat.checkpoints",
+		"chat.dead_letters",
+	)
+	core.Projector = es.NewProjector(
+		&es.ProjectorOpts{
+			Events:      eventStore,
+			Checkpoints: checkpoints,
+			DeadLetters: checkpoints,
+			Logger:      lg,
+		},
+		service.NewUnreadCountersProjection(unreadCounters),
+		service.NewLastMessagesProjection(lastMessages),
+	)
 
 	// init user service
 	core.UserService = service.NewUserService(
@@ -41,6 +119,21 @@ func New(storage *storage.Storage, cacheStorage *redis.Client, lg *slog.Logger)
 			DefaultExpiration: time.Minute * 10,
 		})
 
+	// init conversation service
+	core.ConversationService = service.NewConversationService(
+		repo.NewConversationRepository(storage),
+		core.UserService,
+		core.Outbox,
+	)
+
+	// init reaction service
+	core.ReactionService = service.NewReactionService(
+		repo.NewReactionRepository(storage),
+		core.MessageService,
+		core.ConversationService,
+		core.Outbox,
+	)
+
 	// init auth service
 	tokenStorage := cache.NewCache[entity.Token](&cache.CacheOpts{
 		Client:            cacheStorage,
@@ -51,8 +144,69 @@ func New(storage *storage.Storage, cacheStorage *redis.Client, lg *slog.Logger)
 		repo.NewTokenRepository(tokenStorage, core.UserService),
 	)
 
-	// init event service
-	core.EventService = service.NewEventService()
+	// init event types
+	service.Register(
+		registry,
+		service.NewMessageEventType,
+		service.NewMessageEventDef(core.MessageService, core.ConversationService),
+	)
+	service.Register(
+		registry,
+		service.MessageEditedEventType,
+		service.MessageEditedEventDef(core.MessageService, core.ConversationService),
+	)
+	service.Register(
+		registry,
+		service.MessageDeletedEventType,
+		service.MessageDeletedEventDef(core.MessageService, core.ConversationService),
+	)
+	service.Register(
+		registry,
+		service.ReactionAddedEventType,
+		service.ReactionAddedEventDef(core.ReactionService),
+	)
+	service.Register(
+		registry,
+		service.ReactionRemovedEventType,
+		service.ReactionRemovedEventDef(core.ReactionService),
+	)
+	service.Register(
+		registry,
+		service.ConversationCreatedEventType,
+		service.EventDef[entity.ConversationCreatedEvent]{
+			Version: service.ConversationCreatedEventVersion,
+		},
+	)
+	service.Register(
+		registry,
+		service.ConversationMemberEventType,
+		service.EventDef[entity.ConversationMemberEvent]{
+			Version: service.ConversationMemberEventVersion,
+		},
+	)
+
+	// run outbox and projections in background until core is closed
+	var ctx context.Context
+	ctx, core.stopBackground = context.WithCancel(context.Background())
+	core.runBackground(func() { core.Outbox.Run(ctx) })
+	core.runBackground(func() { core.Projector.Run(ctx) })
 
 	return &core
 }
+
+// runBackground runs fn in goroutine that is waited on close
+func (core *Core) runBackground(fn func()) {
+	core.background.Add(1)
+	go func() {
+		defer core.background.Done()
+		fn()
+	}()
+}
+
+// Close releases resources of the services
+func (core *Core) Close() error {
+	core.stopBackground()
+	core.background.Wait()
+
+	return core.eventBus.Close()
+}
@@ -0,0 +1,55 @@
+package entity
+
+import "time"
+
+// ConversationKind represents conversation kind
+type ConversationKind int
+
+const (
+	// DirectConversation represents a conversation of two users
+	DirectConversation ConversationKind = 0
+	// GroupConversation represents a conversation of any count of users
+	GroupConversation ConversationKind = 1
+)
+
+type Conversation struct {
+	ID               int64            `db:"id"                json:"id"`
+	Title            string           `db:"title"             json:"title"`
+	Color            string           `db:"color"             json:"color"`
+	CreatorID        int64            `db:"creator_id"        json:"creator_id"`
+	ConversationKind ConversationKind `db:"conversation_kind" json:"conversation_kind"`
+	CreatedAt        time.Time        `db:"created_at"        json:"created_at"`
+}
+
+// ConversationCreatedEvent notifies members about the new conversation
+type ConversationCreatedEvent struct {
+	Conversation
+	MemberIDs []int64 `json:"member_ids"`
+}
+
+// ConversationInvite represents invitation of user to the conversation
+type ConversationInvite struct {
+	ConversationID int64     `db:"conversation_id" json:"conversation_id"`
+	UserID         int64     `db:"user_id"         json:"user_id"`
+	InviterID      int64     `db:"inviter_id"      json:"inviter_id"`
+	CreatedAt      time.Time `db:"created_at"      json:"created_at"`
+}
+
+// MemberAction represents change of the conversation membership
+type MemberAction string
+
+const (
+	MemberAdded   MemberAction = "added"
+	MemberRemoved MemberAction = "removed"
+	MemberLeft    MemberAction = "left"
+	MemberInvited MemberAction = "invited"
+	MemberJoined  MemberAction = "joined"
+)
+
+// ConversationMemberEvent notifies members and affected users about change of membership
+type ConversationMemberEvent struct {
+	ConversationID int64        `json:"conversation_id"`
+	Action         MemberAction `json:"action"`
+	ActorID        int64        `json:"actor_id"`
+	UserIDs        []int64      `json:"user_ids"`
+}
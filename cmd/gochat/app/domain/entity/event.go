@@ -1,6 +1,7 @@
 package entity
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -8,21 +9,97 @@ import (
 
 type Event struct {
 	ID        uuid.UUID
+	Seq       uint64
 	Type      string
+	Version   int
 	Timestamp time.Time
 	Payload   interface{}
 }
 
 type PublicEvent struct {
+	Seq     uint64      `json:"seq,omitempty"`
 	Type    string      `json:"type"`
+	Version int         `json:"version,omitempty"`
 	Payload interface{} `json:"payload"`
 }
 
+// OutboxEvent is event stored in the outbox with the changes it describes,
+// it is published after the changes are committed
+type OutboxEvent struct {
+	ID          uuid.UUID  `db:"id"`
+	EventType   string     `db:"event_type"`
+	Version     int        `db:"event_version"`
+	Payload     []byte     `db:"payload"`
+	CreatedAt   time.Time  `db:"created_at"`
+	PublishedAt *time.Time `db:"published_at"`
+}
+
+// RawEvent is public event received from client with payload not decoded yet,
+// version of the payload is the first version if it is not set
+type RawEvent struct {
+	Type    string          `json:"type"`
+	Version int             `json:"version,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+}
+
 type NewMessageEvent struct {
-	ID          string      `json:"id"`
-	SenderID    int64       `json:"sender_id"`
-	MessageKind MessageKind `json:"message_kind"`
-	Message     string      `json:"message"`
-	CreatedAt   time.Time   `json:"created_at"`
-	UpdateAt    time.Time   `json:"updated_at"`
+	ID             string      `json:"id"`
+	ClientID       string      `json:"client_id,omitempty"`
+	ConversationID int64       `json:"conversation_id"`
+	SenderID       int64       `json:"sender_id"`
+	MessageKind    MessageKind `json:"message_kind"`
+	Message        string      `json:"message"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdateAt       time.Time   `json:"updated_at"`
+
+	// ReplyTo is id of the message the message replies to if it is reply
+	ReplyTo string `json:"reply_to,omitempty"`
+
+	// ThreadID is id of the root message of the thread if message is reply,
+	// so clients could count new replies of the thread without loading it
+	ThreadID string `json:"thread_id,omitempty"`
+}
+
+// MessageEditedEvent is event of editing text of the message by its sender
+type MessageEditedEvent struct {
+	ID             string    `json:"id"`
+	ClientID       string    `json:"client_id,omitempty"`
+	ConversationID int64     `json:"conversation_id"`
+	SenderID       int64     `json:"sender_id"`
+	Message        string    `json:"message"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// MessageDeletedEvent is event of deleting the message by its sender
+type MessageDeletedEvent struct {
+	ID             string    `json:"id"`
+	ClientID       string    `json:"client_id,omitempty"`
+	ConversationID int64     `json:"conversation_id"`
+	SenderID       int64     `json:"sender_id"`
+	DeletedAt      time.Time `json:"deleted_at"`
+}
+
+// MessageAckEvent confirms to the sender that message with client id is stored
+type MessageAckEvent struct {
+	ClientID  string    `json:"client_id"`
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CloseEvent struct {
+	Reason string `json:"reason"`
+}
+
+type ErrorEvent struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+
+	// ClientID is client id of the rejected message if it is set
+	ClientID string `json:"client_id,omitempty"`
+}
+
+type HistoryGapEvent struct {
+	LastSeenSeq uint64 `json:"last_seen_seq"`
+	Reason      string `json:"reason"`
 }
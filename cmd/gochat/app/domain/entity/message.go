@@ -16,12 +16,105 @@ const (
 	AddingUserMessage MessageKind = 1
 	// UserTextMessage represents a text message from user
 	UserTextMessage MessageKind = 2
+	// RemovingUserMessage represents a message of removing people from conversation
+	RemovingUserMessage MessageKind = 3
+	// LeavingUserMessage represents a message of leaving conversation by user
+	LeavingUserMessage MessageKind = 4
+	// InvitingUserMessage represents a message of inviting people to conversation
+	InvitingUserMessage MessageKind = 5
 )
 
+// IsValid reports whether message kind is known
+func (k MessageKind) IsValid() bool {
+	return k >= CreateConversationMessage && k <= InvitingUserMessage
+}
+
+// IsSystem reports whether message is created by the chat and not by user
+func (k MessageKind) IsSystem() bool {
+	return k != UserTextMessage
+}
+
 type Message struct {
-	ID          uuid.UUID   `db:"id"           json:"id"`
-	SenderID    int64       `db:"sender_id"    json:"sender_id"`
-	MessageKind MessageKind `db:"message_kind" json:"message_kind"`
-	Message     string      `db:"message"      json:"message"`
-	CreatedAt   time.Time   `db:"created_at"   json:"created_at"`
+	ID             uuid.UUID   `db:"id"              json:"id"`
+	ConversationID int64       `db:"conversation_id" json:"conversation_id"`
+	SenderID       int64       `db:"sender_id"       json:"sender_id"`
+	MessageKind    MessageKind `db:"message_kind"    json:"message_kind"`
+	Message        string      `db:"message"         json:"message"`
+	CreatedAt      time.Time   `db:"created_at"      json:"created_at"`
+
+	// ClientID is optional id of the message generated by client of the sender,
+	// so retries of sending create the message only once
+	ClientID string `db:"client_id" json:"client_id,omitempty"`
+
+	// UpdatedAt is time of the last edit of the message, it is creation time
+	// if the message is not edited
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+
+	// DeletedAt is time of deleting the message, deleted message is tombstone
+	// with empty text
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+
+	// ReplyTo is optional id of the message the message replies to
+	ReplyTo *uuid.UUID `db:"reply_to" json:"reply_to,omitempty"`
+
+	// ThreadID is id of the root message of the thread of the reply,
+	// it is not set for messages that are not replies
+	ThreadID *uuid.UUID `db:"thread_id" json:"thread_id,omitempty"`
+
+	// ReplyCount is count of replies in the thread of the root message
+	ReplyCount int `db:"reply_count" json:"reply_count"`
+
+	// LastReplyAt is creation time of the last reply in the thread of the root message
+	LastReplyAt *time.Time `db:"last_reply_at" json:"last_reply_at,omitempty"`
+
+	// Reactions are reactions on the message aggregated by emoji,
+	// they are set only for messages of history
+	Reactions []ReactionSummary `db:"-" json:"reactions,omitempty"`
+}
+
+// IsDeleted reports whether message is deleted
+func (m *Message) IsDeleted() bool {
+	return m.DeletedAt != nil
+}
+
+// DefaultConversationID is id of the common conversation of all users
+const DefaultConversationID int64 = 0
+
+// MessageCreated is payload of the stored event of created message
+type MessageCreated struct {
+	Message
+}
+
+// MessageEdited is payload of the stored event of edited message
+type MessageEdited struct {
+	ID             uuid.UUID `json:"id"`
+	ConversationID int64     `json:"conversation_id"`
+	Message        string    `json:"message"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// MessageDeleted is payload of the stored event of deleted message
+type MessageDeleted struct {
+	ID             uuid.UUID `json:"id"`
+	ConversationID int64     `json:"conversation_id"`
+	DeletedAt      time.Time `json:"deleted_at"`
+}
+
+// ConversationRead is payload of the stored event of reading conversation by user
+type ConversationRead struct {
+	ConversationID int64     `json:"conversation_id"`
+	UserID         int64     `json:"user_id"`
+	ReadAt         time.Time `json:"read_at"`
+}
+
+// UnreadCounter represents count of unread messages of the conversation by user
+type UnreadCounter struct {
+	ConversationID int64 `db:"conversation_id" json:"conversation_id"`
+	UserID         int64 `db:"user_id"         json:"user_id"`
+	UnreadCount    int   `db:"unread_count"    json:"unread_count"`
+}
+
+// LastMessage represents the last message of the conversation
+type LastMessage struct {
+	Message
 }
@@ -0,0 +1,32 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Reaction represents emoji reaction of user on the message
+type Reaction struct {
+	MessageID uuid.UUID `db:"message_id" json:"message_id"`
+	UserID    int64     `db:"user_id"    json:"user_id"`
+	Emoji     string    `db:"emoji"      json:"emoji"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// ReactionSummary represents aggregated reactions with the same emoji on the message,
+// users are ordered by time of reacting
+type ReactionSummary struct {
+	Emoji   string  `json:"emoji"`
+	Count   int     `json:"count"`
+	UserIDs []int64 `json:"user_ids"`
+}
+
+// MessageReactionEvent is event of adding or removing reaction on the message by user
+type MessageReactionEvent struct {
+	MessageID      string `json:"message_id"`
+	ClientID       string `json:"client_id,omitempty"`
+	ConversationID int64  `json:"conversation_id"`
+	UserID         int64  `json:"user_id"`
+	Emoji          string `json:"emoji"`
+}
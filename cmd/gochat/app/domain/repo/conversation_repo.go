@@ -0,0 +1,690 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/storage"
+)
+
+type ConversationRepository interface {
+	// NextId reserves id of the new conversation
+	// Errors: unknown
+	NextId(ctx context.Context) (int64, error)
+
+	// Create creates new conversation with members, id of the conversation
+	// should be reserved by NextId, system message of the creating if it is not nil
+	// and events are added in the same transaction, only one direct conversation
+	// of two users could be created
+	// Errors: ErrConversationExists, ErrUserNotFound, unknown
+	Create(
+		ctx context.Context,
+		conv *entity.Conversation,
+		memberIds []int64,
+		msg *entity.Message,
+		events ...*entity.OutboxEvent,
+	) error
+
+	// FindById finds conversation by id
+	// Errors: ErrConversationNotFound, unknown
+	FindById(ctx context.Context, id int64) (*entity.Conversation, error)
+
+	// FindDirect finds direct conversation of two users
+	// Errors: ErrConversationNotFound, unknown
+	FindDirect(ctx context.Context, userId, peerId int64) (*entity.Conversation, error)
+
+	// FindByMember returns conversations of the member ordered by creation
+	// Errors: unknown
+	FindByMember(ctx context.Context, userId int64) ([]entity.Conversation, error)
+
+	// IsMember reports whether user is member of the conversation
+	// Errors: unknown
+	IsMember(ctx context.Context, conversationId, userId int64) (bool, error)
+
+	// GetMemberIds returns ids of members of the conversation
+	// Errors: unknown
+	GetMemberIds(ctx context.Context, conversationId int64) ([]int64, error)
+
+	// AddMembers adds members to the conversation if count of its members does not
+	// exceed maxMembers, system message of the adding if it is not nil and events
+	// are added in the same transaction
+	// Errors: ErrTooManyMembers, ErrConversationNotFound, ErrUserNotFound, unknown
+	AddMembers(
+		ctx context.Context,
+		conversationId int64,
+		memberIds []int64,
+		maxMembers int,
+		msg *entity.Message,
+		events ...*entity.OutboxEvent,
+	) error
+
+	// RemoveMember removes member from the conversation, system message of the
+	// removing if it is not nil and events are added in the same transaction
+	// Errors: ErrMemberNotFound, unknown
+	RemoveMember(
+		ctx context.Context,
+		conversationId, userId int64,
+		msg *entity.Message,
+		events ...*entity.OutboxEvent,
+	) error
+
+	// AddInvite adds invite of the user to the conversation, system message of the
+	// inviting if it is not nil and events are added in the same transaction
+	// Errors: ErrInviteExists, ErrUserNotFound, unknown
+	AddInvite(
+		ctx context.Context,
+		invite *entity.ConversationInvite,
+		msg *entity.Message,
+		events ...*entity.OutboxEvent,
+	) error
+
+	// AcceptInvite deletes invite of the user and adds the user to members of the
+	// conversation if count of its members does not exceed maxMembers, system message
+	// of the joining if it is not nil and events are added in the same transaction
+	// Errors: ErrInviteNotFound, ErrTooManyMembers, ErrConversationNotFound, unknown
+	AcceptInvite(
+		ctx context.Context,
+		conversationId, userId int64,
+		maxMembers int,
+		msg *entity.Message,
+		events ...*entity.OutboxEvent,
+	) error
+
+	// FindInvites returns invites of the user ordered by creation
+	// Errors: unknown
+	FindInvites(ctx context.Context, userId int64) ([]entity.ConversationInvite, error)
+}
+
+type conversationRepository struct {
+	storage *storage.Storage
+}
+
+func NewConversationRepository(db *storage.Storage) ConversationRepository {
+	return &conversationRepository{storage: db}
+}
+
+var (
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrConversationExists   = errors.New("conversation already exists")
+	ErrMemberNotFound       = errors.New("conversation member not found")
+	ErrInviteNotFound       = errors.New("conversation invite not found")
+	ErrInviteExists         = errors.New("conversation invite already exists")
+	ErrTooManyMembers       = errors.New("too many members of the conversation")
+)
+
+// Postgres error codes of constraint violations
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// isViolation reports whether err is postgres error of the constraint violation
+func isViolation(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
+
+// directKey returns key of direct conversation that is the same for both users
+func directKey(userId, peerId int64) string {
+	return fmt.Sprintf("%d:%d", min(userId, peerId), max(userId, peerId))
+}
+
+// NextId is implementing interface ConversationRepository
+func (cr *conversationRepository) NextId(ctx context.Context) (int64, error) {
+	const op = "gochat.internal.domain.infastructure.datastore.conversation.NextId"
+
+	var id int64
+	err := cr.storage.GetContext(ctx, &id, "SELECT nextval('chat.conversations_id_seq')")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// Create is implementing interface ConversationRepository
+func (cr *conversationRepository) Create(
+	ctx context.Context,
+	conv *entity.Conversation,
+	memberIds []int64,
+	msg *entity.Message,
+	events ...*entity.OutboxEvent,
+) (err error) {
+	const op = "gochat.internal.domain.infastructure.datastore.conversation.Create"
+
+	// only direct conversations have key
+	var key *string
+	if conv.ConversationKind == entity.DirectConversation && len(memberIds) == 2 {
+		k := directKey(memberIds[0], memberIds[1])
+		key = &k
+	}
+
+	tx, err := cr.storage.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(
+		ctx,
+		`
+    INSERT INTO chat.conversations
+    (id, title, color, creator_id, conversation_kind, direct_key, created_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `,
+		conv.ID,
+		conv.Title,
+		conv.Color,
+		conv.CreatorID,
+		conv.ConversationKind,
+		key,
+		conv.CreatedAt,
+	)
+	if err != nil {
+		if isViolation(err, uniqueViolation) {
+			return ErrConversationExists
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = addMembers(ctx, tx, conv.ID, memberIds); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = addSystemMessage(ctx, tx, msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = addOutboxEvents(ctx, tx, events...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// FindById is implementing interface ConversationRepository
+func (cr *conversationRepository) FindById(
+	ctx context.Context,
+	id int64,
+) (*entity.Conversation, error) {
+	const op = "gochat.internal.domain.infastructure.datastore.conversation.FindById"
+
+	var conv entity.Conversation
+	err := cr.storage.GetContext(
+		ctx,
+		&conv,
+		`
+    SELECT id, title, color, COALESCE(creator_id, 0) AS creator_id,
+      conversation_kind, created_at
+    FROM chat.conversations
+    WHERE id = $1
+    `,
+		id,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrConversationNotFound
+		default:
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return &conv, nil
+}
+
+// FindDirect is implementing interface ConversationRepository
+func (cr *conversationRepository) FindDirect(
+	ctx context.Context,
+	userId, peerId int64,
+) (*entity.Conversation, error) {
+	const op = "gochat.internal.domain.infastructure.datastore.conversation.FindDirect"
+
+	var conv entity.Conversation
+	err := cr.storage.GetContext(
+		ctx,
+		&conv,
+		`
+    SELECT id, title, color, COALESCE(creator_id, 0) AS creator_id,
+      conversation_kind, created_at
+    FROM chat.conversations
+    WHERE direct_key = $1
+    `,
+		directKey(userId, peerId),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrConversationNotFound
+		default:
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return &conv, nil
+}
+
+// FindByMember is implementing interface ConversationRepository
+func (cr *conversationRepository) FindByMember(
+	ctx context.Context,
+	userId int64,
+) ([]entity.Conversation, error) {
+	const op = "gochat.internal.domain.infastructure.datastore.conversation.FindByMember"
+
+	var convs []entity.Conversation
+	err := cr.storage.SelectContext(
+		ctx,
+		&convs,
+		`
+    SELECT c.id, c.title, c.color, COALESCE(c.creator_id, 0) AS creator_id,
+      c.conversation_kind, c.created_at
+    FROM chat.conversations c
+    JOIN chat.conversation_members m ON m.conversation_id = c.id
+    WHERE m.user_id = $1
+    ORDER BY c.created_at ASC
+    `,
+		userId,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return convs, nil
+}
+
+// IsMember is implementing interface ConversationRepository
+func (cr *conversationRepository) IsMember(
+	ctx context.Context,
+	conversationId, userId int64,
+) (bool, error) {
+	const op = "gochat.internal.domain.infastructure.datastore.conversation.IsMember"
+
+	var isMember bool
+	err := cr.storage.GetContext(
+		ctx,
+		&isMember,
+		`
+    SELECT EXISTS (
+      SELECT 1 FROM chat.conversation_members WHERE conversation_id = $1 AND user_id = $2
+    )
+    `,
+		conversationId,
+		userId,
+	)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return isMember, nil
+}
+
+// GetMemberIds is implementing interface ConversationRepository
+func (cr *conversationRepository) GetMemberIds(
+	ctx context.Context,
+	conversationId int64,
+) ([]int64, error) {
+	const op = "gochat.internal.domain.infastructure.datastore.conversation.GetMemberIds"
+
+	var memberIds []int64
+	err := cr.storage.SelectContext(
+		ctx,
+		&memberIds,
+		`
+    SELECT user_id FROM chat.conversation_members
+    WHERE conversation_id = $1
+    ORDER BY joined_at ASC
+    `,
+		conversationId,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return memberIds, nil
+}
+
+// addMembers adds members to the conversation in the transaction, members
+// that are already added are skipped
+// Errors: ErrUserNotFound, unknown
+func addMembers(ctx context.Context, tx *sqlx.Tx, conversationId int64, memberIds []int64) error {
+	for _, memberId := range memberIds {
+		_, err := tx.ExecContext(
+			ctx,
+			`
+      INSERT INTO chat.conversation_members (conversation_id, user_id)
+      VALUES ($1, $2)
+      ON CONFLICT DO NOTHING
+      `,
+			conversationId,
+			memberId,
+		)
+		if err != nil {
+			if isViolation(err, foreignKeyViolation) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addMembersLimited adds members to the conversation in the transaction, the
+// conversation is locked, so concurrent adding could not exceed maxMembers
+// Errors: ErrTooManyMembers, ErrConversationNotFound, ErrUserNotFound, unknown
+func addMembersLimited(
+	ctx context.Context,
+	tx *sqlx.Tx,
+	conversationId int64,
+	memberIds []int64,
+	maxMembers int,
+) error {
+	var id int64
+	err := tx.GetContext(
+		ctx,
+		&id,
+		"SELECT id FROM chat.conversations WHERE id = $1 FOR UPDATE",
+		conversationId,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrConversationNotFound
+		}
+		return err
+	}
+
+	if err := addMembers(ctx, tx, conversationId, memberIds); err != nil {
+		return err
+	}
+
+	var count int
+	err = tx.GetContext(
+		ctx,
+		&count,
+		"SELECT COUNT(*) FROM chat.conversation_members WHERE conversation_id = $1",
+		conversationId,
+	)
+	if err != nil {
+		return err
+	}
+
+	if count > maxMembers {
+		return ErrTooManyMembers
+	}
+
+	return nil
+}
+
+// addSystemMessage adds system message of the membership change in the transaction
+// if it is not nil
+// Errors: ErrMessageCreateFailed, unknown
+func addSystemMessage(ctx context.Context, tx *sqlx.Tx, msg *entity.Message) error {
+	if msg == nil {
+		return nil
+	}
+
+	return insertMessage(ctx, tx, msg)
+}
+
+// AddMembers is implementing interface ConversationRepository
+func (cr *conversationRepository) AddMembers(
+	ctx context.Context,
+	conversationId int64,
+	memberIds []int64,
+	maxMembers int,
+	msg *entity.Message,
+	events ...*entity.OutboxEvent,
+) (err error) {
+	const op = "gochat.internal.domain.infastructure.datastore.conversation.AddMembers"
+
+	tx, err := cr.storage.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	err = addMembersLimited(ctx, tx, conversationId, memberIds, maxMembers)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTooManyMembers),
+			errors.Is(err, ErrConversationNotFound),
+			errors.Is(err, ErrUserNotFound):
+			return err
+		default:
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = addSystemMessage(ctx, tx, msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = addOutboxEvents(ctx, tx, events...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// RemoveMember is implementing interface ConversationRepository
+func (cr *conversationRepository) RemoveMember(
+	ctx context.Context,
+	conversationId, userId int64,
+	msg *entity.Message,
+	events ...*entity.OutboxEvent,
+) (err error) {
+	const op = "gochat.internal.domain.infastructure.datastore.conversation.RemoveMember"
+
+	tx, err := cr.storage.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM chat.conversation_members WHERE conversation_id = $1 AND user_id = $2",
+		conversationId,
+		userId,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if res != 1 {
+		err = ErrMemberNotFound
+		return err
+	}
+
+	if err = addSystemMessage(ctx, tx, msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = addOutboxEvents(ctx, tx, events...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AddInvite is implementing interface ConversationRepository
+func (cr *conversationRepository) AddInvite(
+	ctx context.Context,
+	invite *entity.ConversationInvite,
+	msg *entity.Message,
+	events ...*entity.OutboxEvent,
+) (err error) {
+	const op = "gochat.internal.domain.infastructure.datastore.conversation.AddInvite"
+
+	tx, err := cr.storage.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.NamedExecContext(
+		ctx,
+		`
+    INSERT INTO chat.conversation_invites (conversation_id, user_id, inviter_id, created_at)
+    VALUES (:conversation_id, :user_id, :inviter_id, :created_at)
+    `,
+		invite,
+	)
+	if err != nil {
+		switch {
+		case isViolation(err, uniqueViolation):
+			return ErrInviteExists
+		case isViolation(err, foreignKeyViolation):
+			return ErrUserNotFound
+		default:
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = addSystemMessage(ctx, tx, msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = addOutboxEvents(ctx, tx, events...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AcceptInvite is implementing interface ConversationRepository
+func (cr *conversationRepository) AcceptInvite(
+	ctx context.Context,
+	conversationId, userId int64,
+	maxMembers int,
+	msg *entity.Message,
+	events ...*entity.OutboxEvent,
+) (err error) {
+	const op = "gochat.internal.domain.infastructure.datastore.conversation.AcceptInvite"
+
+	tx, err := cr.storage.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM chat.conversation_invites WHERE conversation_id = $1 AND user_id = $2",
+		conversationId,
+		userId,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if res != 1 {
+		err = ErrInviteNotFound
+		return err
+	}
+
+	err = addMembersLimited(ctx, tx, conversationId, []int64{userId}, maxMembers)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrTooManyMembers), errors.Is(err, ErrConversationNotFound):
+			return err
+		default:
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = addSystemMessage(ctx, tx, msg); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = addOutboxEvents(ctx, tx, events...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// FindInvites is implementing interface ConversationRepository
+func (cr *conversationRepository) FindInvites(
+	ctx context.Context,
+	userId int64,
+) ([]entity.ConversationInvite, error) {
+	const op = "gochat.internal.domain.infastructure.datastore.conversation.FindInvites"
+
+	var invites []entity.ConversationInvite
+	err := cr.storage.SelectContext(
+		ctx,
+		&invites,
+		`
+    SELECT conversation_id, user_id, inviter_id, created_at
+    FROM chat.conversation_invites
+    WHERE user_id = $1
+    ORDER BY created_at ASC
+    `,
+		userId,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return invites, nil
+}
@@ -8,50 +8,84 @@ import (
 	"time"
 
 	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
 
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/storage"
 )
 
 type MessageRepository interface {
-	// Create creates new message and returns it's id
-	// Errors: ErrGenerateUUIDFailed, ErrMessageCreateFailed, unknown
-	Create(ctx context.Context, msg *entity.Message) (uuid.UUID, error)
+	// Create creates new message and returns it's id, id is generated if it is not set,
+	// events are added to the outbox in the same transaction, message is not created
+	// if sender already has message with the same client id, reply count and time of
+	// the last reply of the thread root are updated if message is reply
+	// Errors: ErrGenerateUUIDFailed, ErrMessageCreateFailed, ErrMessageDuplicate, unknown
+	Create(
+		ctx context.Context,
+		msg *entity.Message,
+		events ...*entity.OutboxEvent,
+	) (uuid.UUID, error)
 
 	// FindById finds message by id
 	// Errors: ErrMessageNotFound, unknown
 	FindById(ctx context.Context, id uuid.UUID) (*entity.Message, error)
 
-	// Update updates message by id
+	// FindByClientId finds message of the sender by client id
+	// Errors: ErrMessageNotFound, unknown
+	FindByClientId(ctx context.Context, senderId int64, clientId string) (*entity.Message, error)
+
+	// Update updates text and update time of the message by id, deleted message
+	// is not updated, events are added to the outbox in the same transaction
 	// Errors: ErrMessageUpdateFailed, unknown
-	Update(ctx context.Context, message *entity.Message) error
+	Update(ctx context.Context, message *entity.Message, events ...*entity.OutboxEvent) error
 
-	// Delete deletes message by id
+	// Delete marks message as deleted by id and clears its text and reactions, so tombstone
+	// of the message is kept in history, events are added to the outbox in the same transaction
 	// Errors: ErrMessageDeleteFailed, unknown
-	Delete(ctx context.Context, id uuid.UUID) error
+	Delete(
+		ctx context.Context,
+		id uuid.UUID,
+		deletedAt time.Time,
+		events ...*entity.OutboxEvent,
+	) error
 
-	// GetConvMessagesPrevTimestamp returns limits count of messages previous to timestamp
+	// GetConvMessagesPrevTimestamp returns limits count of messages of the conversation
+	// previous to timestamp
 	// Errors: ErrNoMessages, unknown
 	GetConvMessagesPrevTimestamp(
 		ctx context.Context,
+		conversationId int64,
 		timestamp time.Time,
 		limit int,
 	) ([]entity.Message, error)
 
-	// GetConvMessagesNextTimestamp returns limits count of messages next to timestamp
+	// GetConvMessagesNextTimestamp returns limits count of messages of the conversation
+	// next to timestamp
 	// Errors: ErrNoMessages, unknown
 	GetConvMessagesNextTimestamp(
 		ctx context.Context,
+		conversationId int64,
 		timestamp time.Time,
 		limit int,
 	) ([]entity.Message, error)
 
-	// GetConvMessagesBetweenTimestamp returns limits count of messages next to timestamp
+	// GetConvMessagesBetweenTimestamp returns messages of the conversation between timestamp
 	// Errors: ErrNoMessages, unknown
 	GetConvMessagesBetweenTimestamp(
 		ctx context.Context,
+		conversationId int64,
 		from, to time.Time,
 	) ([]entity.Message, error)
+
+	// GetThreadRepliesNextTimestamp returns limits count of replies of the thread
+	// next to timestamp
+	// Errors: ErrNoMessages, unknown
+	GetThreadRepliesNextTimestamp(
+		ctx context.Context,
+		threadId uuid.UUID,
+		timestamp time.Time,
+		limit int,
+	) ([]entity.Message, error)
 }
 
 type messageRepository struct {
@@ -69,44 +103,109 @@ var (
 	ErrMessageNotFound     = errors.New("message not found")
 	ErrNoMessages          = errors.New("no messages")
 	ErrMessageDeleteFailed = errors.New("failed to delete message")
+	ErrMessageDuplicate    = errors.New("message with client id already exists")
 )
 
 // Create is implementing interface MessageRepository
 func (ms *messageRepository) Create(
 	ctx context.Context,
 	msg *entity.Message,
-) (uuid.UUID, error) {
+	events ...*entity.OutboxEvent,
+) (id uuid.UUID, err error) {
 	const op = "gochat.internal.domain.infastructure.datastore.Create"
 
 	// Generate new uuid for message
-	id, err := uuid.NewV4()
+	if msg.ID == uuid.Nil {
+		msg.ID, err = uuid.NewV4()
+		if err != nil {
+			return msg.ID, ErrGenerateUUIDFailed
+		}
+	}
+	id = msg.ID
+
+	tx, err := ms.storage.BeginTxx(ctx, nil)
 	if err != nil {
-		return id, ErrGenerateUUIDFailed
+		return id, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = insertMessage(ctx, tx, msg); err != nil {
+		if errors.Is(err, ErrMessageCreateFailed) || errors.Is(err, ErrMessageDuplicate) {
+			return id, err
+		}
+		return id, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = addOutboxEvents(ctx, tx, events...); err != nil {
+		return id, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return id, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// insertMessage inserts message with id in the transaction, reply is counted
+// in the thread of the message it replies to
+// Errors: ErrMessageCreateFailed, ErrMessageDuplicate, unknown
+func insertMessage(ctx context.Context, tx *sqlx.Tx, msg *entity.Message) error {
+	if msg.UpdatedAt.IsZero() {
+		msg.UpdatedAt = msg.CreatedAt
 	}
 
-	msg.ID = id
-	result, err := ms.storage.NamedExecContext(
+	result, err := tx.NamedExecContext(
 		ctx,
 		`
-    INSERT INTO chat.messages (id, sender_id, message_kind, message, created_at)
-    VALUES (:id, :sender_id, :message_kind, :message, :created_at)
+    INSERT INTO chat.messages
+    (id, conversation_id, sender_id, message_kind, message, created_at, client_id,
+      updated_at, reply_to, thread_id)
+    VALUES (
+      :id, :conversation_id, :sender_id, :message_kind, :message, :created_at,
+      NULLIF(:client_id, ''), :updated_at, :reply_to, :thread_id
+    )
+    ON CONFLICT (sender_id, client_id) WHERE client_id IS NOT NULL DO NOTHING
     `,
 		msg,
 	)
 	if err != nil {
-		return id, fmt.Errorf("%s: %w", op, err)
+		return err
 	}
 
 	res, err := result.RowsAffected()
 	if err != nil {
-		return id, fmt.Errorf("%s: %w", op, err)
+		return err
 	}
 
 	if res != 1 {
-		return id, ErrMessageCreateFailed
+		if msg.ClientID != "" {
+			return ErrMessageDuplicate
+		}
+		return ErrMessageCreateFailed
 	}
 
-	return id, nil
+	if msg.ThreadID != nil {
+		_, err = tx.ExecContext(
+			ctx,
+			`
+      UPDATE chat.messages
+      SET reply_count = reply_count + 1, last_reply_at = GREATEST(last_reply_at, $1)
+      WHERE id=$2
+      `,
+			msg.CreatedAt,
+			msg.ThreadID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // FindById is implementing interface MessageRepository
@@ -119,7 +218,13 @@ func (ms *messageRepository) FindById(
 	var msg entity.Message
 	err := ms.storage.Get(
 		&msg,
-		"SELECT * FROM chat.messages WHERE id=$1",
+		`
+    SELECT id, conversation_id, sender_id, message_kind, message, created_at,
+      COALESCE(client_id, '') AS client_id, updated_at, deleted_at,
+      reply_to, thread_id, reply_count, last_reply_at
+    FROM chat.messages
+    WHERE id=$1
+    `,
 		id,
 	)
 	if err != nil {
@@ -134,14 +239,65 @@ func (ms *messageRepository) FindById(
 	return &msg, nil
 }
 
+// FindByClientId is implementing interface MessageRepository
+func (ms *messageRepository) FindByClientId(
+	ctx context.Context,
+	senderId int64,
+	clientId string,
+) (*entity.Message, error) {
+	const op = "gochat.internal.domain.infastructure.datastore.message.FindByClientId"
+
+	var msg entity.Message
+	err := ms.storage.GetContext(
+		ctx,
+		&msg,
+		`
+    SELECT id, conversation_id, sender_id, message_kind, message, created_at, client_id,
+      updated_at, deleted_at, reply_to, thread_id, reply_count, last_reply_at
+    FROM chat.messages
+    WHERE sender_id=$1 AND client_id=$2
+    `,
+		senderId,
+		clientId,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrMessageNotFound
+		default:
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return &msg, nil
+}
+
 // Update is implementing interface MessageRepository
-func (ms *messageRepository) Update(ctx context.Context, message *entity.Message) error {
+func (ms *messageRepository) Update(
+	ctx context.Context,
+	message *entity.Message,
+	events ...*entity.OutboxEvent,
+) (err error) {
 	const op = "gochat.internal.domain.infastructure.datastore.message.Update"
 
-	result, err := ms.storage.ExecContext(
+	tx, err := ms.storage.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := tx.ExecContext(
 		ctx,
-		"UPDATE chat.messages SET message=$1  WHERE id=$2",
+		`
+    UPDATE chat.messages SET message=$1, updated_at=$2
+    WHERE id=$3 AND deleted_at IS NULL
+    `,
 		message.Message,
+		message.UpdatedAt,
 		message.ID,
 	)
 	if err != nil {
@@ -154,7 +310,16 @@ func (ms *messageRepository) Update(ctx context.Context, message *entity.Message
 	}
 
 	if res != 1 {
-		return ErrMessageUpdateFailed
+		err = ErrMessageUpdateFailed
+		return err
+	}
+
+	if err = addOutboxEvents(ctx, tx, events...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
@@ -164,12 +329,28 @@ func (ms *messageRepository) Update(ctx context.Context, message *entity.Message
 func (ms *messageRepository) Delete(
 	ctx context.Context,
 	id uuid.UUID,
-) error {
+	deletedAt time.Time,
+	events ...*entity.OutboxEvent,
+) (err error) {
 	const op = "gochat.internal.domain.infastructure.datastore.message.Delete"
 
-	result, err := ms.storage.ExecContext(
+	tx, err := ms.storage.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := tx.ExecContext(
 		ctx,
-		"DELETE FROM chat.messages WHERE id=$1",
+		`
+    UPDATE chat.messages SET message='', updated_at=$1, deleted_at=$1
+    WHERE id=$2 AND deleted_at IS NULL
+    `,
+		deletedAt,
 		id,
 	)
 	if err != nil {
@@ -182,7 +363,22 @@ func (ms *messageRepository) Delete(
 	}
 
 	if res != 1 {
-		return ErrMessageDeleteFailed
+		err = ErrMessageDeleteFailed
+		return err
+	}
+
+	// reactions are not kept on tombstones and could not be added to them
+	_, err = tx.ExecContext(ctx, `DELETE FROM chat.message_reactions WHERE message_id=$1`, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = addOutboxEvents(ctx, tx, events...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
 	return nil
@@ -191,6 +387,7 @@ func (ms *messageRepository) Delete(
 // GetConvMessagesPrevTimestamp is implementing MessageRepository interface
 func (ms *messageRepository) GetConvMessagesPrevTimestamp(
 	ctx context.Context,
+	conversationId int64,
 	timestamp time.Time,
 	limit int,
 ) ([]entity.Message, error) {
@@ -202,14 +399,16 @@ func (ms *messageRepository) GetConvMessagesPrevTimestamp(
 		&messages,
 		`
     WITH ready_messages AS (
-     SELECT id, sender_id, message_kind, message, created_at 
+     SELECT id, conversation_id, sender_id, message_kind, message, created_at,
+       updated_at, deleted_at, reply_to, thread_id, reply_count, last_reply_at
      FROM chat.messages 
-     WHERE created_at<$1 
+     WHERE conversation_id=$1 AND created_at<$2 
 		 ORDER BY created_at DESC
-     LIMIT $2
+     LIMIT $3
     )
     SELECT * FROM ready_messages ORDER BY created_at ASC
     `,
+		conversationId,
 		timestamp,
 		limit,
 	)
@@ -228,6 +427,7 @@ func (ms *messageRepository) GetConvMessagesPrevTimestamp(
 // GetConvMessagesNextTimestamp is implementing interface MessageRepository
 func (ms *messageRepository) GetConvMessagesNextTimestamp(
 	ctx context.Context,
+	conversationId int64,
 	timestamp time.Time,
 	limit int,
 ) ([]entity.Message, error) {
@@ -237,12 +437,14 @@ func (ms *messageRepository) GetConvMessagesNextTimestamp(
 	err := ms.storage.SelectContext(ctx,
 		&messages,
 		`
-    SELECT id, sender_id, message_kind, message, created_at 
+    SELECT id, conversation_id, sender_id, message_kind, message, created_at,
+      updated_at, deleted_at, reply_to, thread_id, reply_count, last_reply_at
     FROM chat.messages 
-    WHERE created_at>$1 
+    WHERE conversation_id=$1 AND created_at>$2 
 		ORDER BY created_at ASC
-    LIMIT $2
+    LIMIT $3
     `,
+		conversationId,
 		timestamp,
 		limit,
 	)
@@ -261,6 +463,7 @@ func (ms *messageRepository) GetConvMessagesNextTimestamp(
 // GetConvMessagesBetweenTimestamp is implementing interface MessageRepository
 func (ms *messageRepository) GetConvMessagesBetweenTimestamp(
 	ctx context.Context,
+	conversationId int64,
 	from, to time.Time,
 ) ([]entity.Message, error) {
 	const op = "gochat.internal.domain.infastructure.datastore.message.GetConvMessagesBetweenTimestamp"
@@ -270,11 +473,13 @@ func (ms *messageRepository) GetConvMessagesBetweenTimestamp(
 		ctx,
 		&messages,
 		`
-    SELECT id, sender_id, message_kind, message, created_at 
+    SELECT id, conversation_id, sender_id, message_kind, message, created_at,
+      updated_at, deleted_at, reply_to, thread_id, reply_count, last_reply_at
     FROM chat.messages 
-    WHERE created_at BETWEEN $1 and $2
+    WHERE conversation_id=$1 AND created_at BETWEEN $2 and $3
 		ORDER BY created_at ASC
     `,
+		conversationId,
 		from,
 		to)
 	if err != nil {
@@ -288,3 +493,40 @@ func (ms *messageRepository) GetConvMessagesBetweenTimestamp(
 
 	return messages, nil
 }
+
+// GetThreadRepliesNextTimestamp is implementing interface MessageRepository
+func (ms *messageRepository) GetThreadRepliesNextTimestamp(
+	ctx context.Context,
+	threadId uuid.UUID,
+	timestamp time.Time,
+	limit int,
+) ([]entity.Message, error) {
+	const op = "gochat.internal.domain.infastructure.datastore.message.GetThreadRepliesNextTimestamp"
+
+	var messages []entity.Message
+	err := ms.storage.SelectContext(
+		ctx,
+		&messages,
+		`
+    SELECT id, conversation_id, sender_id, message_kind, message, created_at,
+      updated_at, deleted_at, reply_to, thread_id, reply_count, last_reply_at
+    FROM chat.messages
+    WHERE thread_id=$1 AND created_at>$2
+    ORDER BY created_at ASC
+    LIMIT $3
+    `,
+		threadId,
+		timestamp,
+		limit,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNoMessages
+		default:
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return messages, nil
+}
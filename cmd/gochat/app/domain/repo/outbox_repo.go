@@ -0,0 +1,147 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/storage"
+)
+
+type OutboxRepository interface {
+	// Dispatch passes unpublished events in order of creation to publish and marks
+	// them published, dispatches of all instances of the app are serialized, so
+	// events are published in order of creation, dispatch is stopped on the first
+	// publish error
+	// Errors: unknown
+	Dispatch(
+		ctx context.Context,
+		limit int,
+		publish func(event *entity.OutboxEvent) error,
+	) (int, error)
+
+	// DeletePublished deletes events published before timestamp and returns their count
+	// Errors: unknown
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+type outboxRepository struct {
+	storage *storage.Storage
+}
+
+func NewOutboxRepository(db *storage.Storage) OutboxRepository {
+	return &outboxRepository{storage: db}
+}
+
+// addOutboxEvents adds events to the outbox in the transaction of the changes
+func addOutboxEvents(ctx context.Context, tx *sqlx.Tx, events ...*entity.OutboxEvent) error {
+	for _, event := range events {
+		_, err := tx.NamedExecContext(
+			ctx,
+			`
+    INSERT INTO chat.outbox (id, event_type, event_version, payload, created_at)
+    VALUES (:id, :event_type, :event_version, :payload, :created_at)
+    `,
+			event,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Dispatch is implementing interface OutboxRepository
+func (obr *outboxRepository) Dispatch(
+	ctx context.Context,
+	limit int,
+	publish func(event *entity.OutboxEvent) error,
+) (dispatched int, err error) {
+	const op = "gochat.internal.domain.infastructure.datastore.outbox.Dispatch"
+
+	tx, err := obr.storage.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// dispatchers wait each other, so later events are not published
+	// by another instance before the locked ones
+	_, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('chat.outbox'))")
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var events []entity.OutboxEvent
+	err = tx.SelectContext(
+		ctx,
+		&events,
+		`
+    SELECT id, event_type, event_version, payload, created_at, published_at
+    FROM chat.outbox
+    WHERE published_at IS NULL
+    ORDER BY created_at ASC
+    LIMIT $1
+    FOR UPDATE
+    `,
+		limit,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// events published before the error are still marked
+	var publishErr error
+	for i := range events {
+		if publishErr = publish(&events[i]); publishErr != nil {
+			break
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			"UPDATE chat.outbox SET published_at = NOW() WHERE id = $1",
+			events[i].ID,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		dispatched++
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if publishErr != nil {
+		return dispatched, fmt.Errorf("%s: %w", op, publishErr)
+	}
+
+	return dispatched, nil
+}
+
+// DeletePublished is implementing interface OutboxRepository
+func (obr *outboxRepository) DeletePublished(
+	ctx context.Context,
+	before time.Time,
+) (int64, error) {
+	const op = "gochat.internal.domain.infastructure.datastore.outbox.DeletePublished"
+
+	result, err := obr.storage.ExecContext(
+		ctx,
+		"DELETE FROM chat.outbox WHERE published_at < $1",
+		before,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,284 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/storage"
+)
+
+// UnreadCounterRepository represents read model of the unread counters, rows keep
+// position of the last applied event, so events handled twice are not applied again
+type UnreadCounterRepository interface {
+	// Increment increments unread counters of the conversation for members
+	// except sender and resets counter of the sender, all users are members
+	// of the common conversation
+	// Errors: unknown
+	Increment(ctx context.Context, conversationID, senderID int64, position int64) error
+
+	// Reset resets unread counter of the conversation for user
+	// Errors: unknown
+	Reset(ctx context.Context, conversationID, userID int64, position int64) error
+
+	// FindByUser returns unread counters of conversations of the user
+	// Errors: unknown
+	FindByUser(ctx context.Context, userID int64) ([]entity.UnreadCounter, error)
+
+	// Clear deletes all unread counters
+	// Errors: unknown
+	Clear(ctx context.Context) error
+}
+
+// LastMessageRepository represents read model of the last messages, rows keep
+// position of the last applied event, so events handled twice are not applied again
+type LastMessageRepository interface {
+	// Save saves the last message of the conversation
+	// Errors: unknown
+	Save(ctx context.Context, msg *entity.LastMessage, position int64) error
+
+	// Update updates text of the last message of the conversation
+	// if it is the message with id
+	// Errors: unknown
+	Update(
+		ctx context.Context,
+		conversationID int64,
+		id uuid.UUID,
+		message string,
+		position int64,
+	) error
+
+	// FindByConversation returns the last message of the conversation
+	// Errors: ErrMessageNotFound, unknown
+	FindByConversation(ctx context.Context, conversationID int64) (*entity.LastMessage, error)
+
+	// Clear deletes all last messages
+	// Errors: unknown
+	Clear(ctx context.Context) error
+}
+
+type unreadCounterRepository struct {
+	storage *storage.Storage
+}
+
+func NewUnreadCounterRepository(db *storage.Storage) UnreadCounterRepository {
+	return &unreadCounterRepository{storage: db}
+}
+
+// Increment is implementing interface UnreadCounterRepository
+func (ur *unreadCounterRepository) Increment(
+	ctx context.Context,
+	conversationID, senderID int64,
+	position int64,
+) error {
+	const op = "gochat.internal.domain.infastructure.datastore.unread.Increment"
+
+	_, err := ur.storage.ExecContext(
+		ctx,
+		`
+    INSERT INTO chat.unread_counters (conversation_id, user_id, unread_count, position)
+    SELECT $1, id, CASE WHEN id = $2 THEN 0 ELSE 1 END, $3 FROM chat.users
+    WHERE $1 = 0 OR id IN (
+      SELECT user_id FROM chat.conversation_members WHERE conversation_id = $1
+    )
+    ON CONFLICT (conversation_id, user_id) DO UPDATE
+    SET unread_count = CASE
+        WHEN EXCLUDED.user_id = $2 THEN 0
+        ELSE chat.unread_counters.unread_count + 1
+      END,
+      position = EXCLUDED.position
+    WHERE chat.unread_counters.position < EXCLUDED.position
+    `,
+		conversationID,
+		senderID,
+		position,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Reset is implementing interface UnreadCounterRepository
+func (ur *unreadCounterRepository) Reset(
+	ctx context.Context,
+	conversationID, userID int64,
+	position int64,
+) error {
+	const op = "gochat.internal.domain.infastructure.datastore.unread.Reset"
+
+	_, err := ur.storage.ExecContext(
+		ctx,
+		`
+    INSERT INTO chat.unread_counters (conversation_id, user_id, unread_count, position)
+    VALUES ($1, $2, 0, $3)
+    ON CONFLICT (conversation_id, user_id) DO UPDATE
+    SET unread_count = 0, position = EXCLUDED.position
+    WHERE chat.unread_counters.position < EXCLUDED.position
+    `,
+		conversationID,
+		userID,
+		position,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// FindByUser is implementing interface UnreadCounterRepository
+func (ur *unreadCounterRepository) FindByUser(
+	ctx context.Context,
+	userID int64,
+) ([]entity.UnreadCounter, error) {
+	const op = "gochat.internal.domain.infastructure.datastore.unread.FindByUser"
+
+	var counters []entity.UnreadCounter
+	err := ur.storage.SelectContext(
+		ctx,
+		&counters,
+		`
+    SELECT conversation_id, user_id, unread_count
+    FROM chat.unread_counters
+    WHERE user_id = $1
+    `,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return counters, nil
+}
+
+// Clear is implementing interface UnreadCounterRepository
+func (ur *unreadCounterRepository) Clear(ctx context.Context) error {
+	const op = "gochat.internal.domain.infastructure.datastore.unread.Clear"
+
+	if _, err := ur.storage.ExecContext(ctx, "DELETE FROM chat.unread_counters"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+type lastMessageRepository struct {
+	storage *storage.Storage
+}
+
+func NewLastMessageRepository(db *storage.Storage) LastMessageRepository {
+	return &lastMessageRepository{storage: db}
+}
+
+// Save is implementing interface LastMessageRepository
+func (lr *lastMessageRepository) Save(
+	ctx context.Context,
+	msg *entity.LastMessage,
+	position int64,
+) error {
+	const op = "gochat.internal.domain.infastructure.datastore.last_message.Save"
+
+	_, err := lr.storage.ExecContext(
+		ctx,
+		`
+    INSERT INTO chat.last_messages
+    (conversation_id, id, sender_id, message_kind, message, created_at, position)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)
+    ON CONFLICT (conversation_id) DO UPDATE
+    SET id = EXCLUDED.id,
+      sender_id = EXCLUDED.sender_id,
+      message_kind = EXCLUDED.message_kind,
+      message = EXCLUDED.message,
+      created_at = EXCLUDED.created_at,
+      position = EXCLUDED.position
+    WHERE chat.last_messages.position < EXCLUDED.position
+    `,
+		msg.ConversationID,
+		msg.ID,
+		msg.SenderID,
+		msg.MessageKind,
+		msg.Message.Message,
+		msg.CreatedAt,
+		position,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Update is implementing interface LastMessageRepository
+func (lr *lastMessageRepository) Update(
+	ctx context.Context,
+	conversationID int64,
+	id uuid.UUID,
+	message string,
+	position int64,
+) error {
+	const op = "gochat.internal.domain.infastructure.datastore.last_message.Update"
+
+	_, err := lr.storage.ExecContext(
+		ctx,
+		`
+    UPDATE chat.last_messages SET message = $3, position = $4
+    WHERE conversation_id = $1 AND id = $2 AND position < $4
+    `,
+		conversationID,
+		id,
+		message,
+		position,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// FindByConversation is implementing interface LastMessageRepository
+func (lr *lastMessageRepository) FindByConversation(
+	ctx context.Context,
+	conversationID int64,
+) (*entity.LastMessage, error) {
+	const op = "gochat.internal.domain.infastructure.datastore.last_message.FindByConversation"
+
+	var msg entity.LastMessage
+	err := lr.storage.GetContext(
+		ctx,
+		&msg,
+		`
+    SELECT conversation_id, id, sender_id, message_kind, message, created_at
+    FROM chat.last_messages
+    WHERE conversation_id = $1
+    `,
+		conversationID,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrMessageNotFound
+		default:
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return &msg, nil
+}
+
+// Clear is implementing interface LastMessageRepository
+func (lr *lastMessageRepository) Clear(ctx context.Context) error {
+	const op = "gochat.internal.domain.infastructure.datastore.last_message.Clear"
+
+	if _, err := lr.storage.ExecContext(ctx, "DELETE FROM chat.last_messages"); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/storage"
+)
+
+var (
+	ErrReactionExists   = errors.New("reaction already exists")
+	ErrReactionNotFound = errors.New("reaction not found")
+)
+
+type ReactionRepository interface {
+	// Add adds reaction on the message, events are added to the outbox
+	// in the same transaction
+	// Errors: ErrReactionExists, ErrMessageNotFound, unknown
+	Add(ctx context.Context, reaction *entity.Reaction, events ...*entity.OutboxEvent) error
+
+	// Remove removes reaction of user on the message, events are added to the outbox
+	// in the same transaction
+	// Errors: ErrReactionNotFound, unknown
+	Remove(ctx context.Context, reaction *entity.Reaction, events ...*entity.OutboxEvent) error
+
+	// FindByMessages returns reactions on the messages ordered by creation
+	// Errors: unknown
+	FindByMessages(ctx context.Context, messageIds []uuid.UUID) ([]entity.Reaction, error)
+}
+
+type reactionRepository struct {
+	storage *storage.Storage
+}
+
+func NewReactionRepository(db *storage.Storage) ReactionRepository {
+	return &reactionRepository{storage: db}
+}
+
+// Add is implementing interface ReactionRepository
+func (rr *reactionRepository) Add(
+	ctx context.Context,
+	reaction *entity.Reaction,
+	events ...*entity.OutboxEvent,
+) (err error) {
+	const op = "gochat.internal.domain.infastructure.datastore.reaction.Add"
+
+	tx, err := rr.storage.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := tx.NamedExecContext(
+		ctx,
+		`
+    INSERT INTO chat.message_reactions (message_id, user_id, emoji, created_at)
+    VALUES (:message_id, :user_id, :emoji, :created_at)
+    ON CONFLICT (message_id, user_id, emoji) DO NOTHING
+    `,
+		reaction,
+	)
+	if err != nil {
+		if isViolation(err, foreignKeyViolation) {
+			err = ErrMessageNotFound
+			return err
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if res != 1 {
+		err = ErrReactionExists
+		return err
+	}
+
+	if err = addOutboxEvents(ctx, tx, events...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Remove is implementing interface ReactionRepository
+func (rr *reactionRepository) Remove(
+	ctx context.Context,
+	reaction *entity.Reaction,
+	events ...*entity.OutboxEvent,
+) (err error) {
+	const op = "gochat.internal.domain.infastructure.datastore.reaction.Remove"
+
+	tx, err := rr.storage.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := tx.ExecContext(
+		ctx,
+		`
+    DELETE FROM chat.message_reactions
+    WHERE message_id=$1 AND user_id=$2 AND emoji=$3
+    `,
+		reaction.MessageID,
+		reaction.UserID,
+		reaction.Emoji,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if res != 1 {
+		err = ErrReactionNotFound
+		return err
+	}
+
+	if err = addOutboxEvents(ctx, tx, events...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// FindByMessages is implementing interface ReactionRepository
+func (rr *reactionRepository) FindByMessages(
+	ctx context.Context,
+	messageIds []uuid.UUID,
+) ([]entity.Reaction, error) {
+	const op = "gochat.internal.domain.infastructure.datastore.reaction.FindByMessages"
+
+	if len(messageIds) == 0 {
+		return nil, nil
+	}
+
+	query, args, err := sqlx.In(
+		`
+    SELECT message_id, user_id, emoji, created_at
+    FROM chat.message_reactions
+    WHERE message_id IN (?)
+    ORDER BY created_at ASC
+    `,
+		messageIds,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var reactions []entity.Reaction
+	err = rr.storage.SelectContext(ctx, &reactions, rr.storage.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return reactions, nil
+}
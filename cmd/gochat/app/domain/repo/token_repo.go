@@ -8,6 +8,7 @@ import (
 	"github.com/gofrs/uuid"
 
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/pkg/cache"
 )
 
 var (
@@ -29,7 +30,7 @@ type TokenRepository interface {
 	TokenById(ctx context.Context, id entity.TokenID) (entity.Token, error)
 
 	// UserByTokenId return user by token
-	// Errors: ErrUserNotFound
+	// Errors: ErrTokenNotFound, ErrUserNotFound, unknown
 	UserByTokenId(ctx context.Context, id entity.TokenID) (*entity.User, error)
 
 	// DeleteToken deletes token by id
@@ -87,7 +88,17 @@ func (tr *tokenRepository) SaveToken(
 
 // TokenById is implementing interface TokenRepository
 func (tr *tokenRepository) TokenById(ctx context.Context, id entity.TokenID) (entity.Token, error) {
-	return tr.tokenStorage.Get(ctx, id.String())
+	tk, err := tr.tokenStorage.Get(ctx, id.String())
+	if err != nil {
+		switch {
+		case errors.Is(err, cache.ErrKeyNotFound):
+			return tk, ErrTokenNotFound
+		default:
+			return tk, err
+		}
+	}
+
+	return tk, nil
 }
 
 // UserByTokenId is implementing interface TokenRepository
@@ -95,7 +106,7 @@ func (tr *tokenRepository) UserByTokenId(
 	ctx context.Context,
 	id entity.TokenID,
 ) (*entity.User, error) {
-	tk, err := tr.tokenStorage.Get(ctx, id.String())
+	tk, err := tr.TokenById(ctx, id)
 	if err != nil {
 		return nil, err
 	}
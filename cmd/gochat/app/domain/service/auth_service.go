@@ -37,6 +37,10 @@ type AuthService interface {
 	// if token exists but not the same, then token would be deleted
 	// Errors: ErrMismatchedTokens, ErrTokenNotFound, unknown
 	ValidateToken(ctx context.Context, authToken entity.Token) error
+
+	// Authenticate returns user of the token by token id
+	// Errors: ErrInvalidToken, unknown
+	Authenticate(ctx context.Context, tokenId entity.TokenID) (*entity.User, error)
 }
 
 type authService struct {
@@ -133,3 +137,21 @@ func (aus *authService) ValidateToken(ctx context.Context, authToken entity.Toke
 
 	return nil
 }
+
+// Authenticate is implementing interface AuthService
+func (aus *authService) Authenticate(
+	ctx context.Context,
+	tokenId entity.TokenID,
+) (*entity.User, error) {
+	user, err := aus.tokenRepository.UserByTokenId(ctx, tokenId)
+	if err != nil {
+		switch {
+		case errors.Is(err, repo.ErrTokenNotFound) || errors.Is(err, repo.ErrUserNotFound):
+			return nil, ErrInvalidToken
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
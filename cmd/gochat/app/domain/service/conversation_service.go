@@ -0,0 +1,680 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/internal/color"
+)
+
+const (
+	// MaxConversationTitleLength is max count of characters in the title of the conversation
+	MaxConversationTitleLength = 40
+
+	// MaxConversationMembers is max count of members of the group conversation
+	MaxConversationMembers = 256
+)
+
+var (
+	ErrNotConversationMember = errors.New("user is not member of the conversation")
+	ErrInvalidConversation   = errors.New("invalid conversation")
+	ErrMembershipForbidden   = errors.New("not allowed to change members of the conversation")
+	ErrAlreadyMember         = errors.New("user is already member of the conversation")
+)
+
+type ConversationService interface {
+	// CreateGroup creates group conversation of the creator and members and returns it's id,
+	// conversation created event is published through the outbox
+	// Errors: ErrInvalidConversation, unknown
+	CreateGroup(ctx context.Context, conv *entity.Conversation, memberIds []int64) (int64, error)
+
+	// Direct returns direct conversation of two users, it is created if it does not exist
+	// Errors: ErrInvalidConversation, unknown
+	Direct(ctx context.Context, userId, peerId int64) (*entity.Conversation, error)
+
+	// FindById finds conversation by id for the member
+	// Errors: ErrNotConversationMember, repo.ErrConversationNotFound, unknown
+	FindById(ctx context.Context, id, userId int64) (*entity.Conversation, error)
+
+	// GetByMember returns conversations of the member, the common conversation is the first
+	// Errors: unknown
+	GetByMember(ctx context.Context, userId int64) ([]entity.Conversation, error)
+
+	// CheckMember checks that user is member of the conversation,
+	// all users are members of the common conversation
+	// Errors: ErrNotConversationMember, unknown
+	CheckMember(ctx context.Context, conversationId, userId int64) error
+
+	// GetMembers returns ids of members of the group conversation for the member
+	// Errors: ErrNotConversationMember, ErrInvalidConversation, unknown
+	GetMembers(ctx context.Context, conversationId, userId int64) ([]int64, error)
+
+	// AddMembers adds users to members of the group conversation by the member,
+	// users that are already members are skipped
+	// Errors: ErrNotConversationMember, ErrInvalidConversation, repo.ErrUserNotFound, unknown
+	AddMembers(ctx context.Context, conversationId, actorId int64, userIds []int64) error
+
+	// RemoveMember removes member of the group conversation by its creator
+	// Errors: ErrNotConversationMember, ErrInvalidConversation, ErrMembershipForbidden,
+	// repo.ErrMemberNotFound, unknown
+	RemoveMember(ctx context.Context, conversationId, actorId, userId int64) error
+
+	// Leave removes the member from the group conversation, the creator could leave
+	// only if there are no other members
+	// Errors: ErrNotConversationMember, ErrInvalidConversation, ErrMembershipForbidden,
+	// unknown
+	Leave(ctx context.Context, conversationId, userId int64) error
+
+	// Invite invites user to the group conversation by the member
+	// Errors: ErrNotConversationMember, ErrInvalidConversation, ErrAlreadyMember,
+	// repo.ErrInviteExists, repo.ErrUserNotFound, unknown
+	Invite(ctx context.Context, conversationId, actorId, userId int64) error
+
+	// AcceptInvite adds invited user to members of the conversation
+	// Errors: ErrInvalidConversation, repo.ErrInviteNotFound, unknown
+	AcceptInvite(ctx context.Context, conversationId, userId int64) error
+
+	// GetInvites returns invites of the user
+	// Errors: unknown
+	GetInvites(ctx context.Context, userId int64) ([]entity.ConversationInvite, error)
+}
+
+// Every change of conversation membership is stored in one transaction with system
+// message of the change and outbox events of both, the membership event is added
+// first, so clients receive it before the message of the change.
+type conversationService struct {
+	repository repo.ConversationRepository
+	users      UserService
+	outbox     *OutboxDispatcher
+}
+
+// NewConversationService creates conversation service, system messages of the
+// conversations contain names of users from user service, outbox dispatcher
+// is notified about new events if it is not nil
+func NewConversationService(
+	repository repo.ConversationRepository,
+	users UserService,
+	outbox *OutboxDispatcher,
+) ConversationService {
+	return &conversationService{
+		repository: repository,
+		users:      users,
+		outbox:     outbox,
+	}
+}
+
+// CreateGroup is implementing interface ConversationService
+func (cs *conversationService) CreateGroup(
+	ctx context.Context,
+	conv *entity.Conversation,
+	memberIds []int64,
+) (int64, error) {
+	conv.Title = strings.TrimSpace(conv.Title)
+	switch {
+	case conv.Title == "":
+		return 0, fmt.Errorf("%w: empty title", ErrInvalidConversation)
+	case utf8.RuneCountInString(conv.Title) > MaxConversationTitleLength:
+		return 0, fmt.Errorf("%w: title is too long", ErrInvalidConversation)
+	}
+
+	// creator is always member of the group
+	memberIds = uniqueMemberIds(append([]int64{conv.CreatorID}, memberIds...))
+	if len(memberIds) > MaxConversationMembers {
+		return 0, fmt.Errorf("%w: too many members", ErrInvalidConversation)
+	}
+
+	if conv.Color == "" {
+		conv.Color = color.GetRandomColorInHex()
+	}
+	conv.ConversationKind = entity.GroupConversation
+
+	if err := cs.create(ctx, conv, memberIds); err != nil {
+		return 0, err
+	}
+
+	return conv.ID, nil
+}
+
+// Direct is implementing interface ConversationService
+func (cs *conversationService) Direct(
+	ctx context.Context,
+	userId, peerId int64,
+) (*entity.Conversation, error) {
+	const op = "gochat.app.domain.service.conversationService.Direct"
+
+	if userId == peerId {
+		return nil, fmt.Errorf("%w: direct conversation with yourself", ErrInvalidConversation)
+	}
+
+	conv, err := cs.repository.FindDirect(ctx, userId, peerId)
+	if err == nil {
+		return conv, nil
+	}
+	if !errors.Is(err, repo.ErrConversationNotFound) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	conv = &entity.Conversation{
+		CreatorID:        userId,
+		ConversationKind: entity.DirectConversation,
+	}
+	err = cs.create(ctx, conv, []int64{userId, peerId})
+	if errors.Is(err, repo.ErrConversationExists) {
+		// conversation is created concurrently by the peer
+		conv, err = cs.repository.FindDirect(ctx, userId, peerId)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return conv, nil
+}
+
+// create creates conversation with members and adds conversation created event to the outbox
+func (cs *conversationService) create(
+	ctx context.Context,
+	conv *entity.Conversation,
+	memberIds []int64,
+) error {
+	const op = "gochat.app.domain.service.conversationService.create"
+
+	// id is reserved before creating, because it is a part of the event
+	id, err := cs.repository.NextId(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	conv.ID = id
+	conv.CreatedAt = time.Now()
+
+	event, err := newOutboxEvent(
+		ConversationCreatedEventType,
+		ConversationCreatedEventVersion,
+		conv.CreatedAt,
+		entity.ConversationCreatedEvent{Conversation: *conv, MemberIDs: memberIds},
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	text := "started the conversation"
+	if conv.ConversationKind == entity.GroupConversation {
+		text = fmt.Sprintf("created the conversation %q", conv.Title)
+	}
+
+	msg, msgEvent, err := newSystemMessage(
+		conv.ID,
+		conv.CreatorID,
+		entity.CreateConversationMessage,
+		text,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := cs.repository.Create(ctx, conv, memberIds, msg, event, msgEvent); err != nil {
+		return err
+	}
+	cs.notify()
+
+	return nil
+}
+
+// FindById is implementing interface ConversationService
+func (cs *conversationService) FindById(
+	ctx context.Context,
+	id, userId int64,
+) (*entity.Conversation, error) {
+	if err := cs.CheckMember(ctx, id, userId); err != nil {
+		return nil, err
+	}
+
+	return cs.repository.FindById(ctx, id)
+}
+
+// GetByMember is implementing interface ConversationService
+func (cs *conversationService) GetByMember(
+	ctx context.Context,
+	userId int64,
+) ([]entity.Conversation, error) {
+	const op = "gochat.app.domain.service.conversationService.GetByMember"
+
+	common, err := cs.repository.FindById(ctx, entity.DefaultConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	convs, err := cs.repository.FindByMember(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return append([]entity.Conversation{*common}, convs...), nil
+}
+
+// CheckMember is implementing interface ConversationService
+func (cs *conversationService) CheckMember(
+	ctx context.Context,
+	conversationId, userId int64,
+) error {
+	const op = "gochat.app.domain.service.conversationService.CheckMember"
+
+	if conversationId == entity.DefaultConversationID {
+		return nil
+	}
+
+	isMember, err := cs.repository.IsMember(ctx, conversationId, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !isMember {
+		return ErrNotConversationMember
+	}
+
+	return nil
+}
+
+// uniqueMemberIds returns ids without duplicates in order of the first occurrence
+func uniqueMemberIds(ids []int64) []int64 {
+	seen := make(map[int64]struct{}, len(ids))
+	unique := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+
+	return unique
+}
+
+// GetMembers is implementing interface ConversationService
+func (cs *conversationService) GetMembers(
+	ctx context.Context,
+	conversationId, userId int64,
+) ([]int64, error) {
+	const op = "gochat.app.domain.service.conversationService.GetMembers"
+
+	if _, err := cs.group(ctx, conversationId, userId); err != nil {
+		return nil, err
+	}
+
+	memberIds, err := cs.repository.GetMemberIds(ctx, conversationId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return memberIds, nil
+}
+
+// AddMembers is implementing interface ConversationService
+func (cs *conversationService) AddMembers(
+	ctx context.Context,
+	conversationId, actorId int64,
+	userIds []int64,
+) error {
+	const op = "gochat.app.domain.service.conversationService.AddMembers"
+
+	if _, err := cs.group(ctx, conversationId, actorId); err != nil {
+		return err
+	}
+
+	memberIds, err := cs.repository.GetMemberIds(ctx, conversationId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// only new members are added, so adding is idempotent
+	var added []int64
+	for _, userId := range uniqueMemberIds(userIds) {
+		if !slices.Contains(memberIds, userId) {
+			added = append(added, userId)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	names, err := cs.userNames(ctx, added)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event, err := newMemberEvent(conversationId, entity.MemberAdded, actorId, added)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	msg, msgEvent, err := newSystemMessage(
+		conversationId,
+		actorId,
+		entity.AddingUserMessage,
+		"added "+names,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = cs.repository.AddMembers(
+		ctx,
+		conversationId,
+		added,
+		MaxConversationMembers,
+		msg,
+		event,
+		msgEvent,
+	)
+	if err != nil {
+		if errors.Is(err, repo.ErrTooManyMembers) {
+			return fmt.Errorf("%w: too many members", ErrInvalidConversation)
+		}
+		return err
+	}
+	cs.notify()
+
+	return nil
+}
+
+// RemoveMember is implementing interface ConversationService
+func (cs *conversationService) RemoveMember(
+	ctx context.Context,
+	conversationId, actorId, userId int64,
+) error {
+	const op = "gochat.app.domain.service.conversationService.RemoveMember"
+
+	conv, err := cs.group(ctx, conversationId, actorId)
+	if err != nil {
+		return err
+	}
+
+	if actorId == userId {
+		return cs.Leave(ctx, conversationId, userId)
+	}
+	if conv.CreatorID != actorId {
+		return ErrMembershipForbidden
+	}
+
+	names, err := cs.userNames(ctx, []int64{userId})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event, err := newMemberEvent(conversationId, entity.MemberRemoved, actorId, []int64{userId})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	msg, msgEvent, err := newSystemMessage(
+		conversationId,
+		actorId,
+		entity.RemovingUserMessage,
+		"removed "+names,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = cs.repository.RemoveMember(ctx, conversationId, userId, msg, event, msgEvent)
+	if err != nil {
+		return err
+	}
+	cs.notify()
+
+	return nil
+}
+
+// Leave is implementing interface ConversationService
+func (cs *conversationService) Leave(ctx context.Context, conversationId, userId int64) error {
+	const op = "gochat.app.domain.service.conversationService.Leave"
+
+	conv, err := cs.group(ctx, conversationId, userId)
+	if err != nil {
+		return err
+	}
+
+	// group without creator could not be managed, so creator leaves it the last
+	if conv.CreatorID == userId {
+		memberIds, err := cs.repository.GetMemberIds(ctx, conversationId)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if len(memberIds) > 1 {
+			return fmt.Errorf(
+				"%w: creator could not leave group with members",
+				ErrMembershipForbidden,
+			)
+		}
+	}
+
+	event, err := newMemberEvent(conversationId, entity.MemberLeft, userId, []int64{userId})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	msg, msgEvent, err := newSystemMessage(
+		conversationId,
+		userId,
+		entity.LeavingUserMessage,
+		"left the conversation",
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = cs.repository.RemoveMember(ctx, conversationId, userId, msg, event, msgEvent)
+	if err != nil {
+		return err
+	}
+	cs.notify()
+
+	return nil
+}
+
+// Invite is implementing interface ConversationService
+func (cs *conversationService) Invite(
+	ctx context.Context,
+	conversationId, actorId, userId int64,
+) error {
+	const op = "gochat.app.domain.service.conversationService.Invite"
+
+	if _, err := cs.group(ctx, conversationId, actorId); err != nil {
+		return err
+	}
+
+	isMember, err := cs.repository.IsMember(ctx, conversationId, userId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if isMember {
+		return ErrAlreadyMember
+	}
+
+	names, err := cs.userNames(ctx, []int64{userId})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	event, err := newMemberEvent(conversationId, entity.MemberInvited, actorId, []int64{userId})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	msg, msgEvent, err := newSystemMessage(
+		conversationId,
+		actorId,
+		entity.InvitingUserMessage,
+		"invited "+names,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = cs.repository.AddInvite(ctx, &entity.ConversationInvite{
+		ConversationID: conversationId,
+		UserID:         userId,
+		InviterID:      actorId,
+		CreatedAt:      time.Now(),
+	}, msg, event, msgEvent)
+	if err != nil {
+		return err
+	}
+	cs.notify()
+
+	return nil
+}
+
+// AcceptInvite is implementing interface ConversationService
+func (cs *conversationService) AcceptInvite(
+	ctx context.Context,
+	conversationId, userId int64,
+) error {
+	const op = "gochat.app.domain.service.conversationService.AcceptInvite"
+
+	event, err := newMemberEvent(conversationId, entity.MemberJoined, userId, []int64{userId})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	msg, msgEvent, err := newSystemMessage(
+		conversationId,
+		userId,
+		entity.AddingUserMessage,
+		"joined the conversation",
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = cs.repository.AcceptInvite(
+		ctx,
+		conversationId,
+		userId,
+		MaxConversationMembers,
+		msg,
+		event,
+		msgEvent,
+	)
+	if err != nil {
+		if errors.Is(err, repo.ErrTooManyMembers) {
+			return fmt.Errorf("%w: too many members", ErrInvalidConversation)
+		}
+		return err
+	}
+	cs.notify()
+
+	return nil
+}
+
+// GetInvites is implementing interface ConversationService
+func (cs *conversationService) GetInvites(
+	ctx context.Context,
+	userId int64,
+) ([]entity.ConversationInvite, error) {
+	return cs.repository.FindInvites(ctx, userId)
+}
+
+// group returns group conversation if user is member of it, membership
+// of the common and direct conversations could not be changed
+// Errors: ErrNotConversationMember, ErrInvalidConversation, unknown
+func (cs *conversationService) group(
+	ctx context.Context,
+	conversationId, userId int64,
+) (*entity.Conversation, error) {
+	if conversationId == entity.DefaultConversationID {
+		return nil, fmt.Errorf("%w: common conversation", ErrInvalidConversation)
+	}
+
+	conv, err := cs.FindById(ctx, conversationId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if conv.ConversationKind != entity.GroupConversation {
+		return nil, fmt.Errorf("%w: not group conversation", ErrInvalidConversation)
+	}
+
+	return conv, nil
+}
+
+// newMemberEvent creates outbox event of the membership change
+func newMemberEvent(
+	conversationId int64,
+	action entity.MemberAction,
+	actorId int64,
+	userIds []int64,
+) (*entity.OutboxEvent, error) {
+	return newOutboxEvent(
+		ConversationMemberEventType,
+		ConversationMemberEventVersion,
+		time.Now(),
+		entity.ConversationMemberEvent{
+			ConversationID: conversationId,
+			Action:         action,
+			ActorID:        actorId,
+			UserIDs:        userIds,
+		},
+	)
+}
+
+// notify notifies outbox dispatcher about new events
+func (cs *conversationService) notify() {
+	if cs.outbox != nil {
+		cs.outbox.Notify()
+	}
+}
+
+// newSystemMessage creates system message of the conversation on behalf of the actor
+// and outbox event of it, they are stored with the membership change
+// Errors: repo.ErrGenerateUUIDFailed, unknown
+func newSystemMessage(
+	conversationId, actorId int64,
+	kind entity.MessageKind,
+	text string,
+) (*entity.Message, *entity.OutboxEvent, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, nil, repo.ErrGenerateUUIDFailed
+	}
+
+	msg := &entity.Message{
+		ID:             id,
+		ConversationID: conversationId,
+		SenderID:       actorId,
+		MessageKind:    kind,
+		Message:        text,
+		CreatedAt:      time.Now(),
+	}
+
+	event, err := newMessageOutboxEvent(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return msg, event, nil
+}
+
+// userNames returns names of the users separated by comma
+func (cs *conversationService) userNames(ctx context.Context, userIds []int64) (string, error) {
+	names := make([]string, 0, len(userIds))
+	for _, userId := range userIds {
+		user, err := cs.users.FindPublicUserById(ctx, userId)
+		if err != nil {
+			return "", err
+		}
+
+		names = append(names, user.Name)
+	}
+
+	return strings.Join(names, ", "), nil
+}
@@ -0,0 +1,494 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
+)
+
+// testConversationRepository is in-memory conversation repository
+type testConversationRepository struct {
+	nextId   int64
+	convs    map[int64]entity.Conversation
+	members  map[int64][]int64
+	direct   map[string]int64
+	invites  []entity.ConversationInvite
+	messages []*entity.Message
+	events   []*entity.OutboxEvent
+}
+
+func newTestConversationRepository() *testConversationRepository {
+	return &testConversationRepository{
+		convs: map[int64]entity.Conversation{
+			entity.DefaultConversationID: {
+				ID:               entity.DefaultConversationID,
+				Title:            "Common",
+				ConversationKind: entity.GroupConversation,
+			},
+		},
+		members: make(map[int64][]int64),
+		direct:  make(map[string]int64),
+	}
+}
+
+func testDirectKey(userId, peerId int64) string {
+	return fmt.Sprintf("%d:%d", min(userId, peerId), max(userId, peerId))
+}
+
+func (r *testConversationRepository) NextId(_ context.Context) (int64, error) {
+	r.nextId++
+	return r.nextId, nil
+}
+
+func (r *testConversationRepository) Create(
+	_ context.Context,
+	conv *entity.Conversation,
+	memberIds []int64,
+	msg *entity.Message,
+	events ...*entity.OutboxEvent,
+) error {
+	if conv.ConversationKind == entity.DirectConversation {
+		key := testDirectKey(memberIds[0], memberIds[1])
+		if _, ok := r.direct[key]; ok {
+			return repo.ErrConversationExists
+		}
+		r.direct[key] = conv.ID
+	}
+
+	r.convs[conv.ID] = *conv
+	r.members[conv.ID] = memberIds
+	r.addSystemMessage(msg, events...)
+	return nil
+}
+
+func (r *testConversationRepository) FindById(
+	_ context.Context,
+	id int64,
+) (*entity.Conversation, error) {
+	conv, ok := r.convs[id]
+	if !ok {
+		return nil, repo.ErrConversationNotFound
+	}
+
+	return &conv, nil
+}
+
+func (r *testConversationRepository) FindDirect(
+	ctx context.Context,
+	userId, peerId int64,
+) (*entity.Conversation, error) {
+	id, ok := r.direct[testDirectKey(userId, peerId)]
+	if !ok {
+		return nil, repo.ErrConversationNotFound
+	}
+
+	return r.FindById(ctx, id)
+}
+
+func (r *testConversationRepository) FindByMember(
+	_ context.Context,
+	userId int64,
+) ([]entity.Conversation, error) {
+	var convs []entity.Conversation
+	for id := int64(1); id <= r.nextId; id++ {
+		for _, memberId := range r.members[id] {
+			if memberId == userId {
+				convs = append(convs, r.convs[id])
+			}
+		}
+	}
+
+	return convs, nil
+}
+
+func (r *testConversationRepository) IsMember(
+	_ context.Context,
+	conversationId, userId int64,
+) (bool, error) {
+	for _, memberId := range r.members[conversationId] {
+		if memberId == userId {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *testConversationRepository) GetMemberIds(
+	_ context.Context,
+	conversationId int64,
+) ([]int64, error) {
+	return r.members[conversationId], nil
+}
+
+func (r *testConversationRepository) AddMembers(
+	_ context.Context,
+	conversationId int64,
+	memberIds []int64,
+	maxMembers int,
+	msg *entity.Message,
+	events ...*entity.OutboxEvent,
+) error {
+	if len(r.members[conversationId])+len(memberIds) > maxMembers {
+		return repo.ErrTooManyMembers
+	}
+
+	r.members[conversationId] = append(r.members[conversationId], memberIds...)
+	r.addSystemMessage(msg, events...)
+	return nil
+}
+
+func (r *testConversationRepository) RemoveMember(
+	_ context.Context,
+	conversationId, userId int64,
+	msg *entity.Message,
+	events ...*entity.OutboxEvent,
+) error {
+	memberIds := r.members[conversationId]
+	i := slices.Index(memberIds, userId)
+	if i < 0 {
+		return repo.ErrMemberNotFound
+	}
+
+	r.members[conversationId] = slices.Delete(memberIds, i, i+1)
+	r.addSystemMessage(msg, events...)
+	return nil
+}
+
+func (r *testConversationRepository) AddInvite(
+	_ context.Context,
+	invite *entity.ConversationInvite,
+	msg *entity.Message,
+	events ...*entity.OutboxEvent,
+) error {
+	for _, inv := range r.invites {
+		if inv.ConversationID == invite.ConversationID && inv.UserID == invite.UserID {
+			return repo.ErrInviteExists
+		}
+	}
+
+	r.invites = append(r.invites, *invite)
+	r.addSystemMessage(msg, events...)
+	return nil
+}
+
+func (r *testConversationRepository) AcceptInvite(
+	ctx context.Context,
+	conversationId, userId int64,
+	maxMembers int,
+	msg *entity.Message,
+	events ...*entity.OutboxEvent,
+) error {
+	for i, inv := range r.invites {
+		if inv.ConversationID == conversationId && inv.UserID == userId {
+			err := r.AddMembers(ctx, conversationId, []int64{userId}, maxMembers, msg, events...)
+			if err != nil {
+				return err
+			}
+
+			r.invites = slices.Delete(r.invites, i, i+1)
+			return nil
+		}
+	}
+
+	return repo.ErrInviteNotFound
+}
+
+// addSystemMessage stores system message of the change with events of the outbox
+func (r *testConversationRepository) addSystemMessage(
+	msg *entity.Message,
+	events ...*entity.OutboxEvent,
+) {
+	if msg != nil {
+		r.messages = append(r.messages, msg)
+	}
+	r.events = append(r.events, events...)
+}
+
+func (r *testConversationRepository) FindInvites(
+	_ context.Context,
+	userId int64,
+) ([]entity.ConversationInvite, error) {
+	var invites []entity.ConversationInvite
+	for _, inv := range r.invites {
+		if inv.UserID == userId {
+			invites = append(invites, inv)
+		}
+	}
+
+	return invites, nil
+}
+
+// testUserService is user service with names of users made of their ids
+type testUserService struct {
+	UserService
+}
+
+func (us *testUserService) FindPublicUserById(
+	_ context.Context,
+	id int64,
+) (*entity.PublicUser, error) {
+	return &entity.PublicUser{ID: id, Name: fmt.Sprintf("user%d", id)}, nil
+}
+
+// newTestConversationService creates conversation service with in-memory repositories
+func newTestConversationService() (ConversationService, *testConversationRepository) {
+	repository := newTestConversationRepository()
+	cs := NewConversationService(repository, &testUserService{}, nil)
+
+	return cs, repository
+}
+
+// testMemberEvents returns conversation member events added to the outbox
+func testMemberEvents(t *testing.T, events []*entity.OutboxEvent) []entity.ConversationMemberEvent {
+	var memberEvents []entity.ConversationMemberEvent
+	for _, e := range events {
+		if e.EventType != ConversationMemberEventType {
+			continue
+		}
+
+		var event entity.ConversationMemberEvent
+		assert.Equal(t, nil, json.Unmarshal(e.Payload, &event), "should not be error")
+		memberEvents = append(memberEvents, event)
+	}
+
+	return memberEvents
+}
+
+func TestConversationService_CreateGroup(t *testing.T) {
+	cs, repository := newTestConversationService()
+
+	t.Run("check created group", func(t *testing.T) {
+		conv := &entity.Conversation{Title: " friends ", CreatorID: 1}
+		id, err := cs.CreateGroup(context.Background(), conv, []int64{2, 1, 3, 2})
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, "friends", conv.Title, "should trim title")
+		assert.NotEqual(t, "", conv.Color, "should set color")
+		assert.Equal(t, []int64{1, 2, 3}, repository.members[id], "should be unique members")
+
+		if assert.Equal(t, 2, len(repository.events), "should add events to the outbox") {
+			var event entity.ConversationCreatedEvent
+			err := json.Unmarshal(repository.events[0].Payload, &event)
+			assert.Equal(t, nil, err, "should not be error")
+			assert.Equal(t, id, event.ID, "should be id of the conversation")
+			assert.Equal(t, []int64{1, 2, 3}, event.MemberIDs, "should be members")
+			assert.Equal(
+				t,
+				NewMessageEventType,
+				repository.events[1].EventType,
+				"should add system message event after the conversation event",
+			)
+		}
+
+		if assert.Equal(t, 1, len(repository.messages), "should create system message") {
+			msg := repository.messages[0]
+			assert.Equal(t, id, msg.ConversationID, "should be message of the conversation")
+			assert.Equal(t, entity.CreateConversationMessage, msg.MessageKind, "should be kind")
+			assert.NotEqual(t, uuid.Nil, msg.ID, "should generate id of the message")
+		}
+	})
+
+	t.Run("check invalid title", func(t *testing.T) {
+		_, err := cs.CreateGroup(context.Background(), &entity.Conversation{CreatorID: 1}, nil)
+		assert.ErrorIs(t, err, ErrInvalidConversation, "should be invalid conversation")
+	})
+}
+
+func TestConversationService_Direct(t *testing.T) {
+	cs, repository := newTestConversationService()
+
+	conv, err := cs.Direct(context.Background(), 1, 2)
+	assert.Equal(t, nil, err, "should not be error")
+	assert.Equal(t, entity.DirectConversation, conv.ConversationKind, "should be direct")
+
+	t.Run("check the same direct conversation of the peer", func(t *testing.T) {
+		peerConv, err := cs.Direct(context.Background(), 2, 1)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, conv.ID, peerConv.ID, "should be the same conversation")
+		assert.Equal(t, 1, len(repository.messages), "should create conversation once")
+	})
+
+	t.Run("check direct conversation with yourself", func(t *testing.T) {
+		_, err := cs.Direct(context.Background(), 1, 1)
+		assert.ErrorIs(t, err, ErrInvalidConversation, "should be invalid conversation")
+	})
+}
+
+func TestConversationService_Members(t *testing.T) {
+	cs, _ := newTestConversationService()
+
+	conv, err := cs.Direct(context.Background(), 1, 2)
+	assert.Equal(t, nil, err, "should not be error")
+
+	t.Run("check members", func(t *testing.T) {
+		assert.Equal(t, nil, cs.CheckMember(context.Background(), conv.ID, 1), "should be member")
+		assert.ErrorIs(
+			t,
+			cs.CheckMember(context.Background(), conv.ID, 3),
+			ErrNotConversationMember,
+			"should not be member",
+		)
+		assert.Equal(
+			t,
+			nil,
+			cs.CheckMember(context.Background(), entity.DefaultConversationID, 3),
+			"should be member of the common conversation",
+		)
+	})
+
+	t.Run("check conversations of the member", func(t *testing.T) {
+		convs, err := cs.GetByMember(context.Background(), 1)
+		assert.Equal(t, nil, err, "should not be error")
+		if assert.Equal(t, 2, len(convs), "should be count of conversations") {
+			assert.Equal(t, entity.DefaultConversationID, convs[0].ID, "should be common first")
+			assert.Equal(t, conv.ID, convs[1].ID, "should be direct conversation")
+		}
+
+		_, err = cs.FindById(context.Background(), conv.ID, 3)
+		assert.ErrorIs(t, err, ErrNotConversationMember, "should not find for not member")
+	})
+}
+
+func TestConversationService_Membership(t *testing.T) {
+	cs, repository := newTestConversationService()
+	ctx := context.Background()
+
+	conv := &entity.Conversation{Title: "friends", CreatorID: 1}
+	id, err := cs.CreateGroup(ctx, conv, []int64{2})
+	assert.Equal(t, nil, err, "should not be error")
+
+	t.Run("check adding members", func(t *testing.T) {
+		err := cs.AddMembers(ctx, id, 2, []int64{1, 3, 3})
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, []int64{1, 2, 3}, repository.members[id], "should add new members")
+
+		events := testMemberEvents(t, repository.events)
+		if assert.Equal(t, 1, len(events), "should add member event to the outbox") {
+			assert.Equal(t, entity.MemberAdded, events[0].Action, "should be added action")
+			assert.Equal(t, int64(2), events[0].ActorID, "should be actor")
+			assert.Equal(t, []int64{3}, events[0].UserIDs, "should be only new members")
+		}
+
+		msg := repository.messages[len(repository.messages)-1]
+		assert.Equal(t, entity.AddingUserMessage, msg.MessageKind, "should be adding message")
+		assert.Equal(t, "added user3", msg.Message, "should be names of added users")
+	})
+
+	t.Run("check adding by not member", func(t *testing.T) {
+		err := cs.AddMembers(ctx, id, 4, []int64{5})
+		assert.ErrorIs(t, err, ErrNotConversationMember, "should not be member")
+	})
+
+	t.Run("check removing member", func(t *testing.T) {
+		err := cs.RemoveMember(ctx, id, 2, 3)
+		assert.ErrorIs(t, err, ErrMembershipForbidden, "should be removed only by creator")
+
+		err = cs.RemoveMember(ctx, id, 1, 3)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, []int64{1, 2}, repository.members[id], "should remove member")
+
+		msg := repository.messages[len(repository.messages)-1]
+		assert.Equal(t, entity.RemovingUserMessage, msg.MessageKind, "should be removing message")
+	})
+
+	t.Run("check leaving", func(t *testing.T) {
+		err := cs.Leave(ctx, id, 2)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, []int64{1}, repository.members[id], "should remove member")
+
+		events := testMemberEvents(t, repository.events)
+		assert.Equal(t, entity.MemberLeft, events[len(events)-1].Action, "should be left action")
+
+		err = cs.CheckMember(ctx, id, 2)
+		assert.ErrorIs(t, err, ErrNotConversationMember, "should not be member after leaving")
+	})
+
+	t.Run("check invite", func(t *testing.T) {
+		err := cs.Invite(ctx, id, 1, 1)
+		assert.ErrorIs(t, err, ErrAlreadyMember, "should not invite member")
+
+		err = cs.Invite(ctx, id, 1, 4)
+		assert.Equal(t, nil, err, "should not be error")
+
+		err = cs.Invite(ctx, id, 1, 4)
+		assert.ErrorIs(t, err, repo.ErrInviteExists, "should invite once")
+
+		invites, err := cs.GetInvites(ctx, 4)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 1, len(invites), "should be invite of the user")
+
+		err = cs.AcceptInvite(ctx, id, 4)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, nil, cs.CheckMember(ctx, id, 4), "should be member after accepting")
+
+		err = cs.AcceptInvite(ctx, id, 4)
+		assert.ErrorIs(t, err, repo.ErrInviteNotFound, "should accept invite once")
+
+		events := testMemberEvents(t, repository.events)
+		joined := events[len(events)-1]
+		assert.Equal(t, entity.MemberJoined, joined.Action, "should be joined action")
+	})
+
+	t.Run("check creator leaving", func(t *testing.T) {
+		err := cs.Leave(ctx, id, 1)
+		assert.ErrorIs(t, err, ErrMembershipForbidden, "should not leave group with members")
+		assert.Equal(t, nil, cs.CheckMember(ctx, id, 1), "should be member")
+
+		err = cs.Leave(ctx, id, 4)
+		assert.Equal(t, nil, err, "should not be error")
+		err = cs.Leave(ctx, id, 1)
+		assert.Equal(t, nil, err, "should leave group without members")
+	})
+
+	t.Run("check membership of the direct conversation", func(t *testing.T) {
+		direct, err := cs.Direct(ctx, 1, 2)
+		assert.Equal(t, nil, err, "should not be error")
+
+		err = cs.AddMembers(ctx, direct.ID, 1, []int64{3})
+		assert.ErrorIs(t, err, ErrInvalidConversation, "should not change direct conversation")
+	})
+}
+
+func TestConversationService_MembersLimit(t *testing.T) {
+	cs, repository := newTestConversationService()
+	ctx := context.Background()
+
+	memberIds := make([]int64, 0, MaxConversationMembers-1)
+	for userId := int64(2); userId <= MaxConversationMembers; userId++ {
+		memberIds = append(memberIds, userId)
+	}
+
+	id, err := cs.CreateGroup(ctx, &entity.Conversation{Title: "full", CreatorID: 1}, memberIds)
+	assert.Equal(t, nil, err, "should not be error")
+
+	t.Run("check adding to full group", func(t *testing.T) {
+		err := cs.AddMembers(ctx, id, 1, []int64{MaxConversationMembers + 1})
+		assert.ErrorIs(t, err, ErrInvalidConversation, "should be too many members")
+		assert.Equal(
+			t,
+			MaxConversationMembers,
+			len(repository.members[id]),
+			"should not add member",
+		)
+	})
+
+	t.Run("check accepting invite to full group", func(t *testing.T) {
+		err := cs.Invite(ctx, id, 1, MaxConversationMembers+1)
+		assert.Equal(t, nil, err, "should not be error")
+
+		err = cs.AcceptInvite(ctx, id, MaxConversationMembers+1)
+		assert.ErrorIs(t, err, ErrInvalidConversation, "should be too many members")
+
+		invites, err := cs.GetInvites(ctx, MaxConversationMembers+1)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 1, len(invites), "should keep invite")
+	})
+}
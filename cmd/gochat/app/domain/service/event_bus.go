@@ -0,0 +1,383 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+)
+
+// OverflowPolicy specifies what bus does when queue of the subscriber is full
+type OverflowPolicy int
+
+const (
+	// DropOldest drops the oldest queued event to enqueue the new one
+	DropOldest OverflowPolicy = iota
+
+	// DropNewest drops the new event
+	DropNewest
+
+	// Disconnect unsubscribes the subscriber and closes its queue
+	Disconnect
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case DropOldest:
+		return "drop_oldest"
+	case DropNewest:
+		return "drop_newest"
+	case Disconnect:
+		return "disconnect"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// DefaultQueueSize is size of the subscriber queue if it is not specified
+	DefaultQueueSize = 16
+
+	// DefaultRetention is count of the last events kept for replay
+	DefaultRetention = 1024
+)
+
+// ErrSeqExpired is returned if events after the sequence number are not retained
+var ErrSeqExpired = errors.New("events after sequence number are not retained")
+
+// EventFilter reports whether event should be enqueued to the subscriber,
+// it is called on publishing, so it should be fast and must not block
+type EventFilter func(event *entity.Event) bool
+
+// SubscriberOpts represents options of the subscriber queue
+type SubscriberOpts struct {
+	QueueSize int
+	Policy    OverflowPolicy
+
+	// Filter is optional filter of the events matched by pattern
+	Filter EventFilter
+}
+
+// Subscription is opaque handle of the subscriber, handles are never reused,
+// so unsubscribing by stale handle does nothing
+type Subscription struct {
+	id uint64
+}
+
+func (s Subscription) String() string {
+	return strconv.FormatUint(s.id, 10)
+}
+
+// SubscriberStats represents lag metrics of the subscriber
+type SubscriberStats struct {
+	Pattern      string
+	Subscription Subscription
+	Policy       OverflowPolicy
+
+	// Queued is count of events waiting for the subscriber
+	Queued   int
+	Capacity int
+
+	// Enqueued, Dropped and Filtered are counts of events since subscription
+	Enqueued uint64
+	Dropped  uint64
+	Filtered uint64
+}
+
+type EventBus interface {
+	// Subscribe adds a new subscriber for events which type matches pattern and returns
+	// its handle and queue of events, pattern is either exact event type or prefix
+	// of event types ending with "*" e.g. "Message.*", the queue is closed on
+	// unsubscribe or disconnect, if opts is nil then default queue size and
+	// DropOldest policy are used
+	Subscribe(pattern string, opts *SubscriberOpts) (Subscription, <-chan entity.Event)
+
+	// Unsubscribe deletes a subscriber by its handle
+	Unsubscribe(sub Subscription)
+
+	// Publish assigns next sequence number of the bus to the event, sequence
+	// numbers are shared by all event types, and enqueues it to all subscribers
+	// which pattern matches the type and filter accepts the event, it never
+	// blocks on slow subscribers
+	// Errors: unknown
+	Publish(event entity.Event) error
+
+	// Replay returns retained events of all types published after the sequence number
+	// in order of sequence numbers
+	// Errors: ErrSeqExpired, unknown
+	Replay(ctx context.Context, afterSeq uint64) ([]entity.Event, error)
+
+	// Stats returns lag metrics of all subscribers
+	Stats() []SubscriberStats
+}
+
+// FilterPayload creates filter of events with payload of type T that satisfy predicate,
+// events with payload of another type are filtered out
+func FilterPayload[T any](predicate func(payload *T) bool) EventFilter {
+	return func(event *entity.Event) bool {
+		payload, ok := event.Payload.(T)
+		return ok && predicate(&payload)
+	}
+}
+
+// isPrefixPattern reports whether pattern matches event types by prefix
+func isPrefixPattern(pattern string) bool {
+	return strings.HasSuffix(pattern, "*")
+}
+
+// matchPattern reports whether event type matches pattern of the subscriber
+func matchPattern(pattern, eventType string) bool {
+	if isPrefixPattern(pattern) {
+		return strings.HasPrefix(eventType, strings.TrimSuffix(pattern, "*"))
+	}
+
+	return pattern == eventType
+}
+
+// subscriber represents queue of the subscriber
+type subscriber struct {
+	pattern string
+	filter  EventFilter
+	queue   chan entity.Event
+	policy  OverflowPolicy
+
+	enqueued atomic.Uint64
+	dropped  atomic.Uint64
+	filtered atomic.Uint64
+}
+
+// enqueue enqueues event accepted by filter by policy of the subscriber without
+// blocking, returns false if subscriber should be disconnected
+func (s *subscriber) enqueue(event entity.Event) bool {
+	if s.filter != nil && !s.filter(&event) {
+		s.filtered.Add(1)
+		return true
+	}
+
+	for {
+		select {
+		case s.queue <- event:
+			s.enqueued.Add(1)
+			return true
+		default:
+		}
+
+		switch s.policy {
+		case DropNewest:
+			s.dropped.Add(1)
+			return true
+		case Disconnect:
+			s.dropped.Add(1)
+			return false
+		default:
+			select {
+			case <-s.queue:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	}
+}
+
+type eventBus struct {
+	mu     sync.RWMutex
+	nextId uint64
+
+	// subscribers with exact patterns are indexed by event type,
+	// subscribers with prefix patterns are matched on every publishing
+	subscribers map[uint64]*subscriber
+	exact       map[string]map[uint64]*subscriber
+	prefixed    map[uint64]*subscriber
+
+	// seqMu serializes publishing, so events are enqueued in order of sequence numbers
+	seqMu     sync.Mutex
+	seq       uint64
+	retention int
+	log       []entity.Event
+}
+
+// NewEventBus creates in-memory event bus that retains DefaultRetention last events
+func NewEventBus() EventBus {
+	return newEventBus(DefaultRetention)
+}
+
+// newEventBus creates in-memory event bus, if retention is 0 events are not retained
+func newEventBus(retention int) *eventBus {
+	return &eventBus{
+		subscribers: make(map[uint64]*subscriber),
+		exact:       make(map[string]map[uint64]*subscriber),
+		prefixed:    make(map[uint64]*subscriber),
+		retention:   retention,
+	}
+}
+
+// Subscribe is implementing interface EventBus
+func (eb *eventBus) Subscribe(
+	pattern string,
+	opts *SubscriberOpts,
+) (Subscription, <-chan entity.Event) {
+	s := &subscriber{
+		pattern: pattern,
+		queue:   make(chan entity.Event, DefaultQueueSize),
+		policy:  DropOldest,
+	}
+	if opts != nil {
+		if opts.QueueSize > 0 {
+			s.queue = make(chan entity.Event, opts.QueueSize)
+		}
+		s.policy = opts.Policy
+		s.filter = opts.Filter
+	}
+
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	eb.nextId++
+	id := eb.nextId
+
+	eb.subscribers[id] = s
+	if isPrefixPattern(pattern) {
+		eb.prefixed[id] = s
+	} else {
+		if eb.exact[pattern] == nil {
+			eb.exact[pattern] = make(map[uint64]*subscriber)
+		}
+		eb.exact[pattern][id] = s
+	}
+
+	return Subscription{id: id}, s.queue
+}
+
+// Unsubscribe is implementing interface EventBus
+func (eb *eventBus) Unsubscribe(sub Subscription) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	eb.remove(sub.id)
+}
+
+// remove deletes subscriber and closes its queue, bus should be locked
+func (eb *eventBus) remove(id uint64) {
+	s, ok := eb.subscribers[id]
+	if !ok {
+		return
+	}
+
+	delete(eb.subscribers, id)
+	delete(eb.prefixed, id)
+	if subscribers, ok := eb.exact[s.pattern]; ok {
+		delete(subscribers, id)
+		if len(subscribers) == 0 {
+			delete(eb.exact, s.pattern)
+		}
+	}
+
+	close(s.queue)
+}
+
+// Publish is implementing interface EventBus,
+// sequence number is kept if it is already assigned to the event
+func (eb *eventBus) Publish(event entity.Event) error {
+	eb.seqMu.Lock()
+	defer eb.seqMu.Unlock()
+
+	if event.Seq == 0 {
+		event.Seq = eb.seq + 1
+	}
+	eb.seq = max(eb.seq, event.Seq)
+	eb.retain(event)
+
+	var disconnected []uint64
+
+	eb.mu.RLock()
+	for id, s := range eb.exact[event.Type] {
+		if !s.enqueue(event) {
+			disconnected = append(disconnected, id)
+		}
+	}
+	for id, s := range eb.prefixed {
+		if matchPattern(s.pattern, event.Type) && !s.enqueue(event) {
+			disconnected = append(disconnected, id)
+		}
+	}
+	eb.mu.RUnlock()
+
+	if len(disconnected) == 0 {
+		return nil
+	}
+
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	for _, id := range disconnected {
+		eb.remove(id)
+	}
+
+	return nil
+}
+
+// retain appends event to the log, the oldest events are dropped
+// if log is full, publishing should be locked
+func (eb *eventBus) retain(event entity.Event) {
+	if eb.retention <= 0 {
+		return
+	}
+
+	eb.log = append(eb.log, event)
+	if len(eb.log) > eb.retention {
+		eb.log = eb.log[len(eb.log)-eb.retention:]
+	}
+}
+
+// Replay is implementing interface EventBus
+func (eb *eventBus) Replay(_ context.Context, afterSeq uint64) ([]entity.Event, error) {
+	eb.seqMu.Lock()
+	defer eb.seqMu.Unlock()
+
+	switch {
+	case afterSeq == eb.seq:
+		return nil, nil
+	case afterSeq > eb.seq:
+		// sequence numbers are started again e.g. after restart
+		return nil, ErrSeqExpired
+	}
+
+	if len(eb.log) == 0 || eb.log[0].Seq > afterSeq+1 {
+		return nil, ErrSeqExpired
+	}
+
+	var events []entity.Event
+	for _, event := range eb.log {
+		if event.Seq > afterSeq {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+// Stats is implementing interface EventBus
+func (eb *eventBus) Stats() []SubscriberStats {
+	eb.mu.RLock()
+	defer eb.mu.RUnlock()
+
+	var stats []SubscriberStats
+	for id, s := range eb.subscribers {
+		stats = append(stats, SubscriberStats{
+			Pattern:      s.pattern,
+			Subscription: Subscription{id: id},
+			Policy:       s.policy,
+			Queued:       len(s.queue),
+			Capacity:     cap(s.queue),
+			Enqueued:     s.enqueued.Load(),
+			Dropped:      s.dropped.Load(),
+			Filtered:     s.filtered.Load(),
+		})
+	}
+
+	return stats
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+)
+
+const testEventType = "TestEvent"
+
+// publishTestEvents publishes events with payloads from 0 to n-1
+func publishTestEvents(eb EventBus, n int) {
+	for i := 0; i < n; i++ {
+		eb.Publish(entity.Event{Type: testEventType, Payload: i})
+	}
+}
+
+// drainTestEvents returns payloads of queued events
+func drainTestEvents(eventch <-chan entity.Event) []interface{} {
+	var payloads []interface{}
+	for {
+		select {
+		case event, ok := <-eventch:
+			if !ok {
+				return payloads
+			}
+			payloads = append(payloads, event.Payload)
+		default:
+			return payloads
+		}
+	}
+}
+
+func TestEventBus_Policies(t *testing.T) {
+	eb := NewEventBus()
+
+	t.Run("check drop oldest", func(t *testing.T) {
+		id, eventch := eb.Subscribe(
+			testEventType,
+			&SubscriberOpts{QueueSize: 2, Policy: DropOldest},
+		)
+		defer eb.Unsubscribe(id)
+
+		publishTestEvents(eb, 3)
+		assert.Equal(t, []interface{}{1, 2}, drainTestEvents(eventch), "should keep newest events")
+	})
+
+	t.Run("check drop newest", func(t *testing.T) {
+		id, eventch := eb.Subscribe(
+			testEventType,
+			&SubscriberOpts{QueueSize: 2, Policy: DropNewest},
+		)
+		defer eb.Unsubscribe(id)
+
+		publishTestEvents(eb, 3)
+		assert.Equal(t, []interface{}{0, 1}, drainTestEvents(eventch), "should keep oldest events")
+	})
+
+	t.Run("check disconnect", func(t *testing.T) {
+		id, eventch := eb.Subscribe(
+			testEventType,
+			&SubscriberOpts{QueueSize: 2, Policy: Disconnect},
+		)
+		defer eb.Unsubscribe(id)
+
+		publishTestEvents(eb, 3)
+		assert.Equal(t, []interface{}{0, 1}, drainTestEvents(eventch), "should keep queued events")
+
+		_, ok := <-eventch
+		assert.Equal(t, false, ok, "should close queue")
+		assert.Equal(t, 0, len(eb.Stats()), "should unsubscribe")
+	})
+}
+
+func TestEventBus_PublishNeverBlocks(t *testing.T) {
+	eb := NewEventBus()
+
+	slowId, _ := eb.Subscribe(testEventType, &SubscriberOpts{QueueSize: 1})
+	defer eb.Unsubscribe(slowId)
+	fastId, fastch := eb.Subscribe(testEventType, &SubscriberOpts{QueueSize: 10})
+	defer eb.Unsubscribe(fastId)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		publishTestEvents(eb, 10)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish should not block on slow subscriber")
+	}
+	assert.Equal(t, 10, len(drainTestEvents(fastch)), "should deliver events to fast subscriber")
+
+	t.Run("check lag metrics", func(t *testing.T) {
+		for _, stats := range eb.Stats() {
+			if stats.Subscription != slowId {
+				continue
+			}
+
+			assert.Equal(t, 1, stats.Queued, "should be equal queued events")
+			assert.Equal(t, uint64(10), stats.Enqueued, "should be equal enqueued events")
+			assert.Equal(t, uint64(9), stats.Dropped, "should be equal dropped events")
+		}
+	})
+}
+
+func TestEventBus_Replay(t *testing.T) {
+	eb := newEventBus(3)
+
+	id, eventch := eb.Subscribe(testEventType, nil)
+	defer eb.Unsubscribe(id)
+
+	publishTestEvents(eb, 5)
+
+	t.Run("check monotonic sequence numbers", func(t *testing.T) {
+		for i := 1; i <= 5; i++ {
+			event := <-eventch
+			assert.Equal(t, uint64(i), event.Seq, "should be next sequence number")
+		}
+	})
+
+	t.Run("check replay after sequence number", func(t *testing.T) {
+		events, err := eb.Replay(context.Background(), 2)
+		assert.Equal(t, nil, err, "should not be error")
+		if assert.Equal(t, 3, len(events), "should be equal count of events") {
+			assert.Equal(t, 2, events[0].Payload, "should be next event")
+		}
+
+		events, err = eb.Replay(context.Background(), 5)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 0, len(events), "should be no events")
+	})
+
+	t.Run("check expired sequence number", func(t *testing.T) {
+		_, err := eb.Replay(context.Background(), 1)
+		assert.ErrorIs(t, err, ErrSeqExpired, "should be expired")
+
+		_, err = eb.Replay(context.Background(), 6)
+		assert.ErrorIs(t, err, ErrSeqExpired, "should be expired")
+	})
+
+	t.Run("check sequence numbers of all event types", func(t *testing.T) {
+		eb.Publish(entity.Event{Type: "OtherEvent", Payload: 5})
+		publishTestEvents(eb, 1)
+
+		events, err := eb.Replay(context.Background(), 5)
+		assert.Equal(t, nil, err, "should not be error")
+		if assert.Equal(t, 2, len(events), "should be events of all types") {
+			assert.Equal(t, "OtherEvent", events[0].Type, "should be other event first")
+			assert.Equal(t, uint64(6), events[0].Seq, "should be next sequence number")
+			assert.Equal(t, testEventType, events[1].Type, "should be test event next")
+			assert.Equal(t, uint64(7), events[1].Seq, "should not reuse sequence number")
+		}
+	})
+}
+
+func TestEventBus_Unsubscribe(t *testing.T) {
+	eb := NewEventBus()
+
+	id, eventch := eb.Subscribe(testEventType, nil)
+	eb.Unsubscribe(id)
+	eb.Unsubscribe(id)
+
+	_, ok := <-eventch
+	assert.Equal(t, false, ok, "should close queue")
+
+	nextId, _ := eb.Subscribe(testEventType, nil)
+	assert.NotEqual(t, id, nextId, "should not reuse id")
+}
+
+func TestEventBus_Patterns(t *testing.T) {
+	eb := NewEventBus()
+
+	messageId, messagech := eb.Subscribe("Message.*", nil)
+	defer eb.Unsubscribe(messageId)
+	allId, allch := eb.Subscribe("*", nil)
+	defer eb.Unsubscribe(allId)
+	exactId, exactch := eb.Subscribe("Message.Created", nil)
+	defer eb.Unsubscribe(exactId)
+
+	eb.Publish(entity.Event{Type: "Message.Created", Payload: "created"})
+	eb.Publish(entity.Event{Type: "Message.Deleted", Payload: "deleted"})
+	eb.Publish(entity.Event{Type: "Conversation.Created", Payload: "conversation"})
+
+	assert.Equal(
+		t,
+		[]interface{}{"created", "deleted"},
+		drainTestEvents(messagech),
+		"should match events by prefix",
+	)
+	assert.Equal(
+		t,
+		[]interface{}{"created", "deleted", "conversation"},
+		drainTestEvents(allch),
+		"should match all events",
+	)
+	assert.Equal(t, []interface{}{"created"}, drainTestEvents(exactch), "should match exact type")
+}
+
+func TestEventBus_Filter(t *testing.T) {
+	eb := NewEventBus()
+
+	id, eventch := eb.Subscribe(testEventType, &SubscriberOpts{
+		Filter: FilterPayload(func(payload *int) bool { return *payload%2 == 0 }),
+	})
+	defer eb.Unsubscribe(id)
+
+	publishTestEvents(eb, 5)
+	eb.Publish(entity.Event{Type: testEventType, Payload: "not int"})
+
+	assert.Equal(t, []interface{}{0, 2, 4}, drainTestEvents(eventch), "should filter events")
+	for _, stats := range eb.Stats() {
+		assert.Equal(t, uint64(3), stats.Filtered, "should count filtered events")
+		assert.Equal(t, uint64(3), stats.Enqueued, "should count enqueued events")
+	}
+}
+
+func TestEventBus_StaleSubscription(t *testing.T) {
+	eb := NewEventBus()
+
+	staleId, _ := eb.Subscribe(testEventType, nil)
+	eb.Unsubscribe(staleId)
+
+	id, eventch := eb.Subscribe(testEventType, nil)
+	defer eb.Unsubscribe(id)
+
+	eb.Unsubscribe(staleId)
+	eb.Unsubscribe(Subscription{})
+	publishTestEvents(eb, 1)
+
+	assert.Equal(
+		t,
+		[]interface{}{0},
+		drainTestEvents(eventch),
+		"should not unsubscribe by stale handle",
+	)
+}
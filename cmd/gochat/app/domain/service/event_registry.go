@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+)
+
+var (
+	ErrInvalidPayload     = errors.New("invalid event payload")
+	ErrUnsupportedVersion = errors.New("unsupported event version")
+	ErrEventNotAccepted   = errors.New("event is not accepted from clients")
+)
+
+// PayloadCaster moves json payload of the event to the next or previous version
+type PayloadCaster func(payload json.RawMessage) (json.RawMessage, error)
+
+// EventDef defines payload T of the event type
+type EventDef[T any] struct {
+	// Version is version of the payload T, default is 1
+	Version int
+
+	// Upcasters move payload from version i+1 to i+2, so payloads of previous
+	// versions could be decoded only if there are Version-1 upcasters
+	Upcasters []PayloadCaster
+
+	// Downcasters move payload from version i+2 to i+1, so payloads could be
+	// encoded for clients that do not understand the last version, they are optional
+	Downcasters []PayloadCaster
+
+	// Validate validates decoded payload, it is optional
+	Validate func(payload *T) error
+
+	// Handle handles payload of created event before publishing and may change it,
+	// it is optional, events without handler are only sent to clients
+	Handle func(ctx context.Context, payload *T) error
+}
+
+// eventCodec represents registered event type
+type eventCodec struct {
+	version     int
+	upcasters   []PayloadCaster
+	downcasters []PayloadCaster
+
+	// decode decodes and validates payload of the version
+	decode func(data []byte) (interface{}, error)
+
+	// handle is nil if event type has no handler
+	handle func(ctx context.Context, event *entity.Event) error
+}
+
+// upcast moves payload from the version to the version of the codec
+// Errors: ErrUnsupportedVersion, ErrInvalidPayload
+func (c *eventCodec) upcast(data []byte, version int) ([]byte, error) {
+	if version > c.version || version < c.version-len(c.upcasters) {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	for ; version < c.version; version++ {
+		var err error
+		data, err = c.upcasters[version-1](data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+		}
+	}
+
+	return data, nil
+}
+
+// downcast moves payload from the version of the codec to the highest version
+// that is not greater than max version and returns it
+// Errors: ErrUnsupportedVersion, unknown
+func (c *eventCodec) downcast(data []byte, maxVersion int) ([]byte, int, error) {
+	version := c.version
+	if maxVersion < version-len(c.downcasters) {
+		return nil, 0, fmt.Errorf("%w: %d", ErrUnsupportedVersion, maxVersion)
+	}
+
+	for ; version > maxVersion; version-- {
+		var err error
+		data, err = c.downcasters[version-2](data)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return data, version, nil
+}
+
+// EventRegistry represents registry of event types and their payloads
+type EventRegistry struct {
+	mu    sync.RWMutex
+	types map[string]eventCodec
+}
+
+func NewEventRegistry() *EventRegistry {
+	return &EventRegistry{
+		types: make(map[string]eventCodec),
+	}
+}
+
+// Register registers payload type T of the event type,
+// previously registered definition of the type is replaced
+func Register[T any](r *EventRegistry, eventType string, def EventDef[T]) {
+	codec := eventCodec{
+		version:     max(def.Version, 1),
+		upcasters:   def.Upcasters,
+		downcasters: def.Downcasters,
+		decode: func(data []byte) (interface{}, error) {
+			var payload T
+			if err := json.Unmarshal(data, &payload); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+			}
+
+			if def.Validate != nil {
+				if err := def.Validate(&payload); err != nil {
+					return nil, fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+				}
+			}
+
+			return payload, nil
+		},
+	}
+
+	if def.Handle != nil {
+		codec.handle = func(ctx context.Context, event *entity.Event) error {
+			payload, ok := event.Payload.(T)
+			if !ok {
+				return fmt.Errorf("%w: %T for %s", ErrInvalidPayload, event.Payload, eventType)
+			}
+
+			if err := def.Handle(ctx, &payload); err != nil {
+				return err
+			}
+
+			event.Payload = payload
+			return nil
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.types[eventType] = codec
+}
+
+// Decode upcasts json payload of the version to the registered version, decodes it
+// to the type registered for event type, validates it and returns it with
+// the registered version, version 0 is the first version
+// Errors: ErrUnknownEventType, ErrUnsupportedVersion, ErrInvalidPayload
+func (r *EventRegistry) Decode(
+	eventType string,
+	version int,
+	data []byte,
+) (interface{}, int, error) {
+	codec, ok := r.codec(eventType)
+	if !ok {
+		return nil, 0, ErrUnknownEventType
+	}
+
+	data, err := codec.upcast(data, max(version, 1))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	payload, err := codec.decode(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return payload, codec.version, nil
+}
+
+// Encode encodes payload of the registered version to json and downcasts it
+// to the highest version that is not greater than max version, returns
+// encoded payload and its version
+// Errors: ErrUnknownEventType, ErrUnsupportedVersion, unknown
+func (r *EventRegistry) Encode(
+	eventType string,
+	payload interface{},
+	maxVersion int,
+) (json.RawMessage, int, error) {
+	codec, ok := r.codec(eventType)
+	if !ok {
+		return nil, 0, ErrUnknownEventType
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return codec.downcast(data, maxVersion)
+}
+
+// MaxVersion returns the highest registered version of event types
+func (r *EventRegistry) MaxVersion() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	version := 1
+	for _, codec := range r.types {
+		version = max(version, codec.version)
+	}
+
+	return version
+}
+
+// Handle handles event by handler registered for its type,
+// events without handler are not accepted from clients
+// Errors: ErrUnknownEventType, ErrEventNotAccepted, ErrInvalidPayload, errors of the handler
+func (r *EventRegistry) Handle(ctx context.Context, event *entity.Event) error {
+	codec, ok := r.codec(event.Type)
+	if !ok {
+		return ErrUnknownEventType
+	}
+
+	if codec.handle == nil {
+		return ErrEventNotAccepted
+	}
+
+	return codec.handle(ctx, event)
+}
+
+func (r *EventRegistry) codec(eventType string) (eventCodec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	codec, ok := r.types[eventType]
+	return codec, ok
+}
+
+// TypedEvent represents event with payload of type T
+type TypedEvent[T any] struct {
+	entity.Event
+
+	Payload T
+}
+
+// SubscribeTyped subscribes to events matched by pattern with payload T, events with payload
+// of another type are skipped, the queue is closed after the queue of the bus,
+// so subscriber should read it until it is closed
+func SubscribeTyped[T any](
+	bus EventBus,
+	pattern string,
+	opts *SubscriberOpts,
+) (Subscription, <-chan TypedEvent[T]) {
+	id, eventch := bus.Subscribe(pattern, opts)
+
+	typedch := make(chan TypedEvent[T])
+	go func() {
+		defer close(typedch)
+
+		for event := range eventch {
+			payload, ok := event.Payload.(T)
+			if !ok {
+				continue
+			}
+
+			typedch <- TypedEvent[T]{Event: event, Payload: payload}
+		}
+	}()
+
+	return id, typedch
+}
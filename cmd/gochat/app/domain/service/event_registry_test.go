@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+)
+
+type testPayload struct {
+	Text string `json:"text"`
+}
+
+var errEmptyText = errors.New("empty text")
+
+func newTestEventService() EventService {
+	registry := NewEventRegistry()
+	Register(registry, testEventType, EventDef[testPayload]{
+		Validate: func(payload *testPayload) error {
+			if payload.Text == "" {
+				return errEmptyText
+			}
+			return nil
+		},
+		Handle: func(_ context.Context, payload *testPayload) error {
+			payload.Text += " handled"
+			return nil
+		},
+	})
+
+	return NewEventService(NewEventBus(), registry)
+}
+
+func TestEventService_CreateEvent(t *testing.T) {
+	es := newTestEventService()
+
+	t.Run("check typed payload", func(t *testing.T) {
+		event, err := es.CreateEvent(&entity.RawEvent{
+			Type:    testEventType,
+			Payload: []byte(`{"text":"hello"}`),
+		})
+		if assert.Equal(t, nil, err, "should not be error") {
+			assert.Equal(t, testPayload{Text: "hello"}, event.Payload, "should be typed payload")
+		}
+	})
+
+	t.Run("check invalid payload", func(t *testing.T) {
+		_, err := es.CreateEvent(&entity.RawEvent{Type: testEventType, Payload: []byte(`{}`)})
+		assert.ErrorIs(t, err, ErrInvalidPayload, "should be invalid payload")
+		assert.ErrorIs(t, err, errEmptyText, "should be validation error")
+
+		_, err = es.CreateEvent(&entity.RawEvent{Type: testEventType, Payload: []byte(`[]`)})
+		assert.ErrorIs(t, err, ErrInvalidPayload, "should be invalid payload")
+	})
+
+	t.Run("check unknown event type", func(t *testing.T) {
+		_, err := es.CreateEvent(&entity.RawEvent{Type: "Unknown", Payload: []byte(`{}`)})
+		assert.ErrorIs(t, err, ErrUnknownEventType, "should be unknown event type")
+	})
+}
+
+func TestEventService_HandleEvent(t *testing.T) {
+	es := newTestEventService()
+
+	event, err := es.CreateEvent(&entity.RawEvent{
+		Type:    testEventType,
+		Payload: []byte(`{"text":"hello"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = es.HandleEvent(context.Background(), event)
+	assert.Equal(t, nil, err, "should not be error")
+	assert.Equal(t, testPayload{Text: "hello handled"}, event.Payload, "should be handled payload")
+
+	t.Run("check event without handler", func(t *testing.T) {
+		registry := NewEventRegistry()
+		Register(registry, testEventType, EventDef[testPayload]{})
+
+		err := registry.Handle(context.Background(), &entity.Event{
+			Type:    testEventType,
+			Payload: testPayload{Text: "hello"},
+		})
+		assert.ErrorIs(t, err, ErrEventNotAccepted, "should not accept event")
+	})
+}
+
+func TestSubscribeTyped(t *testing.T) {
+	eb := NewEventBus()
+
+	id, eventch := SubscribeTyped[testPayload](eb, testEventType, nil)
+
+	eb.Publish(entity.Event{Type: testEventType, Payload: "not typed"})
+	eb.Publish(entity.Event{Type: testEventType, Payload: testPayload{Text: "typed"}})
+
+	select {
+	case event := <-eventch:
+		assert.Equal(t, "typed", event.Payload.Text, "should be typed payload")
+		assert.Equal(t, uint64(2), event.Seq, "should skip event of another payload")
+	case <-time.After(time.Second):
+		t.Fatal("event should be received")
+	}
+
+	eb.Unsubscribe(id)
+	_, ok := <-eventch
+	assert.Equal(t, false, ok, "should close queue")
+}
+
+// renameTestField moves value of the field from one name to another
+func renameTestField(from, to string) PayloadCaster {
+	return func(payload json.RawMessage) (json.RawMessage, error) {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(payload, &fields); err != nil {
+			return nil, err
+		}
+
+		fields[to] = fields[from]
+		delete(fields, from)
+		return json.Marshal(fields)
+	}
+}
+
+// newTestVersionedEventService creates event service with the second version
+// of test payload, the first version has "message" field instead of "text"
+func newTestVersionedEventService() EventService {
+	registry := NewEventRegistry()
+	Register(registry, testEventType, EventDef[testPayload]{
+		Version:     2,
+		Upcasters:   []PayloadCaster{renameTestField("message", "text")},
+		Downcasters: []PayloadCaster{renameTestField("text", "message")},
+	})
+
+	return NewEventService(NewEventBus(), registry)
+}
+
+func TestEventService_Versions(t *testing.T) {
+	es := newTestVersionedEventService()
+	assert.Equal(t, 2, es.MaxVersion(), "should be the highest version")
+
+	t.Run("check upcast of previous version", func(t *testing.T) {
+		event, err := es.CreateEvent(&entity.RawEvent{
+			Type:    testEventType,
+			Payload: []byte(`{"message":"hello"}`),
+		})
+		if assert.Equal(t, nil, err, "should not be error") {
+			assert.Equal(t, 2, event.Version, "should be registered version")
+			assert.Equal(t, testPayload{Text: "hello"}, event.Payload, "should be upcasted payload")
+		}
+	})
+
+	t.Run("check unsupported version", func(t *testing.T) {
+		_, err := es.CreateEvent(&entity.RawEvent{
+			Type:    testEventType,
+			Version: 3,
+			Payload: []byte(`{"text":"hello"}`),
+		})
+		assert.ErrorIs(t, err, ErrUnsupportedVersion, "should be unsupported version")
+	})
+
+	t.Run("check downcast for client", func(t *testing.T) {
+		event := &entity.Event{Type: testEventType, Version: 2, Payload: testPayload{Text: "hi"}}
+
+		public, err := es.CreatePublicEvent(event, 1)
+		if assert.Equal(t, nil, err, "should not be error") {
+			assert.Equal(t, 1, public.Version, "should be version of client")
+			assert.JSONEq(t, `{"message":"hi"}`, string(public.Payload.(json.RawMessage)),
+				"should be downcasted payload")
+		}
+
+		public, err = es.CreatePublicEvent(event, 5)
+		if assert.Equal(t, nil, err, "should not be error") {
+			assert.Equal(t, 2, public.Version, "should be registered version")
+		}
+	})
+}
@@ -1,9 +1,8 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
-	"sync"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -11,8 +10,39 @@ import (
 	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
 )
 
+// Versions of the event payloads
 const (
-	NewMessageEventType = "NewMessageEvent"
+	// NewMessageEventVersion is version of entity.NewMessageEvent payload
+	NewMessageEventVersion = 1
+
+	// MessageEditedEventVersion is version of entity.MessageEditedEvent payload
+	MessageEditedEventVersion = 1
+
+	// MessageDeletedEventVersion is version of entity.MessageDeletedEvent payload
+	MessageDeletedEventVersion = 1
+
+	// MessageReactionEventVersion is version of entity.MessageReactionEvent payload
+	MessageReactionEventVersion = 1
+
+	// ConversationCreatedEventVersion is version of entity.ConversationCreatedEvent payload
+	ConversationCreatedEventVersion = 1
+
+	// ConversationMemberEventVersion is version of entity.ConversationMemberEvent payload
+	ConversationMemberEventVersion = 1
+)
+
+const (
+	NewMessageEventType          = "NewMessageEvent"
+	MessageEditedEventType       = "MessageEditedEvent"
+	MessageDeletedEventType      = "MessageDeletedEvent"
+	ReactionAddedEventType       = "ReactionAddedEvent"
+	ReactionRemovedEventType     = "ReactionRemovedEvent"
+	MessageAckEventType          = "MessageAckEvent"
+	ConversationCreatedEventType = "ConversationCreatedEvent"
+	ConversationMemberEventType  = "ConversationMemberEvent"
+	CloseEventType               = "CloseEvent"
+	ErrorEventType               = "ErrorEvent"
+	HistoryGapEventType          = "HistoryGapEvent"
 )
 
 var ErrUnknownEventType = errors.New("unknown event type")
@@ -20,61 +50,70 @@ var ErrUnknownEventType = errors.New("unknown event type")
 type EventService interface {
 	EventBus
 
-	// CreateEvent creates event from public event and returns it
-	// Errors: ErrUnknownEventType, unknown
-	CreateEvent(event *entity.PublicEvent) (*entity.Event, error)
-
-	// CreatePublicEvent creates public event from event
-	CreatePublicEvent(event *entity.Event) *entity.PublicEvent
-}
+	// CreateEvent creates event with payload decoded by the type registry and returns it,
+	// payload of previous version is upcasted to the registered version
+	// Errors: ErrUnknownEventType, ErrUnsupportedVersion, ErrInvalidPayload, unknown
+	CreateEvent(event *entity.RawEvent) (*entity.Event, error)
 
-type EventBus interface {
-	// Subscribe adds a new subscriber for a given event type
-	Subscribe(eventType string, subscriber chan<- entity.Event) int
+	// HandleEvent handles event by handler registered for its type,
+	// handlers store changes and publish events of them through the outbox
+	// Errors: ErrUnknownEventType, ErrEventNotAccepted, ErrInvalidPayload, errors of the handler
+	HandleEvent(ctx context.Context, event *entity.Event) error
 
-	// Unsubscribe deletes a subscriber by id from a given event type
-	Unsubscribe(eventType string, id int)
+	// CreatePublicEvent creates public event from event with payload of the highest
+	// version that is not greater than max version
+	// Errors: ErrUnknownEventType, ErrUnsupportedVersion, unknown
+	CreatePublicEvent(event *entity.Event, maxVersion int) (*entity.PublicEvent, error)
 
-	// Publish sends an event to all subscribers of a given event type
-	Publish(event entity.Event)
+	// MaxVersion returns the highest version of registered event types
+	MaxVersion() int
 }
 
 type eventService struct {
 	EventBus
+
+	registry *EventRegistry
 }
 
-func NewEventService() EventService {
+// NewEventService creates event service that publishes events to the bus
+// and creates events of types registered in the registry
+func NewEventService(bus EventBus, registry *EventRegistry) EventService {
 	return &eventService{
-		EventBus: NewEventBus(),
+		EventBus: bus,
+		registry: registry,
 	}
 }
 
-func (es *eventService) CreatePublicEvent(event *entity.Event) *entity.PublicEvent {
+// CreatePublicEvent is implementing interface EventService
+func (es *eventService) CreatePublicEvent(
+	event *entity.Event,
+	maxVersion int,
+) (*entity.PublicEvent, error) {
+	payload, version, err := es.registry.Encode(event.Type, event.Payload, maxVersion)
+	if err != nil {
+		return nil, err
+	}
+
 	return &entity.PublicEvent{
+		Seq:     event.Seq,
 		Type:    event.Type,
-		Payload: event.Payload,
-	}
+		Version: version,
+		Payload: payload,
+	}, nil
+}
+
+// MaxVersion is implementing interface EventService
+func (es *eventService) MaxVersion() int {
+	return es.registry.MaxVersion()
 }
 
 // CreateEvent is implementing interface EventService
-func (es *eventService) CreateEvent(event *entity.PublicEvent) (*entity.Event, error) {
-	data, err := json.Marshal(event.Payload)
+func (es *eventService) CreateEvent(event *entity.RawEvent) (*entity.Event, error) {
+	payload, version, err := es.registry.Decode(event.Type, event.Version, event.Payload)
 	if err != nil {
 		return nil, err
 	}
 
-	var payload interface{}
-	switch event.Type {
-	case NewMessageEventType:
-		var newMessageEvent entity.NewMessageEvent
-		if err := json.Unmarshal(data, &newMessageEvent); err != nil {
-			return nil, err
-		}
-		payload = newMessageEvent
-	default:
-		return nil, ErrUnknownEventType
-	}
-
 	id, err := uuid.NewV4()
 	if err != nil {
 		return nil, err
@@ -83,56 +122,13 @@ func (es *eventService) CreateEvent(event *entity.PublicEvent) (*entity.Event, e
 	return &entity.Event{
 		ID:        id,
 		Type:      event.Type,
+		Version:   version,
 		Timestamp: time.Now(),
 		Payload:   payload,
 	}, nil
 }
 
-type eventBus struct {
-	mu          sync.Mutex
-	subscribers map[string][]chan<- entity.Event
-}
-
-func NewEventBus() EventBus {
-	return &eventBus{
-		subscribers: make(map[string][]chan<- entity.Event),
-	}
-}
-
-// Subscribe is implementing interface EventBus
-func (eb *eventBus) Subscribe(eventType string, subscriber chan<- entity.Event) int {
-	eb.mu.Lock()
-	defer eb.mu.Unlock()
-
-	for i := 0; i < len(eb.subscribers[eventType]); i++ {
-		if eb.subscribers[eventType][i] == nil {
-			eb.subscribers[eventType][i] = subscriber
-			return i
-		}
-	}
-
-	id := len(eb.subscribers[eventType])
-	eb.subscribers[eventType] = append(eb.subscribers[eventType], subscriber)
-	return id
-}
-
-// Unsubscribe is implementing interface EventBus
-func (eb *eventBus) Unsubscribe(eventType string, id int) {
-	eb.mu.Lock()
-	defer eb.mu.Unlock()
-
-	eb.subscribers[eventType][id] = nil
-}
-
-// Publish is implementing interface EventBus
-func (eb *eventBus) Publish(event entity.Event) {
-	eb.mu.Lock()
-	defer eb.mu.Unlock()
-
-	subscribers := eb.subscribers[event.Type]
-	for _, subscriber := range subscribers {
-		if subscriber != nil {
-			subscriber <- event
-		}
-	}
+// HandleEvent is implementing interface EventService
+func (es *eventService) HandleEvent(ctx context.Context, event *entity.Event) error {
+	return es.registry.Handle(ctx, event)
 }
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+)
+
+const (
+	// MaxMessageLength is max count of characters in the message,
+	// it is length of the message columns in the storage
+	MaxMessageLength = 1024
+
+	// MaxClientIDLength is max length of the client id of the message
+	MaxClientIDLength = 64
+)
+
+var (
+	ErrEmptyMessage       = errors.New("empty message")
+	ErrMessageTooLong     = errors.New("message is too long")
+	ErrInvalidMessageKind = errors.New("invalid message kind")
+	ErrInvalidSender      = errors.New("invalid sender")
+	ErrClientIDTooLong    = errors.New("client id is too long")
+	ErrInvalidMessageID   = errors.New("invalid message id")
+)
+
+// NewMessageEventDef defines new message event, message of the event is stored
+// by message service on handling if sender is member of the conversation and
+// the event is published through the outbox, id, creation time and thread of the
+// event are set to the ones of the stored message. Events of system messages are
+// decoded, but only user text messages are handled, system messages are created
+// by services on behalf of users.
+func NewMessageEventDef(
+	messageService MessageService,
+	conversationService ConversationService,
+) EventDef[entity.NewMessageEvent] {
+	return EventDef[entity.NewMessageEvent]{
+		Version:  NewMessageEventVersion,
+		Validate: validateNewMessageEvent,
+		Handle: func(ctx context.Context, msg *entity.NewMessageEvent) error {
+			if msg.MessageKind.IsSystem() {
+				return fmt.Errorf("%w: %w", ErrInvalidPayload, ErrInvalidMessageKind)
+			}
+
+			err := conversationService.CheckMember(ctx, msg.ConversationID, msg.SenderID)
+			if err != nil {
+				return err
+			}
+
+			stored := &entity.Message{
+				ConversationID: msg.ConversationID,
+				SenderID:       msg.SenderID,
+				MessageKind:    msg.MessageKind,
+				Message:        msg.Message,
+				CreatedAt:      time.Now(),
+				ClientID:       msg.ClientID,
+			}
+			if msg.ReplyTo != "" {
+				replyTo := uuid.FromStringOrNil(msg.ReplyTo)
+				stored.ReplyTo = &replyTo
+			}
+			if _, err := messageService.Create(ctx, stored); err != nil {
+				return err
+			}
+
+			msg.ID = stored.ID.String()
+			msg.Message = stored.Message
+			msg.CreatedAt = stored.CreatedAt
+			msg.UpdateAt = stored.CreatedAt
+			msg.ReplyTo = uuidString(stored.ReplyTo)
+			msg.ThreadID = uuidString(stored.ThreadID)
+			return nil
+		},
+	}
+}
+
+// validateNewMessageEvent validates new message
+func validateNewMessageEvent(msg *entity.NewMessageEvent) error {
+	switch {
+	case msg.SenderID <= 0:
+		return ErrInvalidSender
+	case msg.ConversationID < 0:
+		return ErrInvalidConversation
+	case !msg.MessageKind.IsValid():
+		return ErrInvalidMessageKind
+	case strings.TrimSpace(msg.Message) == "":
+		return ErrEmptyMessage
+	case utf8.RuneCountInString(msg.Message) > MaxMessageLength:
+		return ErrMessageTooLong
+	case len(msg.ClientID) > MaxClientIDLength:
+		return ErrClientIDTooLong
+	case msg.ReplyTo != "" && uuid.FromStringOrNil(msg.ReplyTo) == uuid.Nil:
+		return ErrInvalidMessageID
+	}
+
+	return nil
+}
+
+// MessageEditedEventDef defines message edited event, message of the event is edited
+// by message service on handling if sender of the message is still member of its
+// conversation and the event is published through the outbox, conversation, text and
+// update time of the event are set to the ones of the edited message
+func MessageEditedEventDef(
+	messageService MessageService,
+	conversationService ConversationService,
+) EventDef[entity.MessageEditedEvent] {
+	return EventDef[entity.MessageEditedEvent]{
+		Version:  MessageEditedEventVersion,
+		Validate: validateMessageEditedEvent,
+		Handle: func(ctx context.Context, msg *entity.MessageEditedEvent) error {
+			id := uuid.FromStringOrNil(msg.ID)
+			err := checkMessageMember(
+				ctx,
+				messageService,
+				conversationService,
+				id,
+				msg.SenderID,
+			)
+			if err != nil {
+				return err
+			}
+
+			edited, err := messageService.Edit(ctx, id, msg.SenderID, msg.Message)
+			if err != nil {
+				return err
+			}
+
+			msg.ConversationID = edited.ConversationID
+			msg.Message = edited.Message
+			msg.UpdatedAt = edited.UpdatedAt
+			return nil
+		},
+	}
+}
+
+// MessageDeletedEventDef defines message deleted event, message of the event is deleted
+// by message service on handling if sender of the message is still member of its
+// conversation and the event is published through the outbox, conversation and
+// deletion time of the event are set to the ones of the deleted message
+func MessageDeletedEventDef(
+	messageService MessageService,
+	conversationService ConversationService,
+) EventDef[entity.MessageDeletedEvent] {
+	return EventDef[entity.MessageDeletedEvent]{
+		Version:  MessageDeletedEventVersion,
+		Validate: validateMessageDeletedEvent,
+		Handle: func(ctx context.Context, msg *entity.MessageDeletedEvent) error {
+			id := uuid.FromStringOrNil(msg.ID)
+			err := checkMessageMember(
+				ctx,
+				messageService,
+				conversationService,
+				id,
+				msg.SenderID,
+			)
+			if err != nil {
+				return err
+			}
+
+			deleted, err := messageService.Delete(ctx, id, msg.SenderID)
+			if err != nil {
+				return err
+			}
+
+			msg.ConversationID = deleted.ConversationID
+			msg.DeletedAt = *deleted.DeletedAt
+			return nil
+		},
+	}
+}
+
+// checkMessageMember checks that user is member of the conversation of the message
+// Errors: repo.ErrMessageNotFound, ErrNotConversationMember, unknown
+func checkMessageMember(
+	ctx context.Context,
+	messageService MessageService,
+	conversationService ConversationService,
+	id uuid.UUID,
+	userId int64,
+) error {
+	msg, err := messageService.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return conversationService.CheckMember(ctx, msg.ConversationID, userId)
+}
+
+// validateMessageEditedEvent validates edit of the message
+func validateMessageEditedEvent(msg *entity.MessageEditedEvent) error {
+	switch {
+	case uuid.FromStringOrNil(msg.ID) == uuid.Nil:
+		return ErrInvalidMessageID
+	case msg.SenderID <= 0:
+		return ErrInvalidSender
+	case strings.TrimSpace(msg.Message) == "":
+		return ErrEmptyMessage
+	case utf8.RuneCountInString(msg.Message) > MaxMessageLength:
+		return ErrMessageTooLong
+	case len(msg.ClientID) > MaxClientIDLength:
+		return ErrClientIDTooLong
+	}
+
+	return nil
+}
+
+// validateMessageDeletedEvent validates deleting of the message
+func validateMessageDeletedEvent(msg *entity.MessageDeletedEvent) error {
+	switch {
+	case uuid.FromStringOrNil(msg.ID) == uuid.Nil:
+		return ErrInvalidMessageID
+	case msg.SenderID <= 0:
+		return ErrInvalidSender
+	case len(msg.ClientID) > MaxClientIDLength:
+		return ErrClientIDTooLong
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+)
+
+func TestValidateMessageLength(t *testing.T) {
+	newMessage := func(text string) *entity.NewMessageEvent {
+		return &entity.NewMessageEvent{
+			SenderID:    1,
+			MessageKind: entity.UserTextMessage,
+			Message:     text,
+		}
+	}
+	editedMessage := func(text string) *entity.MessageEditedEvent {
+		return &entity.MessageEditedEvent{
+			ID:       uuid.Must(uuid.NewV4()).String(),
+			SenderID: 1,
+			Message:  text,
+		}
+	}
+
+	t.Run("check message of max length", func(t *testing.T) {
+		text := strings.Repeat("я", MaxMessageLength)
+		assert.Equal(t, nil, validateNewMessageEvent(newMessage(text)), "should be valid")
+		assert.Equal(t, nil, validateMessageEditedEvent(editedMessage(text)), "should be valid")
+	})
+
+	t.Run("check message longer than max length", func(t *testing.T) {
+		text := strings.Repeat("a", MaxMessageLength+1)
+		assert.ErrorIs(
+			t,
+			validateNewMessageEvent(newMessage(text)),
+			ErrMessageTooLong,
+			"should be too long",
+		)
+		assert.ErrorIs(
+			t,
+			validateMessageEditedEvent(editedMessage(text)),
+			ErrMessageTooLong,
+			"should be too long",
+		)
+	})
+}
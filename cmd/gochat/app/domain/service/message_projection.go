@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
+	"github.com/sazonovItas/gochat-tcp/pkg/es"
+)
+
+// Names of the projections of the message streams
+const (
+	UnreadCountersProjection = "unread_counters"
+	LastMessagesProjection   = "last_messages"
+)
+
+// unreadCountersProjection counts unread messages of conversations by users
+// from created messages and reading of conversations
+type unreadCountersProjection struct {
+	repository repo.UnreadCounterRepository
+}
+
+func NewUnreadCountersProjection(repository repo.UnreadCounterRepository) es.Projection {
+	return &unreadCountersProjection{repository: repository}
+}
+
+// ProjectionName is implementing interface es.Projection
+func (p *unreadCountersProjection) ProjectionName() string {
+	return UnreadCountersProjection
+}
+
+// Handle is implementing interface es.Projection
+func (p *unreadCountersProjection) Handle(ctx context.Context, e es.RecordedEvent) error {
+	switch payload := e.Payload().(type) {
+	case entity.MessageCreated:
+		return p.repository.Increment(ctx, payload.ConversationID, payload.SenderID, e.Position)
+	case entity.ConversationRead:
+		return p.repository.Reset(ctx, payload.ConversationID, payload.UserID, e.Position)
+	}
+
+	return nil
+}
+
+// Reset is implementing interface es.Projection
+func (p *unreadCountersProjection) Reset(ctx context.Context) error {
+	return p.repository.Clear(ctx)
+}
+
+// lastMessagesProjection keeps the last created message of conversations, text of it
+// is updated on editing and cleared on deleting, so deleted text is not shown
+type lastMessagesProjection struct {
+	repository repo.LastMessageRepository
+}
+
+func NewLastMessagesProjection(repository repo.LastMessageRepository) es.Projection {
+	return &lastMessagesProjection{repository: repository}
+}
+
+// ProjectionName is implementing interface es.Projection
+func (p *lastMessagesProjection) ProjectionName() string {
+	return LastMessagesProjection
+}
+
+// Handle is implementing interface es.Projection
+func (p *lastMessagesProjection) Handle(ctx context.Context, e es.RecordedEvent) error {
+	switch payload := e.Payload().(type) {
+	case entity.MessageCreated:
+		return p.repository.Save(ctx, &entity.LastMessage{Message: payload.Message}, e.Position)
+	case entity.MessageEdited:
+		return p.repository.Update(
+			ctx,
+			payload.ConversationID,
+			payload.ID,
+			payload.Message,
+			e.Position,
+		)
+	case entity.MessageDeleted:
+		return p.repository.Update(ctx, payload.ConversationID, payload.ID, "", e.Position)
+	}
+
+	return nil
+}
+
+// Reset is implementing interface es.Projection
+func (p *lastMessagesProjection) Reset(ctx context.Context) error {
+	return p.repository.Clear(ctx)
+}
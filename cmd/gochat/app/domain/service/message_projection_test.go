@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
+	"github.com/sazonovItas/gochat-tcp/pkg/es"
+	"github.com/sazonovItas/gochat-tcp/pkg/es/event"
+)
+
+// testLastMessageRepository is in-memory read model of the last messages
+type testLastMessageRepository struct {
+	repo.LastMessageRepository
+	messages map[int64]entity.LastMessage
+}
+
+func (r *testLastMessageRepository) Save(
+	_ context.Context,
+	msg *entity.LastMessage,
+	_ int64,
+) error {
+	r.messages[msg.ConversationID] = *msg
+	return nil
+}
+
+func (r *testLastMessageRepository) Update(
+	_ context.Context,
+	conversationID int64,
+	id uuid.UUID,
+	message string,
+	_ int64,
+) error {
+	msg, ok := r.messages[conversationID]
+	if ok && msg.ID == id {
+		msg.Message.Message = message
+		r.messages[conversationID] = msg
+	}
+
+	return nil
+}
+
+func TestLastMessagesProjection(t *testing.T) {
+	repository := &testLastMessageRepository{messages: make(map[int64]entity.LastMessage)}
+	projection := NewLastMessagesProjection(repository)
+
+	var position int64
+	handle := func(name string, payload event.EventPayload) {
+		position++
+		err := projection.Handle(context.Background(), es.RecordedEvent{
+			Event:    event.NewEvent(name, payload),
+			Position: position,
+		})
+		assert.Equal(t, nil, err, "should not be error")
+	}
+
+	first, last := newTestMessage(1, ""), newTestMessage(1, "")
+	first.ID, last.ID = uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())
+	handle(MessageCreatedEvent, entity.MessageCreated{Message: *first})
+	handle(MessageCreatedEvent, entity.MessageCreated{Message: *last})
+
+	t.Run("check edited message", func(t *testing.T) {
+		handle(MessageEditedEvent, entity.MessageEdited{ID: first.ID, Message: "edited"})
+		assert.Equal(t, last.Message, repository.messages[0].Message.Message,
+			"should not update message that is not the last one")
+
+		handle(MessageEditedEvent, entity.MessageEdited{ID: last.ID, Message: "edited"})
+		assert.Equal(t, "edited", repository.messages[0].Message.Message,
+			"should update text of the last message")
+	})
+
+	t.Run("check deleted message", func(t *testing.T) {
+		handle(MessageDeletedEvent, entity.MessageDeleted{ID: last.ID, DeletedAt: time.Now()})
+		assert.Equal(t, "", repository.messages[0].Message.Message,
+			"should clear text of the last message")
+	})
+}
@@ -2,7 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gofrs/uuid"
 
@@ -11,62 +15,210 @@ import (
 	"github.com/sazonovItas/gochat-tcp/pkg/cache"
 )
 
+// MessageEditWindow is time after creation of the message while it could be edited
+const MessageEditWindow = 15 * time.Minute
+
+var (
+	ErrNotMessageSender  = errors.New("user is not sender of the message")
+	ErrMessageDeleted    = errors.New("message is deleted")
+	ErrEditWindowExpired = errors.New("time to edit the message is expired")
+	ErrInvalidReply      = errors.New("invalid reply")
+)
+
 type MessageService interface {
-	// Create creates new message and returns it's id
-	// Errors: ErrGenerateUUIDFailed, ErrMessageCreateFailed, unknown
+	// Create creates new message and returns it's id,
+	// new message event is published through the outbox, if sender already has
+	// message with the same client id then msg is replaced by the stored one
+	// and no events are published, so retries of sending are idempotent.
+	// If message replies to another one it is added to the thread of that message.
+	// Errors: ErrGenerateUUIDFailed, ErrMessageCreateFailed, ErrInvalidReply, unknown
 	Create(ctx context.Context, msg *entity.Message) (uuid.UUID, error)
 
 	// FindById finds message by id
 	// Errors: ErrMessageNotFound, unknown
 	FindById(ctx context.Context, id uuid.UUID) (*entity.Message, error)
 
-	// Update updates message by id
-	// Errors: ErrMessageUpdateFailed, unknown
-	Update(ctx context.Context, message *entity.Message) error
+	// GetThreadReplies returns limits count of replies of the thread next to timestamp
+	// Errors: ErrNoMessages, unknown
+	GetThreadReplies(
+		ctx context.Context,
+		threadId uuid.UUID,
+		timestamp time.Time,
+		limit int,
+	) ([]entity.Message, error)
+
+	// Edit edits text of the user text message by its sender until edit window
+	// is expired and returns edited message, message edited event is published
+	// through the outbox, editing with the same text does nothing
+	// Errors: repo.ErrMessageNotFound, ErrNotMessageSender, ErrInvalidMessageKind,
+	// ErrMessageDeleted, ErrEditWindowExpired, ErrEmptyMessage, ErrMessageTooLong,
+	// repo.ErrMessageUpdateFailed, unknown
+	Edit(ctx context.Context, id uuid.UUID, senderId int64, text string) (*entity.Message, error)
 
-	// Delete deletes message by id
-	// Errors: ErrMessageDeleteFailed, unknown
-	Delete(ctx context.Context, id uuid.UUID) error
+	// Delete deletes user text message by its sender and returns tombstone of it,
+	// message deleted event is published through the outbox, deleting of deleted
+	// message does nothing
+	// Errors: repo.ErrMessageNotFound, ErrNotMessageSender, ErrInvalidMessageKind,
+	// repo.ErrMessageDeleteFailed, unknown
+	Delete(ctx context.Context, id uuid.UUID, senderId int64) (*entity.Message, error)
 
-	// GetConvMessagesPrevTimestamp returns limits count of messages previous to timestamp
+	// GetConvMessagesPrevTimestamp returns limits count of messages of the conversation
+	// previous to timestamp
 	// Errors: ErrNoMessages, unknown
 	GetConvMessagesPrevTimestamp(
 		ctx context.Context,
+		conversationId int64,
 		timestamp time.Time,
 		limit int,
 	) ([]entity.Message, error)
 
-	// GetConvMessagesNextTimestamp returns limits count of messages next to timestamp
+	// GetConvMessagesNextTimestamp returns limits count of messages of the conversation
+	// next to timestamp
 	// Errors: ErrNoMessages, unknown
 	GetConvMessagesNextTimestamp(
 		ctx context.Context,
+		conversationId int64,
 		timestamp time.Time,
 		limit int,
 	) ([]entity.Message, error)
 
-	// GetConvMessagesBetweenTimestamp returns messages between timestamp
+	// GetConvMessagesBetweenTimestamp returns messages of the conversation between timestamp
 	// Errors: ErrNoMessages, unknown
 	GetConvMessagesBetweenTimestamp(
 		ctx context.Context,
+		conversationId int64,
 		from, to time.Time,
 	) ([]entity.Message, error)
 }
 
 type messageService struct {
 	repository repo.MessageRepository
+	outbox     *OutboxDispatcher
 	cache      cache.Cache[entity.Message]
 }
 
-func NewMessageService(repository repo.MessageRepository, opts *cache.CacheOpts) MessageService {
+// NewMessageService creates message service, outbox dispatcher is notified
+// about new events if it is not nil
+func NewMessageService(
+	repository repo.MessageRepository,
+	outbox *OutboxDispatcher,
+	opts *cache.CacheOpts,
+) MessageService {
 	return &messageService{
 		repository: repository,
+		outbox:     outbox,
 		cache:      nil,
 	}
 }
 
 // Create is implementing interface MessageService
 func (ms *messageService) Create(ctx context.Context, msg *entity.Message) (uuid.UUID, error) {
-	return ms.repository.Create(ctx, msg)
+	const op = "gochat.app.domain.service.messageService.Create"
+
+	// id is generated before creating, because it is a part of the event
+	if msg.ID == uuid.Nil {
+		id, err := uuid.NewV4()
+		if err != nil {
+			return id, repo.ErrGenerateUUIDFailed
+		}
+		msg.ID = id
+	}
+
+	if err := ms.setThread(ctx, msg); err != nil {
+		return msg.ID, err
+	}
+
+	event, err := newMessageOutboxEvent(msg)
+	if err != nil {
+		return msg.ID, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := ms.repository.Create(ctx, msg, event)
+	if errors.Is(err, repo.ErrMessageDuplicate) {
+		stored, err := ms.repository.FindByClientId(ctx, msg.SenderID, msg.ClientID)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		*msg = *stored
+		return msg.ID, nil
+	}
+	if err != nil {
+		return id, err
+	}
+
+	// message streams are appended by the outbox dispatcher
+	if ms.outbox != nil {
+		ms.outbox.Notify()
+	}
+
+	return id, nil
+}
+
+// newMessageOutboxEvent creates outbox event of the created message
+// Errors: ErrGenerateUUIDFailed, unknown
+func newMessageOutboxEvent(msg *entity.Message) (*entity.OutboxEvent, error) {
+	return newOutboxEvent(
+		NewMessageEventType,
+		NewMessageEventVersion,
+		msg.CreatedAt,
+		entity.NewMessageEvent{
+			ID:             msg.ID.String(),
+			ClientID:       msg.ClientID,
+			ConversationID: msg.ConversationID,
+			SenderID:       msg.SenderID,
+			MessageKind:    msg.MessageKind,
+			Message:        msg.Message,
+			CreatedAt:      msg.CreatedAt,
+			UpdateAt:       msg.CreatedAt,
+			ReplyTo:        uuidString(msg.ReplyTo),
+			ThreadID:       uuidString(msg.ThreadID),
+		},
+	)
+}
+
+// setThread sets thread of the reply to the thread of the message it replies to,
+// message without thread is root of the new one
+// Errors: ErrInvalidReply, unknown
+func (ms *messageService) setThread(ctx context.Context, msg *entity.Message) error {
+	const op = "gochat.app.domain.service.messageService.setThread"
+
+	msg.ThreadID = nil
+	if msg.ReplyTo == nil {
+		return nil
+	}
+
+	parent, err := ms.repository.FindById(ctx, *msg.ReplyTo)
+	if err != nil {
+		if errors.Is(err, repo.ErrMessageNotFound) {
+			return fmt.Errorf("%w: message to reply is not found", ErrInvalidReply)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	switch {
+	case parent.ConversationID != msg.ConversationID:
+		return fmt.Errorf("%w: message to reply is of another conversation", ErrInvalidReply)
+	case parent.IsDeleted():
+		return fmt.Errorf("%w: message to reply is deleted", ErrInvalidReply)
+	}
+
+	threadId := parent.ID
+	if parent.ThreadID != nil {
+		threadId = *parent.ThreadID
+	}
+	msg.ThreadID = &threadId
+
+	return nil
+}
+
+// uuidString returns string of the optional id, it is empty if id is not set
+func uuidString(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+
+	return id.String()
 }
 
 // FindById is implementing interface MessageService
@@ -74,38 +226,172 @@ func (ms *messageService) FindById(ctx context.Context, id uuid.UUID) (*entity.M
 	return ms.repository.FindById(ctx, id)
 }
 
-// Update is implementing interface MessageService
-func (ms *messageService) Update(ctx context.Context, msg *entity.Message) error {
-	return ms.repository.Update(ctx, msg)
+// GetThreadReplies is implementing interface MessageService
+func (ms *messageService) GetThreadReplies(
+	ctx context.Context,
+	threadId uuid.UUID,
+	timestamp time.Time,
+	limit int,
+) ([]entity.Message, error) {
+	return ms.repository.GetThreadRepliesNextTimestamp(ctx, threadId, timestamp, limit)
+}
+
+// Edit is implementing interface MessageService
+func (ms *messageService) Edit(
+	ctx context.Context,
+	id uuid.UUID,
+	senderId int64,
+	text string,
+) (*entity.Message, error) {
+	const op = "gochat.app.domain.service.messageService.Edit"
+
+	msg, err := ms.findOwn(ctx, id, senderId)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	switch {
+	case msg.IsDeleted():
+		return nil, ErrMessageDeleted
+	case msg.Message == text:
+		// retry of the edit
+		return msg, nil
+	case now.Sub(msg.CreatedAt) > MessageEditWindow:
+		return nil, ErrEditWindowExpired
+	case strings.TrimSpace(text) == "":
+		return nil, ErrEmptyMessage
+	case utf8.RuneCountInString(text) > MaxMessageLength:
+		return nil, ErrMessageTooLong
+	}
+
+	msg.Message = text
+	msg.UpdatedAt = now
+
+	event, err := newOutboxEvent(
+		MessageEditedEventType,
+		MessageEditedEventVersion,
+		now,
+		entity.MessageEditedEvent{
+			ID:             msg.ID.String(),
+			ConversationID: msg.ConversationID,
+			SenderID:       msg.SenderID,
+			Message:        msg.Message,
+			UpdatedAt:      msg.UpdatedAt,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := ms.repository.Update(ctx, msg, event); err != nil {
+		return nil, err
+	}
+
+	if ms.outbox != nil {
+		ms.outbox.Notify()
+	}
+
+	return msg, nil
 }
 
 // Delete is implementing interface MessageService
-func (ms *messageService) Delete(ctx context.Context, id uuid.UUID) error {
-	return ms.repository.Delete(ctx, id)
+func (ms *messageService) Delete(
+	ctx context.Context,
+	id uuid.UUID,
+	senderId int64,
+) (*entity.Message, error) {
+	const op = "gochat.app.domain.service.messageService.Delete"
+
+	msg, err := ms.findOwn(ctx, id, senderId)
+	if err != nil {
+		return nil, err
+	}
+
+	// retry of the deleting
+	if msg.IsDeleted() {
+		return msg, nil
+	}
+
+	now := time.Now()
+	msg.Message = ""
+	msg.UpdatedAt = now
+	msg.DeletedAt = &now
+
+	event, err := newOutboxEvent(
+		MessageDeletedEventType,
+		MessageDeletedEventVersion,
+		now,
+		entity.MessageDeletedEvent{
+			ID:             msg.ID.String(),
+			ConversationID: msg.ConversationID,
+			SenderID:       msg.SenderID,
+			DeletedAt:      now,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := ms.repository.Delete(ctx, msg.ID, now, event); err != nil {
+		return nil, err
+	}
+
+	if ms.outbox != nil {
+		ms.outbox.Notify()
+	}
+
+	return msg, nil
+}
+
+// findOwn finds user text message of the sender by id, system messages
+// could not be changed by users
+// Errors: repo.ErrMessageNotFound, ErrNotMessageSender, ErrInvalidMessageKind, unknown
+func (ms *messageService) findOwn(
+	ctx context.Context,
+	id uuid.UUID,
+	senderId int64,
+) (*entity.Message, error) {
+	msg, err := ms.repository.FindById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case msg.SenderID != senderId:
+		return nil, ErrNotMessageSender
+	case msg.MessageKind.IsSystem():
+		return nil, ErrInvalidMessageKind
+	}
+
+	return msg, nil
 }
 
 // GetConvMessagesPrevTimestamp is implementing interface MessageService
 func (ms *messageService) GetConvMessagesPrevTimestamp(
 	ctx context.Context,
+	conversationId int64,
 	timestamp time.Time,
 	limit int,
 ) ([]entity.Message, error) {
-	return ms.repository.GetConvMessagesPrevTimestamp(ctx, timestamp, limit)
+	return ms.repository.GetConvMessagesPrevTimestamp(ctx, conversationId, timestamp, limit)
 }
 
 // GetConvMessagesNextTimestamp is implementing interface MessageService
 func (ms *messageService) GetConvMessagesNextTimestamp(
 	ctx context.Context,
+	conversationId int64,
 	timestamp time.Time,
 	limit int,
 ) ([]entity.Message, error) {
-	return ms.repository.GetConvMessagesNextTimestamp(ctx, timestamp, limit)
+	return ms.repository.GetConvMessagesNextTimestamp(ctx, conversationId, timestamp, limit)
 }
 
 // GetConvMessagesBetweenTimestamp is implementing interface MessageService
 func (ms *messageService) GetConvMessagesBetweenTimestamp(
 	ctx context.Context,
+	conversationId int64,
 	from, to time.Time,
 ) ([]entity.Message, error) {
-	return ms.repository.GetConvMessagesBetweenTimestamp(ctx, from, to)
+	return ms.repository.GetConvMessagesBetweenTimestamp(ctx, conversationId, from, to)
 }
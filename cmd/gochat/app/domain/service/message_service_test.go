@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
+)
+
+// testMessageRepository is in-memory message repository with unique client ids of senders
+type testMessageRepository struct {
+	repo.MessageRepository
+
+	messages []entity.Message
+	events   []*entity.OutboxEvent
+}
+
+func (r *testMessageRepository) Create(
+	_ context.Context,
+	msg *entity.Message,
+	events ...*entity.OutboxEvent,
+) (uuid.UUID, error) {
+	if _, err := r.FindByClientId(context.Background(), msg.SenderID, msg.ClientID); err == nil {
+		return msg.ID, repo.ErrMessageDuplicate
+	}
+
+	r.messages = append(r.messages, *msg)
+	r.events = append(r.events, events...)
+	for i := range r.messages {
+		if msg.ThreadID != nil && r.messages[i].ID == *msg.ThreadID {
+			r.messages[i].ReplyCount++
+		}
+	}
+	return msg.ID, nil
+}
+
+func (r *testMessageRepository) FindByClientId(
+	_ context.Context,
+	senderId int64,
+	clientId string,
+) (*entity.Message, error) {
+	for i := range r.messages {
+		msg := r.messages[i]
+		if clientId != "" && msg.SenderID == senderId && msg.ClientID == clientId {
+			return &msg, nil
+		}
+	}
+
+	return nil, repo.ErrMessageNotFound
+}
+
+func (r *testMessageRepository) FindById(
+	_ context.Context,
+	id uuid.UUID,
+) (*entity.Message, error) {
+	for i := range r.messages {
+		if r.messages[i].ID == id {
+			msg := r.messages[i]
+			return &msg, nil
+		}
+	}
+
+	return nil, repo.ErrMessageNotFound
+}
+
+func (r *testMessageRepository) Update(
+	_ context.Context,
+	message *entity.Message,
+	events ...*entity.OutboxEvent,
+) error {
+	for i := range r.messages {
+		if r.messages[i].ID == message.ID && !r.messages[i].IsDeleted() {
+			r.messages[i].Message = message.Message
+			r.messages[i].UpdatedAt = message.UpdatedAt
+			r.events = append(r.events, events...)
+			return nil
+		}
+	}
+
+	return repo.ErrMessageUpdateFailed
+}
+
+func (r *testMessageRepository) Delete(
+	_ context.Context,
+	id uuid.UUID,
+	deletedAt time.Time,
+	events ...*entity.OutboxEvent,
+) error {
+	for i := range r.messages {
+		if r.messages[i].ID == id && !r.messages[i].IsDeleted() {
+			r.messages[i].Message = ""
+			r.messages[i].UpdatedAt = deletedAt
+			r.messages[i].DeletedAt = &deletedAt
+			r.events = append(r.events, events...)
+			return nil
+		}
+	}
+
+	return repo.ErrMessageDeleteFailed
+}
+
+func newTestMessage(senderId int64, clientId string) *entity.Message {
+	return &entity.Message{
+		SenderID:    senderId,
+		MessageKind: entity.UserTextMessage,
+		Message:     "hello",
+		CreatedAt:   time.Now(),
+		ClientID:    clientId,
+	}
+}
+
+func TestMessageService_CreateIdempotent(t *testing.T) {
+	repository := &testMessageRepository{}
+	ms := NewMessageService(repository, nil, nil)
+
+	first := newTestMessage(1, "client")
+	id, err := ms.Create(context.Background(), first)
+	assert.Equal(t, nil, err, "should not be error")
+
+	t.Run("check retry with the same client id", func(t *testing.T) {
+		retry := newTestMessage(1, "client")
+		retry.CreatedAt = first.CreatedAt.Add(time.Second)
+
+		retryId, err := ms.Create(context.Background(), retry)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, id, retryId, "should be id of the stored message")
+		assert.Equal(t, first.CreatedAt, retry.CreatedAt, "should be stored creation time")
+		assert.Equal(t, 1, len(repository.messages), "should not create message twice")
+		assert.Equal(t, 1, len(repository.events), "should not publish event twice")
+	})
+
+	t.Run("check the same client id of another sender", func(t *testing.T) {
+		otherId, err := ms.Create(context.Background(), newTestMessage(2, "client"))
+		assert.Equal(t, nil, err, "should not be error")
+		assert.NotEqual(t, id, otherId, "should create new message")
+	})
+
+	t.Run("check messages without client id", func(t *testing.T) {
+		_, err := ms.Create(context.Background(), newTestMessage(1, ""))
+		assert.Equal(t, nil, err, "should not be error")
+		_, err = ms.Create(context.Background(), newTestMessage(1, ""))
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 4, len(repository.messages), "should create all messages")
+	})
+}
+
+func TestMessageService_Edit(t *testing.T) {
+	repository := &testMessageRepository{}
+	ms := NewMessageService(repository, nil, nil)
+
+	msg := newTestMessage(1, "")
+	id, err := ms.Create(context.Background(), msg)
+	assert.Equal(t, nil, err, "should not be error")
+
+	t.Run("check edit by sender", func(t *testing.T) {
+		edited, err := ms.Edit(context.Background(), id, 1, "edited")
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, "edited", edited.Message, "should be edited text")
+		assert.Equal(t, "edited", repository.messages[0].Message, "should store edited text")
+		assert.True(t, edited.UpdatedAt.After(msg.CreatedAt), "should set update time")
+
+		if assert.Equal(t, 2, len(repository.events), "should add event to the outbox") {
+			assert.Equal(
+				t,
+				MessageEditedEventType,
+				repository.events[1].EventType,
+				"should be message edited event",
+			)
+		}
+	})
+
+	t.Run("check retry of the edit", func(t *testing.T) {
+		_, err := ms.Edit(context.Background(), id, 1, "edited")
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 2, len(repository.events), "should not publish event twice")
+	})
+
+	t.Run("check edit longer than max length", func(t *testing.T) {
+		_, err := ms.Edit(context.Background(), id, 1, strings.Repeat("a", MaxMessageLength+1))
+		assert.ErrorIs(t, err, ErrMessageTooLong, "should be too long")
+	})
+
+	t.Run("check edit by not sender", func(t *testing.T) {
+		_, err := ms.Edit(context.Background(), id, 2, "stolen")
+		assert.ErrorIs(t, err, ErrNotMessageSender, "should not be sender")
+	})
+
+	t.Run("check edit after window", func(t *testing.T) {
+		old := newTestMessage(1, "")
+		old.CreatedAt = time.Now().Add(-MessageEditWindow - time.Minute)
+		oldId, err := ms.Create(context.Background(), old)
+		assert.Equal(t, nil, err, "should not be error")
+
+		_, err = ms.Edit(context.Background(), oldId, 1, "late")
+		assert.ErrorIs(t, err, ErrEditWindowExpired, "should be expired edit window")
+	})
+
+	t.Run("check edit of system message", func(t *testing.T) {
+		system := newTestMessage(1, "")
+		system.MessageKind = entity.AddingUserMessage
+		systemId, err := ms.Create(context.Background(), system)
+		assert.Equal(t, nil, err, "should not be error")
+
+		_, err = ms.Edit(context.Background(), systemId, 1, "edited")
+		assert.ErrorIs(t, err, ErrInvalidMessageKind, "should not edit system message")
+	})
+}
+
+func TestMessageService_Delete(t *testing.T) {
+	repository := &testMessageRepository{}
+	ms := NewMessageService(repository, nil, nil)
+
+	id, err := ms.Create(context.Background(), newTestMessage(1, ""))
+	assert.Equal(t, nil, err, "should not be error")
+
+	t.Run("check delete by not sender", func(t *testing.T) {
+		_, err := ms.Delete(context.Background(), id, 2)
+		assert.ErrorIs(t, err, ErrNotMessageSender, "should not be sender")
+	})
+
+	t.Run("check tombstone of deleted message", func(t *testing.T) {
+		deleted, err := ms.Delete(context.Background(), id, 1)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.True(t, deleted.IsDeleted(), "should be deleted")
+		assert.Equal(t, "", repository.messages[0].Message, "should clear text")
+		assert.True(t, repository.messages[0].IsDeleted(), "should keep tombstone")
+		assert.Equal(t, 2, len(repository.events), "should add event to the outbox")
+	})
+
+	t.Run("check retry of the delete", func(t *testing.T) {
+		_, err := ms.Delete(context.Background(), id, 1)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 2, len(repository.events), "should not publish event twice")
+	})
+
+	t.Run("check edit of deleted message", func(t *testing.T) {
+		_, err := ms.Edit(context.Background(), id, 1, "edited")
+		assert.ErrorIs(t, err, ErrMessageDeleted, "should not edit deleted message")
+	})
+}
+
+func TestMessageService_Replies(t *testing.T) {
+	repository := &testMessageRepository{}
+	ms := NewMessageService(repository, nil, nil)
+
+	rootId, err := ms.Create(context.Background(), newTestMessage(1, ""))
+	assert.Equal(t, nil, err, "should not be error")
+
+	reply := newTestMessage(2, "")
+	reply.ReplyTo = &rootId
+	replyId, err := ms.Create(context.Background(), reply)
+	assert.Equal(t, nil, err, "should not be error")
+
+	t.Run("check thread of the reply", func(t *testing.T) {
+		if assert.NotNil(t, reply.ThreadID, "should set thread") {
+			assert.Equal(t, rootId, *reply.ThreadID, "should be root of the thread")
+		}
+		assert.Equal(t, 1, repository.messages[0].ReplyCount, "should count reply of the root")
+
+		var event entity.NewMessageEvent
+		err := json.Unmarshal(repository.events[1].Payload, &event)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, rootId.String(), event.ThreadID, "should be thread of the event")
+		assert.Equal(t, rootId.String(), event.ReplyTo, "should be reply of the event")
+	})
+
+	t.Run("check reply to the reply", func(t *testing.T) {
+		nested := newTestMessage(1, "")
+		nested.ReplyTo = &replyId
+		_, err := ms.Create(context.Background(), nested)
+		assert.Equal(t, nil, err, "should not be error")
+
+		if assert.NotNil(t, nested.ThreadID, "should set thread") {
+			assert.Equal(t, rootId, *nested.ThreadID, "should be thread of the replied message")
+		}
+		assert.Equal(t, 2, repository.messages[0].ReplyCount, "should count reply of the root")
+	})
+
+	t.Run("check invalid replies", func(t *testing.T) {
+		missing := uuid.Must(uuid.NewV4())
+		invalid := newTestMessage(1, "")
+		invalid.ReplyTo = &missing
+		_, err := ms.Create(context.Background(), invalid)
+		assert.ErrorIs(t, err, ErrInvalidReply, "should not reply to missing message")
+
+		other := newTestMessage(1, "")
+		other.ConversationID = 1
+		other.ReplyTo = &rootId
+		_, err = ms.Create(context.Background(), other)
+		assert.ErrorIs(t, err, ErrInvalidReply, "should not reply to another conversation")
+	})
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/pkg/es"
+	"github.com/sazonovItas/gochat-tcp/pkg/es/event"
+)
+
+// Aggregates and events of the message streams in the event store
+const (
+	MessageAggregate    = "message"
+	ReadMarkerAggregate = "read_marker"
+
+	MessageCreatedEvent   = "MessageCreated"
+	MessageEditedEvent    = "MessageEdited"
+	MessageDeletedEvent   = "MessageDeleted"
+	ConversationReadEvent = "ConversationRead"
+
+	readMarkerSnapshot = "ReadMarkerV1"
+)
+
+var (
+	ErrMessageAlreadyCreated = errors.New("message is already created")
+	ErrMessageNotCreated     = errors.New("message is not created")
+	ErrStaleMessageEvent     = errors.New("message is already changed by newer event")
+)
+
+// NewMessageStreamRegistry creates registry of the payloads of the message streams
+func NewMessageStreamRegistry() *es.Registry {
+	registry := es.NewRegistry()
+	es.Register[entity.MessageCreated](registry, MessageCreatedEvent)
+	es.Register[entity.MessageEdited](registry, MessageEditedEvent)
+	es.Register[entity.MessageDeleted](registry, MessageDeletedEvent)
+	es.Register[entity.ConversationRead](registry, ConversationReadEvent)
+	es.Register[readMarkerState](registry, readMarkerSnapshot)
+
+	return registry
+}
+
+// messageAggregate represents stream of the message events with message id
+type messageAggregate struct {
+	es.Aggregate
+	created   bool
+	deleted   bool
+	updatedAt time.Time
+}
+
+func newMessageAggregate(id string) *messageAggregate {
+	return &messageAggregate{Aggregate: es.NewAggregate(id, MessageAggregate)}
+}
+
+// Create adds event of the created message
+// Errors: ErrMessageAlreadyCreated
+func (a *messageAggregate) Create(msg *entity.Message) error {
+	if a.created || len(a.Events()) > 0 {
+		return ErrMessageAlreadyCreated
+	}
+
+	a.AddEvent(
+		MessageCreatedEvent,
+		entity.MessageCreated{Message: *msg},
+		event.WithOccuredAt(msg.CreatedAt),
+	)
+	return nil
+}
+
+// Edit adds event of the edited message, edits are applied in order of update time,
+// so edit of the deleted message or edit older than the last change is stale
+// Errors: ErrMessageNotCreated, ErrStaleMessageEvent
+func (a *messageAggregate) Edit(edited *entity.MessageEdited) error {
+	if err := a.checkChange(edited.UpdatedAt); err != nil {
+		return err
+	}
+
+	a.AddEvent(MessageEditedEvent, *edited, event.WithOccuredAt(edited.UpdatedAt))
+	return nil
+}
+
+// Delete adds event of the deleted message
+// Errors: ErrMessageNotCreated, ErrStaleMessageEvent
+func (a *messageAggregate) Delete(deleted *entity.MessageDeleted) error {
+	if err := a.checkChange(deleted.DeletedAt); err != nil {
+		return err
+	}
+
+	a.AddEvent(MessageDeletedEvent, *deleted, event.WithOccuredAt(deleted.DeletedAt))
+	return nil
+}
+
+// checkChange checks that created message could be changed at the time
+// Errors: ErrMessageNotCreated, ErrStaleMessageEvent
+func (a *messageAggregate) checkChange(changedAt time.Time) error {
+	switch {
+	case !a.created:
+		return ErrMessageNotCreated
+	case a.deleted || len(a.Events()) > 0 || !changedAt.After(a.updatedAt):
+		return ErrStaleMessageEvent
+	}
+
+	return nil
+}
+
+// ApplyEvent is implementing interface es.EventApplier
+func (a *messageAggregate) ApplyEvent(e event.Event) error {
+	switch payload := e.Payload().(type) {
+	case entity.MessageCreated:
+		a.created = true
+		a.updatedAt = payload.CreatedAt
+	case entity.MessageEdited:
+		a.updatedAt = payload.UpdatedAt
+	case entity.MessageDeleted:
+		a.deleted = true
+		a.updatedAt = payload.DeletedAt
+	default:
+		return fmt.Errorf("%w: %s", es.ErrUnknownType, e.EventName())
+	}
+
+	return nil
+}
+
+// readMarkerState is snapshot of the read marker
+type readMarkerState struct {
+	ReadAt time.Time `json:"read_at"`
+}
+
+func (readMarkerState) SnapshotName() string { return readMarkerSnapshot }
+
+// readMarkerAggregate represents stream of reading conversation by user,
+// it is loaded from snapshots, because it grows with every reading
+type readMarkerAggregate struct {
+	es.Aggregate
+	state readMarkerState
+}
+
+func newReadMarkerAggregate(id string) *readMarkerAggregate {
+	return &readMarkerAggregate{Aggregate: es.NewAggregate(id, ReadMarkerAggregate)}
+}
+
+// readMarkerID returns id of the read marker of the conversation by user
+func readMarkerID(conversationID, userID int64) string {
+	return fmt.Sprintf("%d:%d", conversationID, userID)
+}
+
+// Read adds event of reading conversation by user
+func (a *readMarkerAggregate) Read(conversationID, userID int64, readAt time.Time) {
+	a.AddEvent(
+		ConversationReadEvent,
+		entity.ConversationRead{ConversationID: conversationID, UserID: userID, ReadAt: readAt},
+		event.WithOccuredAt(readAt),
+	)
+}
+
+// ApplyEvent is implementing interface es.EventApplier
+func (a *readMarkerAggregate) ApplyEvent(e event.Event) error {
+	switch payload := e.Payload().(type) {
+	case entity.ConversationRead:
+		a.state.ReadAt = payload.ReadAt
+	default:
+		return fmt.Errorf("%w: %s", es.ErrUnknownType, e.EventName())
+	}
+
+	return nil
+}
+
+// ApplySnapshot is implementing interface es.Snapshotter
+func (a *readMarkerAggregate) ApplySnapshot(snapshot es.Snapshot) error {
+	state, ok := snapshot.(readMarkerState)
+	if !ok {
+		return fmt.Errorf("%w: %s", es.ErrUnknownType, snapshot.SnapshotName())
+	}
+
+	a.state = state
+	return nil
+}
+
+// ToSnapshot is implementing interface es.Snapshotter
+func (a *readMarkerAggregate) ToSnapshot() es.Snapshot {
+	return a.state
+}
+
+// MessageStreams appends events of messages to the event store
+type MessageStreams struct {
+	messages    es.AggregateRepository[*messageAggregate]
+	readMarkers es.AggregateRepository[*readMarkerAggregate]
+}
+
+// NewMessageStreams creates message streams in the store,
+// store should load read markers from snapshots
+func NewMessageStreams(store es.AggregateStore) *MessageStreams {
+	return &MessageStreams{
+		messages:    es.NewAggregateRepository(newMessageAggregate, store),
+		readMarkers: es.NewAggregateRepository(newReadMarkerAggregate, store),
+	}
+}
+
+// Created appends event of the created message to the message stream
+// Errors: ErrMessageAlreadyCreated, es.ErrVersionConflict, unknown
+func (ms *MessageStreams) Created(ctx context.Context, msg *entity.Message) error {
+	aggregate := newMessageAggregate(msg.ID.String())
+	if err := aggregate.Create(msg); err != nil {
+		return err
+	}
+
+	return ms.messages.Save(ctx, aggregate)
+}
+
+// Edited appends event of the edited message to the message stream
+// Errors: ErrMessageNotCreated, ErrStaleMessageEvent, unknown
+func (ms *MessageStreams) Edited(ctx context.Context, edited *entity.MessageEdited) error {
+	return ms.change(ctx, edited.ID, func(aggregate *messageAggregate) error {
+		return aggregate.Edit(edited)
+	})
+}
+
+// Deleted appends event of the deleted message to the message stream
+// Errors: ErrMessageNotCreated, ErrStaleMessageEvent, unknown
+func (ms *MessageStreams) Deleted(ctx context.Context, deleted *entity.MessageDeleted) error {
+	return ms.change(ctx, deleted.ID, func(aggregate *messageAggregate) error {
+		return aggregate.Delete(deleted)
+	})
+}
+
+// change loads the message stream, adds event of the change and saves it,
+// change is retried if stream is appended concurrently
+func (ms *MessageStreams) change(
+	ctx context.Context,
+	id uuid.UUID,
+	change func(aggregate *messageAggregate) error,
+) error {
+	for {
+		aggregate, err := ms.messages.Load(ctx, id.String())
+		if err != nil {
+			return err
+		}
+
+		if err := change(aggregate); err != nil {
+			return err
+		}
+
+		err = ms.messages.Save(ctx, aggregate)
+		if !errors.Is(err, es.ErrVersionConflict) {
+			return err
+		}
+	}
+}
+
+// ConsumeEvent is implementing interface OutboxConsumer, events of created, edited
+// and deleted messages are appended to the message streams after the messages are
+// stored, events that are already appended are skipped
+func (ms *MessageStreams) ConsumeEvent(ctx context.Context, e *entity.Event) error {
+	var err error
+	switch payload := e.Payload.(type) {
+	case entity.NewMessageEvent:
+		id := uuid.FromStringOrNil(payload.ID)
+		if id == uuid.Nil {
+			return nil
+		}
+
+		err = ms.Created(ctx, &entity.Message{
+			ID:             id,
+			ConversationID: payload.ConversationID,
+			SenderID:       payload.SenderID,
+			MessageKind:    payload.MessageKind,
+			Message:        payload.Message,
+			CreatedAt:      payload.CreatedAt,
+		})
+	case entity.MessageEditedEvent:
+		err = ms.Edited(ctx, &entity.MessageEdited{
+			ID:             uuid.FromStringOrNil(payload.ID),
+			ConversationID: payload.ConversationID,
+			Message:        payload.Message,
+			UpdatedAt:      payload.UpdatedAt,
+		})
+	case entity.MessageDeletedEvent:
+		err = ms.Deleted(ctx, &entity.MessageDeleted{
+			ID:             uuid.FromStringOrNil(payload.ID),
+			ConversationID: payload.ConversationID,
+			DeletedAt:      payload.DeletedAt,
+		})
+	}
+
+	// messages created before their streams have nothing to change
+	switch {
+	case errors.Is(err, ErrMessageAlreadyCreated),
+		errors.Is(err, es.ErrVersionConflict),
+		errors.Is(err, ErrMessageNotCreated),
+		errors.Is(err, ErrStaleMessageEvent):
+		return nil
+	}
+
+	return err
+}
+
+// Read appends event of reading conversation by user to the read marker stream
+// Errors: unknown
+func (ms *MessageStreams) Read(ctx context.Context, conversationID, userID int64) error {
+	for {
+		aggregate, err := ms.readMarkers.Load(ctx, readMarkerID(conversationID, userID))
+		if err != nil {
+			return err
+		}
+
+		aggregate.Read(conversationID, userID, time.Now())
+		err = ms.readMarkers.Save(ctx, aggregate)
+		if !errors.Is(err, es.ErrVersionConflict) {
+			return err
+		}
+	}
+}
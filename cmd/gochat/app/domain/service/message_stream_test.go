@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/pkg/es"
+)
+
+func TestMessageStreams(t *testing.T) {
+	store := es.NewMemoryStore()
+	streams := NewMessageStreams(
+		es.NewSnapshotAggregateStore(store, es.NewMemorySnapshotStore(), 2),
+	)
+
+	msg := &entity.Message{
+		ID:          uuid.Must(uuid.NewV4()),
+		SenderID:    1,
+		MessageKind: entity.UserTextMessage,
+		Message:     "hello",
+		CreatedAt:   time.Now(),
+	}
+
+	t.Run("check created message", func(t *testing.T) {
+		err := streams.Created(context.Background(), msg)
+		assert.Equal(t, nil, err, "should not be error")
+
+		err = streams.Created(context.Background(), msg)
+		assert.ErrorIs(t, err, es.ErrVersionConflict, "should not create message twice")
+	})
+
+	t.Run("check edited and deleted message", func(t *testing.T) {
+		edited := &entity.MessageEdited{
+			ID:        msg.ID,
+			Message:   "edited",
+			UpdatedAt: msg.CreatedAt.Add(time.Second),
+		}
+		err := streams.Edited(context.Background(), edited)
+		assert.Equal(t, nil, err, "should not be error")
+
+		err = streams.Edited(context.Background(), edited)
+		assert.ErrorIs(t, err, ErrStaleMessageEvent, "should not edit message twice")
+
+		deleted := &entity.MessageDeleted{ID: msg.ID, DeletedAt: edited.UpdatedAt}
+		err = streams.Deleted(context.Background(), deleted)
+		assert.ErrorIs(t, err, ErrStaleMessageEvent, "should not delete by stale event")
+
+		deleted.DeletedAt = deleted.DeletedAt.Add(time.Second)
+		err = streams.Deleted(context.Background(), deleted)
+		assert.Equal(t, nil, err, "should not be error")
+
+		edited.UpdatedAt = deleted.DeletedAt.Add(time.Second)
+		err = streams.Edited(context.Background(), edited)
+		assert.ErrorIs(t, err, ErrStaleMessageEvent, "should not edit deleted message")
+
+		err = streams.Edited(context.Background(), &entity.MessageEdited{
+			ID:        uuid.Must(uuid.NewV4()),
+			UpdatedAt: time.Now(),
+		})
+		assert.ErrorIs(t, err, ErrMessageNotCreated, "should not edit not created message")
+	})
+
+	t.Run("check reading conversation", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			err := streams.Read(context.Background(), entity.DefaultConversationID, 2)
+			assert.Equal(t, nil, err, "should not be error")
+		}
+	})
+
+	events, err := store.ReadEvents(context.Background(), 0, 10)
+	assert.Equal(t, nil, err, "should not be error")
+	if assert.Equal(t, 6, len(events), "should be equal count of events") {
+		created, ok := events[0].Payload().(entity.MessageCreated)
+		assert.Equal(t, true, ok, "should be created message")
+		assert.Equal(t, msg.ID, created.ID, "should be equal message ids")
+
+		_, ok = events[2].Payload().(entity.MessageDeleted)
+		assert.Equal(t, true, ok, "should be deleted message")
+		assert.Equal(t, 3, events[2].Metadata().Get(es.AggregateVersionKey), "should be version")
+
+		read, ok := events[5].Payload().(entity.ConversationRead)
+		assert.Equal(t, true, ok, "should be reading of conversation")
+		assert.Equal(t, int64(2), read.UserID, "should be equal readers")
+		assert.Equal(t, 3, events[5].Metadata().Get(es.AggregateVersionKey), "should be version")
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
+)
+
+// Defaults of the outbox dispatcher options
+const (
+	DefaultOutboxPollInterval = time.Second
+	DefaultOutboxBatchSize    = 100
+	DefaultOutboxRetention    = 24 * time.Hour
+)
+
+// newOutboxEvent creates outbox event with a new id and payload of the event type version
+// Errors: ErrGenerateUUIDFailed, unknown
+func newOutboxEvent(
+	eventType string,
+	version int,
+	createdAt time.Time,
+	payload interface{},
+) (*entity.OutboxEvent, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, repo.ErrGenerateUUIDFailed
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.OutboxEvent{
+		ID:        id,
+		EventType: eventType,
+		Version:   version,
+		Payload:   data,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// OutboxConsumer consumes events of the outbox before they are published
+type OutboxConsumer interface {
+	// ConsumeEvent consumes decoded event, event is dispatched again if consuming
+	// or publishing of it fails, so event consumed twice should be skipped
+	// Errors: unknown
+	ConsumeEvent(ctx context.Context, event *entity.Event) error
+}
+
+type OutboxDispatcherOpts struct {
+	// Repository is repository of the outbox
+	Repository repo.OutboxRepository
+
+	// Bus is bus that events are published to
+	Bus EventBus
+
+	// Registry decodes payloads of the events
+	Registry *EventRegistry
+
+	// Consumers consume events before they are published e.g. append them
+	// to the event store, they are optional
+	Consumers []OutboxConsumer
+
+	// Logger logs errors of dispatching, default is slog.Default()
+	Logger *slog.Logger
+
+	// PollInterval is interval of dispatching if dispatcher is not notified,
+	// default is DefaultOutboxPollInterval
+	PollInterval time.Duration
+
+	// BatchSize is count of events that are dispatched at once,
+	// default is DefaultOutboxBatchSize
+	BatchSize int
+
+	// Retention is duration published events are kept in the outbox,
+	// default is DefaultOutboxRetention
+	Retention time.Duration
+}
+
+// OutboxDispatcher publishes events of the outbox to the bus at least once,
+// event is marked published only after the bus accepted it, so it could be
+// published again if dispatcher is stopped before marking and consumers
+// should skip events with already received id e.g. by EventDeduplicator
+type OutboxDispatcher struct {
+	opts     OutboxDispatcherOpts
+	notifych chan struct{}
+}
+
+func NewOutboxDispatcher(opts *OutboxDispatcherOpts) *OutboxDispatcher {
+	d := &OutboxDispatcher{
+		opts:     *opts,
+		notifych: make(chan struct{}, 1),
+	}
+
+	if d.opts.Logger == nil {
+		d.opts.Logger = slog.Default()
+	}
+	if d.opts.PollInterval <= 0 {
+		d.opts.PollInterval = DefaultOutboxPollInterval
+	}
+	if d.opts.BatchSize <= 0 {
+		d.opts.BatchSize = DefaultOutboxBatchSize
+	}
+	if d.opts.Retention <= 0 {
+		d.opts.Retention = DefaultOutboxRetention
+	}
+
+	return d
+}
+
+// Notify wakes up dispatcher after events are added to the outbox, it never blocks
+func (d *OutboxDispatcher) Notify() {
+	select {
+	case d.notifych <- struct{}{}:
+	default:
+	}
+}
+
+// Run dispatches events on notification or every poll interval until context is done,
+// published events are deleted after retention
+func (d *OutboxDispatcher) Run(ctx context.Context) {
+	const op = "gochat.app.domain.service.OutboxDispatcher.Run"
+
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	var cleanedAt time.Time
+	for {
+		if _, err := d.Dispatch(ctx); err != nil && ctx.Err() == nil {
+			d.opts.Logger.Error("dispatch outbox", "error", fmt.Errorf("%s: %w", op, err).Error())
+		}
+
+		if time.Since(cleanedAt) > d.opts.Retention/2 {
+			_, err := d.opts.Repository.DeletePublished(ctx, time.Now().Add(-d.opts.Retention))
+			if err != nil && ctx.Err() == nil {
+				d.opts.Logger.Error("clean outbox", "error", fmt.Errorf("%s: %w", op, err).Error())
+			}
+			cleanedAt = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.notifych:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch publishes all unpublished events of the outbox and returns their count
+// Errors: unknown
+func (d *OutboxDispatcher) Dispatch(ctx context.Context) (int, error) {
+	dispatched := 0
+	for {
+		n, err := d.opts.Repository.Dispatch(
+			ctx,
+			d.opts.BatchSize,
+			func(event *entity.OutboxEvent) error { return d.publish(ctx, event) },
+		)
+		dispatched += n
+		if err != nil || n < d.opts.BatchSize {
+			return dispatched, err
+		}
+	}
+}
+
+// publish passes outbox event to the consumers and publishes it to the bus, events
+// that could not be decoded are logged and skipped, so they do not block the outbox
+func (d *OutboxDispatcher) publish(ctx context.Context, event *entity.OutboxEvent) error {
+	const op = "gochat.app.domain.service.OutboxDispatcher.publish"
+
+	payload, version, err := d.opts.Registry.Decode(
+		event.EventType,
+		event.Version,
+		event.Payload,
+	)
+	if err != nil {
+		d.opts.Logger.Error(
+			"skip outbox event",
+			"id", event.ID.String(),
+			"error", fmt.Errorf("%s: %w", op, err).Error(),
+		)
+		return nil
+	}
+
+	decoded := entity.Event{
+		ID:        event.ID,
+		Type:      event.EventType,
+		Version:   version,
+		Timestamp: event.CreatedAt,
+		Payload:   payload,
+	}
+	for _, consumer := range d.opts.Consumers {
+		if err := consumer.ConsumeEvent(ctx, &decoded); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return d.opts.Bus.Publish(decoded)
+}
+
+// EventDeduplicator remembers ids of the last received events, so consumers
+// could skip events delivered more than once, it is not safe for concurrent use
+type EventDeduplicator struct {
+	seen map[uuid.UUID]struct{}
+	ids  []uuid.UUID
+	next int
+}
+
+// NewEventDeduplicator creates deduplicator that remembers size last ids
+func NewEventDeduplicator(size int) *EventDeduplicator {
+	size = max(size, 1)
+	return &EventDeduplicator{
+		seen: make(map[uuid.UUID]struct{}, size),
+		ids:  make([]uuid.UUID, 0, size),
+	}
+}
+
+// Seen reports whether event with the id is already received and remembers the id,
+// the oldest id is forgotten if deduplicator is full
+func (d *EventDeduplicator) Seen(id uuid.UUID) bool {
+	if _, ok := d.seen[id]; ok {
+		return true
+	}
+
+	if len(d.ids) < cap(d.ids) {
+		d.ids = append(d.ids, id)
+	} else {
+		delete(d.seen, d.ids[d.next])
+		d.ids[d.next] = id
+		d.next = (d.next + 1) % len(d.ids)
+	}
+	d.seen[id] = struct{}{}
+
+	return false
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/pkg/es"
+)
+
+var errTestPublish = errors.New("publish failed")
+
+// testOutboxRepository is in-memory outbox repository
+type testOutboxRepository struct {
+	events []entity.OutboxEvent
+}
+
+func (r *testOutboxRepository) Dispatch(
+	_ context.Context,
+	limit int,
+	publish func(event *entity.OutboxEvent) error,
+) (int, error) {
+	dispatched := 0
+	for i := range r.events {
+		if dispatched == limit {
+			break
+		}
+		if r.events[i].PublishedAt != nil {
+			continue
+		}
+
+		if err := publish(&r.events[i]); err != nil {
+			return dispatched, err
+		}
+
+		now := time.Now()
+		r.events[i].PublishedAt = &now
+		dispatched++
+	}
+
+	return dispatched, nil
+}
+
+func (r *testOutboxRepository) DeletePublished(_ context.Context, _ time.Time) (int64, error) {
+	return 0, nil
+}
+
+// failingEventBus fails publishing of the first failures events
+type failingEventBus struct {
+	EventBus
+	failures int
+}
+
+func (eb *failingEventBus) Publish(event entity.Event) error {
+	if eb.failures > 0 {
+		eb.failures--
+		return errTestPublish
+	}
+
+	return eb.EventBus.Publish(event)
+}
+
+func newTestOutboxEvents(t *testing.T, texts ...string) []entity.OutboxEvent {
+	var events []entity.OutboxEvent
+	for _, text := range texts {
+		event, err := newOutboxEvent(testEventType, 1, time.Now(), testPayload{Text: text})
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, *event)
+	}
+
+	return events
+}
+
+func TestOutboxDispatcher_Dispatch(t *testing.T) {
+	repository := &testOutboxRepository{events: newTestOutboxEvents(t, "first", "second")}
+	repository.events = append(repository.events, entity.OutboxEvent{
+		ID:        uuid.Must(uuid.NewV4()),
+		EventType: "Unknown",
+		Payload:   []byte(`{}`),
+	})
+
+	bus := &failingEventBus{EventBus: NewEventBus(), failures: 1}
+	id, eventch := bus.Subscribe(testEventType, nil)
+	defer bus.Unsubscribe(id)
+
+	registry := NewEventRegistry()
+	Register(registry, testEventType, EventDef[testPayload]{})
+
+	dispatcher := NewOutboxDispatcher(&OutboxDispatcherOpts{
+		Repository: repository,
+		Bus:        bus,
+		Registry:   registry,
+		BatchSize:  1,
+	})
+
+	t.Run("check failed publish", func(t *testing.T) {
+		dispatched, err := dispatcher.Dispatch(context.Background())
+		assert.ErrorIs(t, err, errTestPublish, "should be error of the bus")
+		assert.Equal(t, 0, dispatched, "should not dispatch events")
+		assert.Equal(t, 0, len(drainTestEvents(eventch)), "should not deliver events")
+	})
+
+	t.Run("check dispatch after failure", func(t *testing.T) {
+		dispatched, err := dispatcher.Dispatch(context.Background())
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 3, dispatched, "should dispatch all events")
+		assert.Equal(t, []interface{}{
+			testPayload{Text: "first"},
+			testPayload{Text: "second"},
+		}, drainTestEvents(eventch), "should deliver events in order")
+	})
+}
+
+// failingEventStore fails appending of the first failures aggregates
+type failingEventStore struct {
+	es.EventStore
+	failures int
+}
+
+func (s *failingEventStore) Save(ctx context.Context, aggregate es.EventSourcedAggregate) error {
+	if s.failures > 0 {
+		s.failures--
+		return errTestPublish
+	}
+
+	return s.EventStore.Save(ctx, aggregate)
+}
+
+func TestOutboxDispatcher_Consumers(t *testing.T) {
+	msg := newTestMessage(1, "client")
+	msg.ID = uuid.Must(uuid.NewV4())
+	event, err := newOutboxEvent(NewMessageEventType, NewMessageEventVersion, msg.CreatedAt,
+		entity.NewMessageEvent{
+			ID:          msg.ID.String(),
+			SenderID:    msg.SenderID,
+			MessageKind: msg.MessageKind,
+			Message:     msg.Message,
+			CreatedAt:   msg.CreatedAt,
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repository := &testOutboxRepository{events: []entity.OutboxEvent{*event}}
+
+	bus := NewEventBus()
+	id, eventch := bus.Subscribe(NewMessageEventType, nil)
+	defer bus.Unsubscribe(id)
+
+	registry := NewEventRegistry()
+	Register(registry, NewMessageEventType, EventDef[entity.NewMessageEvent]{
+		Version: NewMessageEventVersion,
+	})
+
+	store := &failingEventStore{EventStore: es.NewMemoryStore(), failures: 1}
+	dispatcher := NewOutboxDispatcher(&OutboxDispatcherOpts{
+		Repository: repository,
+		Bus:        bus,
+		Registry:   registry,
+		Consumers:  []OutboxConsumer{NewMessageStreams(store)},
+	})
+
+	storedEvents := func() int {
+		events, err := store.ReadEvents(context.Background(), 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(events)
+	}
+
+	t.Run("check failed append to the message stream", func(t *testing.T) {
+		dispatched, err := dispatcher.Dispatch(context.Background())
+		assert.ErrorIs(t, err, errTestPublish, "should be error of the store")
+		assert.Equal(t, 0, dispatched, "should not dispatch event")
+		assert.Equal(t, 0, len(drainTestEvents(eventch)), "should not publish event")
+		assert.Equal(t, 0, storedEvents(), "should not append event")
+	})
+
+	t.Run("check dispatch after failure", func(t *testing.T) {
+		dispatched, err := dispatcher.Dispatch(context.Background())
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 1, dispatched, "should dispatch event")
+		assert.Equal(t, 1, len(drainTestEvents(eventch)), "should publish event")
+		assert.Equal(t, 1, storedEvents(), "should append event")
+	})
+
+	t.Run("check dispatch of consumed event", func(t *testing.T) {
+		repository.events[0].PublishedAt = nil
+
+		dispatched, err := dispatcher.Dispatch(context.Background())
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 1, dispatched, "should dispatch event again")
+		assert.Equal(t, 1, storedEvents(), "should not append event twice")
+	})
+}
+
+func TestEventDeduplicator(t *testing.T) {
+	dedup := NewEventDeduplicator(2)
+	ids := []uuid.UUID{uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())}
+
+	assert.Equal(t, false, dedup.Seen(ids[0]), "should not be seen")
+	assert.Equal(t, true, dedup.Seen(ids[0]), "should be seen")
+	assert.Equal(t, false, dedup.Seen(ids[1]), "should not be seen")
+	assert.Equal(t, false, dedup.Seen(ids[2]), "should not be seen")
+	assert.Equal(t, true, dedup.Seen(ids[2]), "should be seen")
+	assert.Equal(t, false, dedup.Seen(ids[0]), "should forget the oldest id")
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+)
+
+// ReactionAddedEventDef defines reaction added event, reaction of the event is added
+// by reaction service on handling and the event is published through the outbox,
+// conversation of the event is set to the one of the message
+func ReactionAddedEventDef(reactionService ReactionService) EventDef[entity.MessageReactionEvent] {
+	return EventDef[entity.MessageReactionEvent]{
+		Version:  MessageReactionEventVersion,
+		Validate: validateMessageReactionEvent,
+		Handle: func(ctx context.Context, event *entity.MessageReactionEvent) error {
+			msg, err := reactionService.Add(ctx, newReaction(event))
+			if err != nil {
+				return err
+			}
+
+			event.ConversationID = msg.ConversationID
+			return nil
+		},
+	}
+}
+
+// ReactionRemovedEventDef defines reaction removed event, reaction of the event is
+// removed by reaction service on handling and the event is published through the
+// outbox, conversation of the event is set to the one of the message
+func ReactionRemovedEventDef(
+	reactionService ReactionService,
+) EventDef[entity.MessageReactionEvent] {
+	return EventDef[entity.MessageReactionEvent]{
+		Version:  MessageReactionEventVersion,
+		Validate: validateMessageReactionEvent,
+		Handle: func(ctx context.Context, event *entity.MessageReactionEvent) error {
+			msg, err := reactionService.Remove(ctx, newReaction(event))
+			if err != nil {
+				return err
+			}
+
+			event.ConversationID = msg.ConversationID
+			return nil
+		},
+	}
+}
+
+// newReaction creates reaction of the event
+func newReaction(event *entity.MessageReactionEvent) *entity.Reaction {
+	return &entity.Reaction{
+		MessageID: uuid.FromStringOrNil(event.MessageID),
+		UserID:    event.UserID,
+		Emoji:     event.Emoji,
+	}
+}
+
+// validateMessageReactionEvent validates reaction on the message
+func validateMessageReactionEvent(event *entity.MessageReactionEvent) error {
+	switch {
+	case uuid.FromStringOrNil(event.MessageID) == uuid.Nil:
+		return ErrInvalidMessageID
+	case event.UserID <= 0:
+		return ErrInvalidSender
+	case len(event.ClientID) > MaxClientIDLength:
+		return ErrClientIDTooLong
+	}
+
+	return validateEmoji(event.Emoji)
+}
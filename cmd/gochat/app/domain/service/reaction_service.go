@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gofrs/uuid"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
+)
+
+// MaxEmojiLength is max length of the emoji of the reaction in bytes,
+// emoji could be sequence of several code points
+const MaxEmojiLength = 32
+
+var ErrInvalidEmoji = errors.New("invalid emoji")
+
+type ReactionService interface {
+	// Add adds reaction of the member of the conversation on the message and
+	// returns the message, reaction added event is published through the outbox,
+	// adding of existing reaction does nothing, reactions of deleted message are
+	// removed with its text, so they could not be added to it
+	// Errors: ErrInvalidEmoji, repo.ErrMessageNotFound, ErrNotConversationMember,
+	// ErrMessageDeleted, unknown
+	Add(ctx context.Context, reaction *entity.Reaction) (*entity.Message, error)
+
+	// Remove removes reaction of the member of the conversation on the message and
+	// returns the message, reaction removed event is published through the outbox,
+	// removing of not existing reaction does nothing
+	// Errors: ErrInvalidEmoji, repo.ErrMessageNotFound, ErrNotConversationMember, unknown
+	Remove(ctx context.Context, reaction *entity.Reaction) (*entity.Message, error)
+
+	// Attach sets reactions aggregated by emoji to the messages
+	// Errors: unknown
+	Attach(ctx context.Context, messages []entity.Message) error
+}
+
+type reactionService struct {
+	repository    repo.ReactionRepository
+	messages      MessageService
+	conversations ConversationService
+	outbox        *OutboxDispatcher
+}
+
+// NewReactionService creates reaction service, messages of reactions are found
+// by message service and membership of users is checked by conversation service,
+// outbox dispatcher is notified about new events if it is not nil
+func NewReactionService(
+	repository repo.ReactionRepository,
+	messages MessageService,
+	conversations ConversationService,
+	outbox *OutboxDispatcher,
+) ReactionService {
+	return &reactionService{
+		repository:    repository,
+		messages:      messages,
+		conversations: conversations,
+		outbox:        outbox,
+	}
+}
+
+// Add is implementing interface ReactionService
+func (rs *reactionService) Add(
+	ctx context.Context,
+	reaction *entity.Reaction,
+) (*entity.Message, error) {
+	const op = "gochat.app.domain.service.reactionService.Add"
+
+	msg, err := rs.findMessage(ctx, reaction)
+	if err != nil {
+		return nil, err
+	}
+	if msg.IsDeleted() {
+		return nil, ErrMessageDeleted
+	}
+
+	reaction.CreatedAt = time.Now()
+	event, err := newReactionEvent(ReactionAddedEventType, msg, reaction)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = rs.repository.Add(ctx, reaction, event)
+	switch {
+	case errors.Is(err, repo.ErrReactionExists):
+		// retry of the adding
+		return msg, nil
+	case err != nil:
+		return nil, err
+	}
+
+	if rs.outbox != nil {
+		rs.outbox.Notify()
+	}
+
+	return msg, nil
+}
+
+// Remove is implementing interface ReactionService
+func (rs *reactionService) Remove(
+	ctx context.Context,
+	reaction *entity.Reaction,
+) (*entity.Message, error) {
+	const op = "gochat.app.domain.service.reactionService.Remove"
+
+	msg, err := rs.findMessage(ctx, reaction)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := newReactionEvent(ReactionRemovedEventType, msg, reaction)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = rs.repository.Remove(ctx, reaction, event)
+	switch {
+	case errors.Is(err, repo.ErrReactionNotFound):
+		// retry of the removing
+		return msg, nil
+	case err != nil:
+		return nil, err
+	}
+
+	if rs.outbox != nil {
+		rs.outbox.Notify()
+	}
+
+	return msg, nil
+}
+
+// Attach is implementing interface ReactionService
+func (rs *reactionService) Attach(ctx context.Context, messages []entity.Message) error {
+	const op = "gochat.app.domain.service.reactionService.Attach"
+
+	messageIds := make([]uuid.UUID, 0, len(messages))
+	for i := range messages {
+		messageIds = append(messageIds, messages[i].ID)
+	}
+
+	reactions, err := rs.repository.FindByMessages(ctx, messageIds)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	summaries := summarizeReactions(reactions)
+	for i := range messages {
+		messages[i].Reactions = summaries[messages[i].ID]
+	}
+
+	return nil
+}
+
+// findMessage validates emoji of the reaction and finds message of the reaction
+// if user of the reaction is member of its conversation
+// Errors: ErrInvalidEmoji, repo.ErrMessageNotFound, ErrNotConversationMember, unknown
+func (rs *reactionService) findMessage(
+	ctx context.Context,
+	reaction *entity.Reaction,
+) (*entity.Message, error) {
+	if err := validateEmoji(reaction.Emoji); err != nil {
+		return nil, err
+	}
+
+	msg, err := rs.messages.FindById(ctx, reaction.MessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = rs.conversations.CheckMember(ctx, msg.ConversationID, reaction.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// newReactionEvent creates outbox event of the reaction on the message
+func newReactionEvent(
+	eventType string,
+	msg *entity.Message,
+	reaction *entity.Reaction,
+) (*entity.OutboxEvent, error) {
+	return newOutboxEvent(
+		eventType,
+		MessageReactionEventVersion,
+		time.Now(),
+		entity.MessageReactionEvent{
+			MessageID:      msg.ID.String(),
+			ConversationID: msg.ConversationID,
+			UserID:         reaction.UserID,
+			Emoji:          reaction.Emoji,
+		},
+	)
+}
+
+// summarizeReactions aggregates reactions ordered by creation by messages and emoji,
+// emoji are ordered by the first reaction with it
+func summarizeReactions(reactions []entity.Reaction) map[uuid.UUID][]entity.ReactionSummary {
+	summaries := make(map[uuid.UUID][]entity.ReactionSummary)
+	for _, reaction := range reactions {
+		messageSummaries := summaries[reaction.MessageID]
+
+		i := 0
+		for i < len(messageSummaries) && messageSummaries[i].Emoji != reaction.Emoji {
+			i++
+		}
+		if i == len(messageSummaries) {
+			messageSummaries = append(
+				messageSummaries,
+				entity.ReactionSummary{Emoji: reaction.Emoji},
+			)
+		}
+
+		messageSummaries[i].Count++
+		messageSummaries[i].UserIDs = append(messageSummaries[i].UserIDs, reaction.UserID)
+		summaries[reaction.MessageID] = messageSummaries
+	}
+
+	return summaries
+}
+
+// validateEmoji validates emoji of the reaction, it could not be empty
+// or contain spaces and control characters
+func validateEmoji(emoji string) error {
+	switch {
+	case emoji == "":
+		return fmt.Errorf("%w: empty emoji", ErrInvalidEmoji)
+	case len(emoji) > MaxEmojiLength:
+		return fmt.Errorf("%w: emoji is too long", ErrInvalidEmoji)
+	case strings.IndexFunc(emoji, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r) || r == unicode.ReplacementChar
+	}) >= 0:
+		return fmt.Errorf("%w: emoji contains invalid characters", ErrInvalidEmoji)
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
+)
+
+// testReactionRepository is in-memory reaction repository
+type testReactionRepository struct {
+	reactions []entity.Reaction
+	events    []*entity.OutboxEvent
+}
+
+func (r *testReactionRepository) find(reaction *entity.Reaction) int {
+	for i := range r.reactions {
+		if r.reactions[i].MessageID == reaction.MessageID &&
+			r.reactions[i].UserID == reaction.UserID &&
+			r.reactions[i].Emoji == reaction.Emoji {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (r *testReactionRepository) Add(
+	_ context.Context,
+	reaction *entity.Reaction,
+	events ...*entity.OutboxEvent,
+) error {
+	if r.find(reaction) >= 0 {
+		return repo.ErrReactionExists
+	}
+
+	r.reactions = append(r.reactions, *reaction)
+	r.events = append(r.events, events...)
+	return nil
+}
+
+func (r *testReactionRepository) Remove(
+	_ context.Context,
+	reaction *entity.Reaction,
+	events ...*entity.OutboxEvent,
+) error {
+	i := r.find(reaction)
+	if i < 0 {
+		return repo.ErrReactionNotFound
+	}
+
+	r.reactions = append(r.reactions[:i], r.reactions[i+1:]...)
+	r.events = append(r.events, events...)
+	return nil
+}
+
+func (r *testReactionRepository) FindByMessages(
+	_ context.Context,
+	messageIds []uuid.UUID,
+) ([]entity.Reaction, error) {
+	var reactions []entity.Reaction
+	for _, reaction := range r.reactions {
+		for _, id := range messageIds {
+			if reaction.MessageID == id {
+				reactions = append(reactions, reaction)
+			}
+		}
+	}
+
+	return reactions, nil
+}
+
+func TestReactionService(t *testing.T) {
+	ctx := context.Background()
+	cs, _ := newTestConversationService()
+	ms := NewMessageService(&testMessageRepository{}, nil, nil)
+	repository := &testReactionRepository{}
+	rs := NewReactionService(repository, ms, cs, nil)
+
+	conv := &entity.Conversation{Title: "friends", CreatorID: 1}
+	convId, err := cs.CreateGroup(ctx, conv, []int64{2})
+	assert.Equal(t, nil, err, "should not be error")
+
+	msg := newTestMessage(1, "")
+	msg.ConversationID = convId
+	msgId, err := ms.Create(ctx, msg)
+	assert.Equal(t, nil, err, "should not be error")
+
+	t.Run("check adding reactions", func(t *testing.T) {
+		for _, reaction := range []entity.Reaction{
+			{MessageID: msgId, UserID: 1, Emoji: "👍"},
+			{MessageID: msgId, UserID: 2, Emoji: "🎉"},
+			{MessageID: msgId, UserID: 2, Emoji: "👍"},
+			{MessageID: msgId, UserID: 2, Emoji: "👍"},
+		} {
+			reacted, err := rs.Add(ctx, &reaction)
+			assert.Equal(t, nil, err, "should not be error")
+			assert.Equal(t, convId, reacted.ConversationID, "should be conversation of the message")
+		}
+		assert.Equal(t, 3, len(repository.events), "should not publish retry of adding")
+	})
+
+	t.Run("check aggregated reactions", func(t *testing.T) {
+		history := []entity.Message{*msg}
+		err := rs.Attach(ctx, history)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(
+			t,
+			[]entity.ReactionSummary{
+				{Emoji: "👍", Count: 2, UserIDs: []int64{1, 2}},
+				{Emoji: "🎉", Count: 1, UserIDs: []int64{2}},
+			},
+			history[0].Reactions,
+			"should be reactions aggregated by emoji",
+		)
+	})
+
+	t.Run("check removing reaction", func(t *testing.T) {
+		reaction := &entity.Reaction{MessageID: msgId, UserID: 2, Emoji: "🎉"}
+		_, err := rs.Remove(ctx, reaction)
+		assert.Equal(t, nil, err, "should not be error")
+		_, err = rs.Remove(ctx, reaction)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 2, len(repository.reactions), "should remove reaction")
+		assert.Equal(t, 4, len(repository.events), "should not publish retry of removing")
+		assert.Equal(
+			t,
+			ReactionRemovedEventType,
+			repository.events[3].EventType,
+			"should be reaction removed event",
+		)
+	})
+
+	t.Run("check reaction of not member", func(t *testing.T) {
+		_, err := rs.Add(ctx, &entity.Reaction{MessageID: msgId, UserID: 3, Emoji: "👍"})
+		assert.ErrorIs(t, err, ErrNotConversationMember, "should not be member")
+	})
+
+	t.Run("check invalid emoji", func(t *testing.T) {
+		_, err := rs.Add(ctx, &entity.Reaction{MessageID: msgId, UserID: 1, Emoji: "a b"})
+		assert.ErrorIs(t, err, ErrInvalidEmoji, "should be invalid emoji")
+	})
+}
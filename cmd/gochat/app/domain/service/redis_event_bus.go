@@ -0,0 +1,367 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+)
+
+const (
+	// DefaultEventsChannel is redis channel of the events if it is not specified
+	DefaultEventsChannel = "gochat:events"
+
+	publishTimeout    = time.Second
+	minReceiveBackoff = time.Millisecond * 100
+	maxReceiveBackoff = time.Second * 5
+)
+
+// publishScript assigns next sequence number of the bus to the event,
+// retains the event in the log and publishes it atomically, so events are
+// published in order of sequence numbers and every instance of the app
+// receives them in that order
+//
+// KEYS[1] - sequence number of the events
+// KEYS[2] - log of the events
+// KEYS[3] - channel of the events
+// ARGV[1] - serialized event without sequence number
+// ARGV[2] - retention
+//
+// Returns sequence number of the event
+var publishScript = redis.NewScript(`
+local seq = redis.call('INCR', KEYS[1])
+local data = '{"seq":' .. seq .. ',' .. string.sub(ARGV[1], 2)
+
+redis.call('ZADD', KEYS[2], seq, data)
+redis.call('ZREMRANGEBYRANK', KEYS[2], 0, -(tonumber(ARGV[2]) + 1))
+redis.call('PUBLISH', KEYS[3], data)
+
+return seq
+`)
+
+// RedisEventBusOpts represents options for redis based event bus
+type RedisEventBusOpts struct {
+	Client *redis.Client
+	Logger *slog.Logger
+
+	// Registry decodes payloads of received events
+	Registry *EventRegistry
+
+	// Channel of redis pub/sub that events are published to,
+	// it is also prefix of keys of sequence number and log
+	Channel string
+
+	// Retention is count of the last events kept for replay
+	Retention int
+}
+
+// redisEvent is event serialized for sending between instances of the app
+type redisEvent struct {
+	// Seq is set by publish script
+	Seq uint64 `json:"seq,omitempty"`
+
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	Version   int             `json:"version,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// RedisEventBus represents event bus that shares events between
+// instances of the app by redis pub/sub and implements EventBus interface.
+// Events published by the bus itself are delivered to local subscribers
+// on receiving from redis too, so all subscribers get events in order of
+// sequence numbers. Events missed while pub/sub is reconnecting are
+// backfilled from the log. Sequence number and log for replay are kept in redis
+type RedisEventBus struct {
+	EventBus
+
+	client    *redis.Client
+	logger    *slog.Logger
+	registry  *EventRegistry
+	channel   string
+	retention int
+
+	// lastSeq is sequence number of the last delivered event,
+	// it is used by receiving goroutine only
+	lastSeq uint64
+
+	pubsub *redis.PubSub
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRedisEventBus creates redis based event bus and starts receiving events,
+// the bus is resubscribed if connection to redis is lost
+func NewRedisEventBus(opts *RedisEventBusOpts) *RedisEventBus {
+	rb := &RedisEventBus{
+		EventBus:  newEventBus(0),
+		client:    opts.Client,
+		logger:    opts.Logger,
+		registry:  opts.Registry,
+		channel:   opts.Channel,
+		retention: opts.Retention,
+		done:      make(chan struct{}),
+	}
+	if rb.logger == nil {
+		rb.logger = slog.Default()
+	}
+	if rb.channel == "" {
+		rb.channel = DefaultEventsChannel
+	}
+	if rb.retention <= 0 {
+		rb.retention = DefaultRetention
+	}
+
+	rb.ctx, rb.cancel = context.WithCancel(context.Background())
+	rb.pubsub = rb.client.Subscribe(rb.ctx, rb.channel)
+
+	go rb.receive()
+	return rb
+}
+
+// Publish is implementing interface EventBus, the event is delivered to
+// local subscribers on receiving from redis after sequence number is assigned
+func (rb *RedisEventBus) Publish(event entity.Event) error {
+	const op = "gochat.app.domain.service.RedisEventBus.Publish"
+
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	data, err := json.Marshal(redisEvent{
+		ID:        event.ID,
+		Type:      event.Type,
+		Version:   event.Version,
+		Timestamp: event.Timestamp,
+		Payload:   payload,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ctx, cancel := context.WithTimeout(rb.ctx, publishTimeout)
+	defer cancel()
+
+	err = publishScript.Run(
+		ctx,
+		rb.client,
+		[]string{rb.seqKey(), rb.logKey(), rb.channel},
+		data,
+		rb.retention,
+	).Err()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// Replay is implementing interface EventBus, events that can not be decoded
+// are skipped
+func (rb *RedisEventBus) Replay(ctx context.Context, afterSeq uint64) ([]entity.Event, error) {
+	const op = "gochat.app.domain.service.RedisEventBus.Replay"
+
+	var (
+		seqCmd    *redis.StringCmd
+		oldestCmd *redis.ZSliceCmd
+		eventsCmd *redis.ZSliceCmd
+	)
+	_, err := rb.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		seqCmd = pipe.Get(ctx, rb.seqKey())
+		oldestCmd = pipe.ZRangeWithScores(ctx, rb.logKey(), 0, 0)
+		eventsCmd = pipe.ZRangeByScoreWithScores(ctx, rb.logKey(), &redis.ZRangeBy{
+			Min: fmt.Sprintf("(%d", afterSeq),
+			Max: "+inf",
+		})
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	seq, err := seqCmd.Uint64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	switch {
+	case afterSeq == seq:
+		return nil, nil
+	case afterSeq > seq:
+		// sequence numbers are started again e.g. after flush of redis
+		return nil, ErrSeqExpired
+	}
+
+	oldest := oldestCmd.Val()
+	if len(oldest) == 0 || uint64(oldest[0].Score) > afterSeq+1 {
+		return nil, ErrSeqExpired
+	}
+
+	events := make([]entity.Event, 0, len(eventsCmd.Val()))
+	for _, z := range eventsCmd.Val() {
+		data, _ := z.Member.(string)
+		event, err := rb.decode(data)
+		if err != nil {
+			rb.logger.Warn(
+				"replay event",
+				"seq",
+				uint64(z.Score),
+				"error",
+				fmt.Errorf("%s: %w", op, err).Error(),
+			)
+			continue
+		}
+		events = append(events, *event)
+	}
+
+	return events, nil
+}
+
+// seqKey returns key of sequence number of the events
+func (rb *RedisEventBus) seqKey() string {
+	return rb.channel + ":seq"
+}
+
+// logKey returns key of log of the events
+func (rb *RedisEventBus) logKey() string {
+	return rb.channel + ":log"
+}
+
+// Close stops receiving events from redis
+func (rb *RedisEventBus) Close() error {
+	rb.cancel()
+	err := rb.pubsub.Close()
+	<-rb.done
+
+	return err
+}
+
+// receive receives events from redis until the bus is closed,
+// pub/sub reconnects and resubscribes on next receive after error
+// and events missed meanwhile are backfilled after resubscribe
+func (rb *RedisEventBus) receive() {
+	const op = "gochat.app.domain.service.RedisEventBus.receive"
+
+	defer close(rb.done)
+
+	backoff := minReceiveBackoff
+	for {
+		msg, err := rb.pubsub.Receive(rb.ctx)
+		if err != nil {
+			if rb.ctx.Err() != nil {
+				return
+			}
+
+			rb.logger.Warn(
+				"receive event",
+				"error",
+				fmt.Errorf("%s: %w", op, err).Error(),
+				"retry_after",
+				backoff.String(),
+			)
+
+			select {
+			case <-rb.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff = min(backoff*2, maxReceiveBackoff)
+			continue
+		}
+		backoff = minReceiveBackoff
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			// events could be published while pub/sub was reconnecting
+			rb.backfill()
+		case *redis.Message:
+			event, err := rb.decode(msg.Payload)
+			if err != nil {
+				rb.logger.Error("decode event", "error", fmt.Errorf("%s: %w", op, err).Error())
+				continue
+			}
+
+			rb.deliver(event)
+		}
+	}
+}
+
+// deliver delivers event to local subscribers in order of sequence numbers,
+// missed events are backfilled first and already delivered ones are skipped
+func (rb *RedisEventBus) deliver(event *entity.Event) {
+	if rb.lastSeq != 0 && event.Seq > rb.lastSeq+1 {
+		rb.backfill()
+	}
+	if event.Seq <= rb.lastSeq {
+		return
+	}
+
+	rb.lastSeq = event.Seq
+	_ = rb.EventBus.Publish(*event)
+}
+
+// backfill delivers events of the log after the last delivered one
+func (rb *RedisEventBus) backfill() {
+	const op = "gochat.app.domain.service.RedisEventBus.backfill"
+
+	if rb.lastSeq == 0 {
+		return
+	}
+
+	events, err := rb.Replay(rb.ctx, rb.lastSeq)
+	if err != nil {
+		rb.logger.Warn(
+			"backfill events",
+			"after_seq",
+			rb.lastSeq,
+			"error",
+			fmt.Errorf("%s: %w", op, err).Error(),
+		)
+
+		// missed events are lost, so delivering is started from the next event
+		if errors.Is(err, ErrSeqExpired) {
+			rb.lastSeq = 0
+		}
+		return
+	}
+
+	for _, event := range events {
+		rb.lastSeq = event.Seq
+		_ = rb.EventBus.Publish(event)
+	}
+}
+
+// decode decodes event serialized by publish script
+// Errors: ErrUnknownEventType, ErrInvalidPayload, unknown
+func (rb *RedisEventBus) decode(data string) (*entity.Event, error) {
+	var re redisEvent
+	if err := json.Unmarshal([]byte(data), &re); err != nil {
+		return nil, err
+	}
+
+	// events of previous versions are published by older instances of the app
+	payload, version, err := rb.registry.Decode(re.Type, re.Version, re.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.Event{
+		ID:        re.ID,
+		Seq:       re.Seq,
+		Type:      re.Type,
+		Version:   version,
+		Timestamp: re.Timestamp,
+		Payload:   payload,
+	}, nil
+}
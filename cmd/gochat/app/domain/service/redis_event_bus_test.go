@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofrs/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+)
+
+// newTestRegistry creates registry with new and deleted message event types
+func newTestRegistry() *EventRegistry {
+	registry := NewEventRegistry()
+	Register(registry, NewMessageEventType, EventDef[entity.NewMessageEvent]{})
+	Register(registry, MessageDeletedEventType, EventDef[entity.MessageDeletedEvent]{})
+
+	return registry
+}
+
+// newTestRedisEventBus creates redis event bus connected to the server
+func newTestRedisEventBus(t *testing.T, s *miniredis.Miniredis) *RedisEventBus {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	bus := NewRedisEventBus(&RedisEventBusOpts{
+		Client:   client,
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Registry: newTestRegistry(),
+	})
+	t.Cleanup(func() {
+		_ = bus.Close()
+		_ = client.Close()
+	})
+
+	return bus
+}
+
+// waitTestSubscribers waits until count of the subscribers of events channel is n
+func waitTestSubscribers(t *testing.T, s *miniredis.Miniredis, n int) {
+	t.Helper()
+
+	assert.Eventually(t, func() bool {
+		return s.PubSubNumSub(DefaultEventsChannel)[DefaultEventsChannel] == n
+	}, time.Second*5, time.Millisecond*10, "should subscribe to events channel")
+}
+
+// receiveTestEvent receives event from the queue or fails on timeout
+func receiveTestEvent(t *testing.T, eventch <-chan entity.Event) entity.Event {
+	t.Helper()
+
+	select {
+	case event := <-eventch:
+		return event
+	case <-time.After(time.Second * 5):
+		t.Fatal("event should be received")
+	}
+
+	return entity.Event{}
+}
+
+func newTestMessageEvent() entity.Event {
+	return entity.Event{
+		ID:        uuid.Must(uuid.NewV4()),
+		Type:      NewMessageEventType,
+		Version:   NewMessageEventVersion,
+		Timestamp: time.Now().UTC().Truncate(time.Millisecond),
+		Payload: entity.NewMessageEvent{
+			ID:       "message",
+			SenderID: 1,
+			Message:  "hello",
+		},
+	}
+}
+
+func TestRedisEventBus(t *testing.T) {
+	s := miniredis.RunT(t)
+
+	local := newTestRedisEventBus(t, s)
+	remote := newTestRedisEventBus(t, s)
+	waitTestSubscribers(t, s, 2)
+
+	localId, localch := local.Subscribe(NewMessageEventType, nil)
+	defer local.Unsubscribe(localId)
+	remoteId, remotech := remote.Subscribe(NewMessageEventType, nil)
+	defer remote.Unsubscribe(remoteId)
+
+	event := newTestMessageEvent()
+	local.Publish(event)
+	event.Seq = 1
+
+	t.Run("check local delivery", func(t *testing.T) {
+		assert.Equal(t, event, receiveTestEvent(t, localch), "should be equal events")
+	})
+
+	t.Run("check remote delivery with typed payload", func(t *testing.T) {
+		assert.Equal(t, event, receiveTestEvent(t, remotech), "should be equal events")
+	})
+
+	t.Run("check events are delivered once", func(t *testing.T) {
+		next := newTestMessageEvent()
+		remote.Publish(next)
+
+		assert.Equal(t, next.ID, receiveTestEvent(t, localch).ID, "should be next event")
+		assert.Equal(t, next.ID, receiveTestEvent(t, remotech).ID, "should be next event")
+
+		select {
+		case event := <-localch:
+			t.Fatalf("event should not be duplicated: %v", event)
+		case event := <-remotech:
+			t.Fatalf("event should not be duplicated: %v", event)
+		case <-time.After(time.Millisecond * 100):
+		}
+	})
+}
+
+func TestRedisEventBus_Replay(t *testing.T) {
+	s := miniredis.RunT(t)
+
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	bus := NewRedisEventBus(&RedisEventBusOpts{
+		Client:    client,
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Registry:  newTestRegistry(),
+		Retention: 3,
+	})
+	t.Cleanup(func() {
+		_ = bus.Close()
+		_ = client.Close()
+	})
+
+	for i := 0; i < 4; i++ {
+		bus.Publish(newTestMessageEvent())
+	}
+	bus.Publish(entity.Event{
+		ID:      uuid.Must(uuid.NewV4()),
+		Type:    MessageDeletedEventType,
+		Payload: entity.MessageDeletedEvent{ID: "message", SenderID: 1},
+	})
+
+	t.Run("check replay after sequence number", func(t *testing.T) {
+		events, err := bus.Replay(context.Background(), 3)
+		assert.Equal(t, nil, err, "should not be error")
+		if assert.Equal(t, 2, len(events), "should be equal count of events") {
+			assert.Equal(t, uint64(4), events[0].Seq, "should be next event")
+			assert.IsType(t, entity.NewMessageEvent{}, events[0].Payload, "should be typed payload")
+			assert.Equal(t, uint64(5), events[1].Seq, "should share sequence number by types")
+			assert.IsType(
+				t,
+				entity.MessageDeletedEvent{},
+				events[1].Payload,
+				"should be typed payload",
+			)
+		}
+	})
+
+	t.Run("check replay of the last sequence number", func(t *testing.T) {
+		events, err := bus.Replay(context.Background(), 5)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 0, len(events), "should be no events")
+	})
+
+	t.Run("check undecodable event is skipped", func(t *testing.T) {
+		if _, err := s.Incr(bus.seqKey(), 1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.ZAdd(bus.logKey(), 6, "not event"); err != nil {
+			t.Fatal(err)
+		}
+		bus.Publish(newTestMessageEvent())
+
+		events, err := bus.Replay(context.Background(), 5)
+		assert.Equal(t, nil, err, "should not be error")
+		if assert.Equal(t, 1, len(events), "should be decoded events") {
+			assert.Equal(t, uint64(7), events[0].Seq, "should be event after skipped one")
+		}
+	})
+
+	t.Run("check expired sequence number", func(t *testing.T) {
+		_, err := bus.Replay(context.Background(), 1)
+		assert.ErrorIs(t, err, ErrSeqExpired, "should be expired")
+
+		_, err = bus.Replay(context.Background(), 8)
+		assert.ErrorIs(t, err, ErrSeqExpired, "should be expired")
+	})
+}
+
+func TestRedisEventBus_Reconnect(t *testing.T) {
+	s := miniredis.RunT(t)
+
+	bus := newTestRedisEventBus(t, s)
+	waitTestSubscribers(t, s, 1)
+
+	id, eventch := bus.Subscribe(NewMessageEventType, nil)
+	defer bus.Unsubscribe(id)
+
+	s.Close()
+	if err := s.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	waitTestSubscribers(t, s, 1)
+
+	publisher := newTestRedisEventBus(t, s)
+	event := newTestMessageEvent()
+	assert.Eventually(t, func() bool {
+		publisher.Publish(event)
+		select {
+		case received := <-eventch:
+			return received.ID == event.ID
+		case <-time.After(time.Millisecond * 50):
+			return false
+		}
+	}, time.Second*5, time.Millisecond*10, "should receive event after reconnect")
+}
+
+// logTestEvent adds event to the log of the bus without publishing it
+// as it is lost by pub/sub
+func logTestEvent(t *testing.T, s *miniredis.Miniredis, bus *RedisEventBus, event entity.Event) {
+	t.Helper()
+
+	seq, err := s.Incr(bus.seqKey(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(redisEvent{
+		Seq:       uint64(seq),
+		ID:        event.ID,
+		Type:      event.Type,
+		Version:   event.Version,
+		Timestamp: event.Timestamp,
+		Payload:   payload,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ZAdd(bus.logKey(), float64(seq), string(data)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedisEventBus_Backfill(t *testing.T) {
+	s := miniredis.RunT(t)
+
+	bus := newTestRedisEventBus(t, s)
+	waitTestSubscribers(t, s, 1)
+
+	id, eventch := bus.Subscribe(NewMessageEventType, nil)
+	defer bus.Unsubscribe(id)
+
+	bus.Publish(newTestMessageEvent())
+	assert.Equal(t, uint64(1), receiveTestEvent(t, eventch).Seq, "should be first event")
+
+	t.Run("check missed event is delivered in order", func(t *testing.T) {
+		missed := newTestMessageEvent()
+		logTestEvent(t, s, bus, missed)
+
+		next := newTestMessageEvent()
+		bus.Publish(next)
+
+		assert.Equal(t, missed.ID, receiveTestEvent(t, eventch).ID, "should be missed event")
+		assert.Equal(t, next.ID, receiveTestEvent(t, eventch).ID, "should be next event")
+	})
+
+	t.Run("check event published while reconnecting", func(t *testing.T) {
+		s.Close()
+		missed := newTestMessageEvent()
+		logTestEvent(t, s, bus, missed)
+		if err := s.Restart(); err != nil {
+			t.Fatal(err)
+		}
+
+		event := receiveTestEvent(t, eventch)
+		assert.Equal(t, missed.ID, event.ID, "should be missed event")
+		assert.Equal(t, uint64(4), event.Seq, "should be next sequence number")
+	})
+}
+
+func TestRedisEventBus_Close(t *testing.T) {
+	s := miniredis.RunT(t)
+
+	bus := NewRedisEventBus(&RedisEventBusOpts{
+		Client:   redis.NewClient(&redis.Options{Addr: s.Addr()}),
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Registry: newTestRegistry(),
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = bus.Close()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("close should stop receiving")
+	}
+	assert.Equal(t, context.Canceled, bus.ctx.Err(), "should cancel context")
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/entity"
+	"github.com/sazonovItas/gochat-tcp/cmd/gochat/app/domain/repo"
+)
+
+// UnreadService represents read models of conversations that are built by projections
+type UnreadService interface {
+	// MarkRead marks messages of the conversation as read by user,
+	// unread counter is reset after the projection handles it
+	// Errors: unknown
+	MarkRead(ctx context.Context, conversationID, userID int64) error
+
+	// GetUnreadCounters returns unread counters of conversations of the user
+	// Errors: unknown
+	GetUnreadCounters(ctx context.Context, userID int64) ([]entity.UnreadCounter, error)
+
+	// GetLastMessage returns the last message of the conversation
+	// Errors: ErrMessageNotFound, unknown
+	GetLastMessage(ctx context.Context, conversationID int64) (*entity.LastMessage, error)
+}
+
+type unreadService struct {
+	streams      *MessageStreams
+	counters     repo.UnreadCounterRepository
+	lastMessages repo.LastMessageRepository
+}
+
+func NewUnreadService(
+	streams *MessageStreams,
+	counters repo.UnreadCounterRepository,
+	lastMessages repo.LastMessageRepository,
+) UnreadService {
+	return &unreadService{
+		streams:      streams,
+		counters:     counters,
+		lastMessages: lastMessages,
+	}
+}
+
+// MarkRead is implementing interface UnreadService
+func (us *unreadService) MarkRead(ctx context.Context, conversationID, userID int64) error {
+	return us.streams.Read(ctx, conversationID, userID)
+}
+
+// GetUnreadCounters is implementing interface UnreadService
+func (us *unreadService) GetUnreadCounters(
+	ctx context.Context,
+	userID int64,
+) ([]entity.UnreadCounter, error) {
+	return us.counters.FindByUser(ctx, userID)
+}
+
+// GetLastMessage is implementing interface UnreadService
+func (us *unreadService) GetLastMessage(
+	ctx context.Context,
+	conversationID int64,
+) (*entity.LastMessage, error) {
+	return us.lastMessages.FindByConversation(ctx, conversationID)
+}
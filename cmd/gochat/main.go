@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/joho/godotenv"
 
@@ -37,5 +40,30 @@ func main() {
 	}
 
 	// Run application
-	log.Fatal(app.Run())
+	errch := make(chan error, 1)
+	go func() {
+		errch <- app.Run()
+	}()
+
+	// wait for stop signal or server error
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-errch:
+		if err != nil {
+			log.Fatalf("%s: %s", "error to run app", err.Error())
+		}
+		return
+	case <-ctx.Done():
+	}
+
+	// shutdown application
+	if err := app.Shutdown(context.Background()); err != nil {
+		log.Printf("%s: %s", "error to shutdown app", err.Error())
+	}
+
+	if err := <-errch; err != nil {
+		log.Fatalf("%s: %s", "error to run app", err.Error())
+	}
 }
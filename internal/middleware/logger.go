@@ -20,6 +20,7 @@ func Logger(log *slog.Logger) tcpws.Middleware {
 			defer func() {
 				log.Info("request completed",
 					slog.Uint64("request_id", requestId),
+					slog.String("correlation_id", req.ID),
 					slog.String("method", req.Method),
 					slog.String("path", req.Url),
 					slog.String("remote_addr", resp.Conn.RemoteAddr().String()),
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strings"
+
+	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
+	"github.com/sazonovItas/gochat-tcp/pkg/ratelimit"
+)
+
+// KeyFunc returns key of the request for rate limiting,
+// requests with empty key are not limited
+type KeyFunc func(resp *tcpws.Response, req *tcpws.Request) string
+
+// RateLimit rejects requests over the limit of the key with too many requests
+// error and retry after header, if limiter fails the request is served
+func RateLimit(limiter ratelimit.Limiter, key KeyFunc) tcpws.Middleware {
+	return func(next tcpws.HandlerFunc) tcpws.HandlerFunc {
+		fn := func(resp *tcpws.Response, req *tcpws.Request) {
+			k := key(resp, req)
+			if k == "" {
+				next.Serve(resp, req)
+				return
+			}
+
+			res, err := limiter.Allow(req.Ctx(), k)
+			if err != nil || res.Allowed {
+				next.Serve(resp, req)
+				return
+			}
+
+			retryAfter := int64(math.Ceil(res.RetryAfter.Seconds()))
+			resp.Header[tcpws.HeaderRetryAfter] = retryAfter
+			resp.Error(
+				http.StatusTooManyRequests,
+				tcpws.ErrCodeTooManyRequests,
+				"too many requests",
+				map[string]interface{}{"retry_after": retryAfter},
+			)
+		}
+
+		return fn
+	}
+}
+
+// KeyByRemoteAddr returns host of the client address
+func KeyByRemoteAddr(resp *tcpws.Response, req *tcpws.Request) string {
+	if resp.Conn == nil {
+		return ""
+	}
+
+	addr := resp.Conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return addr
+}
+
+// KeyByRoute returns matched route of the request e.g. "GET /member/{id}"
+func KeyByRoute(resp *tcpws.Response, req *tcpws.Request) string {
+	return req.Pattern()
+}
+
+// Keys combines keys of the functions, if any of keys is empty
+// then the combined key is empty too
+func Keys(fns ...KeyFunc) KeyFunc {
+	return func(resp *tcpws.Response, req *tcpws.Request) string {
+		keys := make([]string, 0, len(fns))
+		for _, fn := range fns {
+			k := fn(resp, req)
+			if k == "" {
+				return ""
+			}
+			keys = append(keys, k)
+		}
+
+		return strings.Join(keys, ":")
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
+	"github.com/sazonovItas/gochat-tcp/pkg/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	limiter := ratelimit.NewMemoryLimiter(ratelimit.Limit{Rate: 1, Per: time.Hour, Burst: 1})
+
+	var calls int
+	handler := RateLimit(limiter, func(resp *tcpws.Response, req *tcpws.Request) string {
+		return req.Url
+	})(func(resp *tcpws.Response, req *tcpws.Request) {
+		calls++
+		resp.StatusCode = http.StatusOK
+	})
+
+	t.Run("check allowed request", func(t *testing.T) {
+		resp := &tcpws.Response{Header: map[string]interface{}{}}
+		handler.Serve(resp, &tcpws.Request{Url: "/limited"})
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "should be ok status")
+		assert.Equal(t, 1, calls, "should call handler")
+	})
+
+	t.Run("check request over limit", func(t *testing.T) {
+		resp := &tcpws.Response{Header: map[string]interface{}{}}
+		handler.Serve(resp, &tcpws.Request{Url: "/limited"})
+
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode, "should be too many requests")
+		assert.Equal(t, 1, calls, "should not call handler")
+		assert.Equal(
+			t,
+			int64(time.Hour.Seconds()),
+			resp.Header[tcpws.HeaderRetryAfter],
+			"should be retry after header",
+		)
+
+		var body tcpws.ErrorBody
+		_ = json.Unmarshal([]byte(resp.Body), &body)
+		assert.Equal(t, tcpws.ErrCodeTooManyRequests, body.Code, "should be too many requests code")
+	})
+
+	t.Run("check empty key is not limited", func(t *testing.T) {
+		resp := &tcpws.Response{Header: map[string]interface{}{}}
+		handler.Serve(resp, &tcpws.Request{})
+		handler.Serve(resp, &tcpws.Request{})
+
+		assert.Equal(t, 3, calls, "should call handler")
+	})
+}
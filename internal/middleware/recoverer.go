@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+
+	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
+)
+
+var panics uint64
+
+// Recoverer recovers panic of the handler, logs stack of the panic with request id
+// and replies with internal error, ws connection is closed by the server after
+// handler returns, so panic affects only the connection of the request
+func Recoverer(log *slog.Logger) tcpws.Middleware {
+	return func(next tcpws.HandlerFunc) tcpws.HandlerFunc {
+		fn := func(resp *tcpws.Response, req *tcpws.Request) {
+			defer func() {
+				rvr := recover()
+				if rvr == nil {
+					return
+				}
+
+				logPanic(log, req, rvr)
+
+				if req.Proto == tcpws.ProtoWS {
+					return
+				}
+
+				resp.Error(
+					http.StatusInternalServerError,
+					tcpws.ErrCodeInternal,
+					http.StatusText(http.StatusInternalServerError),
+					nil,
+				)
+			}()
+
+			next.Serve(resp, req)
+		}
+
+		return fn
+	}
+}
+
+// Go runs fn in goroutine started by handler of the request, panic of the goroutine
+// is recovered and logged like panic of the handler, ws connection of the request
+// is closed, so the handler stops serving it
+func Go(log *slog.Logger, resp *tcpws.Response, req *tcpws.Request, fn func()) {
+	go func() {
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+
+			logPanic(log, req, rvr)
+
+			if req.Proto == tcpws.ProtoWS && resp.Conn != nil {
+				_ = resp.Conn.Close()
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// logPanic counts recovered panic and logs its stack with request id
+func logPanic(log *slog.Logger, req *tcpws.Request, rvr interface{}) {
+	atomic.AddUint64(&panics, 1)
+
+	var requestId uint64
+	if reqId, ok := req.Ctx().Value(RequestIdKey).(uint64); ok {
+		requestId = reqId
+	}
+
+	log.Error("panic recovered",
+		slog.Uint64("request_id", requestId),
+		slog.String("method", req.Method),
+		slog.String("path", req.Url),
+		slog.String("proto", req.Proto),
+		slog.String("panic", fmt.Sprint(rvr)),
+		slog.String("stack", string(debug.Stack())),
+	)
+}
+
+// PanicCount returns count of recovered panics since start of the server
+func PanicCount() uint64 {
+	return atomic.LoadUint64(&panics)
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	tcpws "github.com/sazonovItas/gochat-tcp/internal/server"
+)
+
+func TestRecoverer(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	handler := RequestId()(Recoverer(log)(func(resp *tcpws.Response, req *tcpws.Request) {
+		panic("test panic")
+	}))
+
+	t.Run("check internal error for request", func(t *testing.T) {
+		before := PanicCount()
+
+		resp := &tcpws.Response{Header: map[string]interface{}{}}
+		handler.Serve(resp, &tcpws.Request{Method: "GET", Proto: tcpws.ProtoHTTP})
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode, "should be internal error")
+		assert.Equal(t, before+1, PanicCount(), "should increment panic count")
+
+		var body tcpws.ErrorBody
+		_ = json.Unmarshal([]byte(resp.Body), &body)
+		assert.Equal(t, tcpws.ErrCodeInternal, body.Code, "should be internal error code")
+		assert.NotEqual(t, uint64(0), body.RequestId, "should have request id")
+	})
+
+	t.Run("check ws request", func(t *testing.T) {
+		resp := &tcpws.Response{Header: map[string]interface{}{}}
+		handler.Serve(resp, &tcpws.Request{Method: tcpws.ProtoWS, Proto: tcpws.ProtoWS})
+
+		assert.Equal(t, 0, resp.StatusCode, "should not set response for ws request")
+	})
+}
+
+func TestGo(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	before := PanicCount()
+	Go(log, &tcpws.Response{}, &tcpws.Request{Method: "GET", Proto: tcpws.ProtoHTTP}, func() {
+		panic("test panic")
+	})
+
+	assert.Eventually(t, func() bool {
+		return PanicCount() == before+1
+	}, time.Second, 10*time.Millisecond, "should recover panic of the goroutine")
+}
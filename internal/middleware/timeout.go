@@ -17,9 +17,12 @@ func Timeout(timeout time.Duration) tcpws.Middleware {
 				defer func() {
 					cancel()
 					if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-						resp.StatusCode = http.StatusBadGateway
-						resp.Status = http.StatusText(http.StatusBadGateway)
-						resp.Body = ""
+						resp.Error(
+							http.StatusBadGateway,
+							tcpws.ErrCodeTimeout,
+							"request timeout",
+							nil,
+						)
 					}
 				}()
 
@@ -0,0 +1,58 @@
+package tcpws
+
+import "strings"
+
+// Group is set of routes with common url prefix and middlewares
+type Group struct {
+	mux    *MuxHandler
+	parent *Group
+
+	prefix      string
+	middlewares []Middleware
+}
+
+// Use adds middleware for all routes of the group
+// it must not be called while serving connections
+func (g *Group) Use(md Middleware) {
+	g.middlewares = append(g.middlewares, md)
+}
+
+// Group creates nested group, url prefix of nested group is
+// appended to the group prefix and middlewares are applied after group ones
+func (g *Group) Group(prefix string, mds ...Middleware) *Group {
+	return &Group{
+		mux:         g.mux,
+		parent:      g,
+		prefix:      joinUrl(g.prefix, prefix),
+		middlewares: mds,
+	}
+}
+
+// HandleFunc sets handler function for method and url with prefix of the group
+// Will panic if can't add handler to routing tree
+func (g *Group) HandleFunc(method, url string, handler HandlerFunc, mds ...Middleware) {
+	g.mux.handle(method, joinUrl(g.prefix, url), g, mds, handler)
+}
+
+// chain returns middlewares of the group and parent groups from outer group to inner one
+func (g *Group) chain() []Middleware {
+	if g == nil {
+		return nil
+	}
+
+	return append(g.parent.chain(), g.middlewares...)
+}
+
+// joinUrl joins url prefix and url with one slash
+func joinUrl(prefix, url string) string {
+	prefix = strings.TrimRight(prefix, "/")
+	url = strings.TrimLeft(url, "/")
+	switch {
+	case url == "" && prefix == "":
+		return "/"
+	case url == "":
+		return prefix
+	}
+
+	return prefix + "/" + url
+}
@@ -0,0 +1,91 @@
+package tcpws
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// nameMiddleware appends name to response body before calling next handler
+func nameMiddleware(name string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(resp *Response, req *Request) {
+			resp.Body += name + ";"
+			next.Serve(resp, req)
+		}
+	}
+}
+
+func TestMuxHandler_Group(t *testing.T) {
+	handler := func(resp *Response, req *Request) {
+		resp.StatusCode = http.StatusOK
+		resp.Body += "handler"
+	}
+
+	mux := NewMuxHandler()
+	mux.KeepAlive(time.Second)
+
+	// routes are registered before middlewares to check order independence
+	mux.HandleFunc("GET", "/health", handler)
+
+	v1 := mux.Group("/api/v1/", nameMiddleware("v1"))
+	v1.HandleFunc("GET", "/messages", handler, nameMiddleware("route"))
+
+	auth := v1.Group("/member", nameMiddleware("auth"))
+	auth.HandleFunc("GET", "/{id}", handler)
+
+	mux.Group("/api/v2").HandleFunc("GET", "/messages", handler)
+
+	mux.Use(nameMiddleware("global"))
+	v1.Use(nameMiddleware("v1-late"))
+
+	client, server := newTestConns(t)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.Serve(context.Background(), server)
+	}()
+	defer func() {
+		_ = client.Close()
+		<-done
+	}()
+
+	tests := map[string]string{
+		"/health":          "global;handler",
+		"/api/v1/messages": "global;v1;v1-late;route;handler",
+		"/api/v1/member/1": "global;v1;v1-late;auth;handler",
+		"/api/v2/messages": "global;handler",
+	}
+
+	for url, want := range tests {
+		t.Run("check middlewares chain for "+url, func(t *testing.T) {
+			writeTestRequest(t, client, "GET", url)
+			resp := readTestResponse(t, client)
+			assert.Equal(t, want, resp["body"], "should be equal middlewares chain")
+		})
+	}
+
+	t.Run("check not found is covered by global middlewares", func(t *testing.T) {
+		writeTestRequest(t, client, "GET", "/api/v3/messages")
+		resp := readTestResponse(t, client)
+		assert.Equal(t, float64(http.StatusNotFound), resp["status_code"], "should be not found")
+	})
+}
+
+func Test_joinUrl(t *testing.T) {
+	tests := map[[2]string]string{
+		{"/api/v1", "/messages"}:  "/api/v1/messages",
+		{"/api/v1/", "messages"}:  "/api/v1/messages",
+		{"/api/v1", ""}:           "/api/v1",
+		{"", "/messages"}:         "/messages",
+		{"", "/"}:                 "/",
+		{"/api", "/{id}/message"}: "/api/{id}/message",
+	}
+
+	for in, want := range tests {
+		assert.Equal(t, want, joinUrl(in[0], in[1]), "should be equal urls")
+	}
+}
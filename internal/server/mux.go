@@ -1,7 +1,15 @@
 package tcpws
 
 import (
+	"context"
+	"errors"
+	"io"
 	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	gotcpws "github.com/sazonovItas/go-tcpws"
 )
@@ -11,6 +19,9 @@ const (
 	ProtoWS   = "ws"
 )
 
+// DefaultIdleTimeout is used for keep-alive connections if idle timeout is not set
+const DefaultIdleTimeout = time.Minute
+
 type (
 	// Middleware type for covering handler functions
 	Middleware func(next HandlerFunc) HandlerFunc
@@ -27,75 +38,255 @@ func NewMuxHandler() *MuxHandler {
 }
 
 // MuxHandler specifies what handler will handle request
-// TODO: replace middlewares with one covered middleware
+// Middlewares are applied when request is served, so order of Use and
+// HandleFunc calls doesn't matter. Handler is covered by middlewares in
+// order: mux middlewares, group middlewares from outer group to inner one,
+// route middlewares, the first middleware of the chain is the outermost
 type MuxHandler struct {
 	routerTree *routingNode
 
 	middlewares []Middleware
+
+	// keepAlive specifies that connection can carry many requests
+	keepAlive bool
+
+	// idleTimeout specifies how long keep-alive connection waits for next request
+	idleTimeout time.Duration
+
+	// maxPipelined specifies how many requests of one connection are served concurrently
+	maxPipelined int
+
+	// notFound handles requests which url doesn't match any pattern
+	notFound HandlerFunc
+
+	// methodNotAllowed handles requests which url matches pattern of other methods
+	methodNotAllowed HandlerFunc
 }
 
-// Add new middleware for request
+// Use adds middleware for all requests
+// it must not be called while serving connections
 func (mh *MuxHandler) Use(md Middleware) {
 	mh.middlewares = append(mh.middlewares, md)
 }
 
-func (mh *MuxHandler) newMiddlewareHandler(h HandlerFunc) HandlerFunc {
-	handler := h
-	for _, middleware := range mh.middlewares {
-		handler = middleware(handler)
+// KeepAlive enables serving many request/response frames on one connection,
+// connection is closed if there is no new request during idleTimeout or
+// client sends close frame, if idleTimeout is 0 will use DefaultIdleTimeout
+func (mh *MuxHandler) KeepAlive(idleTimeout time.Duration) {
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
 	}
 
-	return handler
+	mh.keepAlive, mh.idleTimeout = true, idleTimeout
 }
 
-// Set handler function for method and url
+// Pipeline sets how many requests of one keep-alive connection
+// are served concurrently, responses are written as each request
+// is done, so client should match them by request id
+func (mh *MuxHandler) Pipeline(limit int) {
+	mh.maxPipelined = limit
+}
+
+// NotFound sets handler for requests which url doesn't match any pattern
+func (mh *MuxHandler) NotFound(handler HandlerFunc) {
+	mh.notFound = handler
+}
+
+// MethodNotAllowed sets handler for requests which url matches only
+// patterns of other methods, the methods are in Allow header of the
+// response and Request.AllowedMethods
+func (mh *MuxHandler) MethodNotAllowed(handler HandlerFunc) {
+	mh.methodNotAllowed = handler
+}
+
+// Set handler function for method and url with route middlewares
 // Will panic if can't add handler to routing tree
-func (mh *MuxHandler) HandleFunc(method, url string, handler HandlerFunc) {
+func (mh *MuxHandler) HandleFunc(method, url string, handler HandlerFunc, mds ...Middleware) {
+	mh.handle(method, url, nil, mds, handler)
+}
+
+// Group creates group of routes with url prefix and middlewares
+func (mh *MuxHandler) Group(prefix string, mds ...Middleware) *Group {
+	return &Group{
+		mux:         mh,
+		prefix:      prefix,
+		middlewares: mds,
+	}
+}
+
+// handle adds handler to routing tree, handler is covered
+// by middlewares of the mux, group and route on serving
+func (mh *MuxHandler) handle(
+	method, url string,
+	group *Group,
+	mds []Middleware,
+	handler HandlerFunc,
+) {
 	p, err := parsePattern(method, url)
 	if err != nil {
 		panic(err)
 	}
 
-	handler = mh.newMiddlewareHandler(handler)
+	mh.routerTree.addPattern(p, func(resp *Response, req *Request) {
+		mh.chain(group, mds, handler).Serve(resp, req)
+	})
+}
+
+// chain covers handler with mux, group and route middlewares
+func (mh *MuxHandler) chain(group *Group, mds []Middleware, handler HandlerFunc) HandlerFunc {
+	var chain []Middleware
+	chain = append(chain, mh.middlewares...)
+	chain = append(chain, group.chain()...)
+	chain = append(chain, mds...)
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		handler = chain[i](handler)
+	}
 
-	mh.routerTree.addPattern(p, handler)
+	return handler
 }
 
 // Serve connection and call handlers for serving
+// if keep-alive is enabled, serves requests until idle timeout,
+// close frame or ws request that takes over the connection,
+// pipelined requests are served concurrently up to pipeline limit,
+// ctx is used as base context for requests and stops keep-alive serving
 // TODO: Add logger for serving new connection
-func (mh *MuxHandler) Serve(conn *gotcpws.Conn) {
-	req, err := conn.ReadFrame()
-	if err != nil {
-		log.Printf("error to read frame: %s", err.Error())
-		return
-	}
+func (mh *MuxHandler) Serve(ctx context.Context, conn *gotcpws.Conn) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
 
-	request, err := newRequest(req)
-	if err != nil {
-		log.Printf("error to create new request: %s", err.Error())
-		return
+	limit := make(chan struct{}, mh.pipelineLimit())
+	for {
+		if mh.keepAlive {
+			_ = conn.SetReadDeadline(time.Now().Add(mh.idleTimeout))
+		}
+
+		// check context after setting deadline, so deadline set on shutdown isn't lost
+		if ctx.Err() != nil {
+			return
+		}
+
+		frame, err := conn.ReadFrame()
+		if err != nil {
+			if !isClosedConnError(err) {
+				log.Printf("error to read frame: %s", err.Error())
+			}
+			return
+		}
+
+		// handlers are not limited by idle timeout
+		if mh.keepAlive {
+			_ = conn.SetReadDeadline(time.Time{})
+		}
+
+		request, err := newRequest(frame)
+		if err != nil {
+			log.Printf("error to create new request: %s", err.Error())
+			return
+		}
+		request.ctx = ctx
+
+		// ws request takes over the connection, so wait for
+		// pipelined requests and serve it in the same goroutine
+		if !mh.keepAlive || request.Proto == ProtoWS {
+			wg.Wait()
+			mh.serveRequest(conn, request)
+			return
+		}
+
+		limit <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-limit
+				wg.Done()
+			}()
+
+			mh.serveRequest(conn, request)
+		}()
 	}
+}
+
+// serveRequest finds handler for the request, serves it and writes response
+func (mh *MuxHandler) serveRequest(conn *gotcpws.Conn, request *Request) {
 	response := newResponse(conn)
 	response.Req = request
 
-	n, m := mh.routerTree.match(request.Method, request.Url)
-	if n == nil {
-		log.Printf("mismatched route")
+	handler, found := mh.handler(request, response)
+	handler.Serve(response, request)
+
+	// ws handler writes response itself if route was found, but
+	// if it returns without writing set response e.g. rejected by
+	// middleware, response is written as for other protocols
+	if response.written {
 		return
 	}
 
-	// get pattern and matches from url
-	request.pattern, request.matches = n.pattern, m
+	if request.Proto != ProtoWS || !found || response.StatusCode != 0 {
+		if err := response.Write(); err != nil {
+			log.Printf("error to write response: %s", err.Error())
+		}
+	}
+}
+
+// handler returns handler for the request and false if route was not found
+func (mh *MuxHandler) handler(request *Request, response *Response) (HandlerFunc, bool) {
+	n, m := mh.routerTree.match(request.Method, request.Url)
+	if n != nil && n.handler != nil {
+		// get pattern and matches from url
+		request.pattern, request.matches = n.pattern, m
+		return n.handler, true
+	}
 
-	// TODO: Add default handler for unknown url
-	handler := n.handler
+	if allowed := mh.routerTree.allowedMethods(request.Url); len(allowed) > 0 {
+		request.allowed = allowed
+		response.Header["Allow"] = strings.Join(allowed, ", ")
+
+		handler := mh.methodNotAllowed
+		if handler == nil {
+			handler = defaultMethodNotAllowed
+		}
+		return mh.chain(nil, nil, handler), false
+	}
+
+	handler := mh.notFound
 	if handler == nil {
-		return
+		handler = defaultNotFound
 	}
+	return mh.chain(nil, nil, handler), false
+}
 
-	handler.Serve(response, request)
+// defaultNotFound replies with not found error
+func defaultNotFound(resp *Response, req *Request) {
+	resp.Error(http.StatusNotFound, ErrCodeNotFound, "route not found", nil)
+}
 
-	if request.Proto != ProtoWS {
-		_ = response.Write()
+// defaultMethodNotAllowed replies with method not allowed error and allowed methods
+func defaultMethodNotAllowed(resp *Response, req *Request) {
+	resp.Error(
+		http.StatusMethodNotAllowed,
+		ErrCodeMethodNotAllowed,
+		"method not allowed",
+		map[string]interface{}{"allowed_methods": req.AllowedMethods()},
+	)
+}
+
+// pipelineLimit returns limit of concurrently served requests on one connection
+func (mh *MuxHandler) pipelineLimit() int {
+	if mh.maxPipelined <= 0 {
+		return 1
 	}
+
+	return mh.maxPipelined
+}
+
+// isClosedConnError checks that error is caused by closing or idle connection
+func isClosedConnError(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
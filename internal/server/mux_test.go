@@ -0,0 +1,294 @@
+package tcpws
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	gotcpws "github.com/sazonovItas/go-tcpws"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestConns creates connected client and server frame connections
+func newTestConns(t *testing.T) (client, server *gotcpws.Conn) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client = gotcpws.NewFrameConnection(c, nil, nil, 0, true)
+	server = gotcpws.NewFrameConnection(s, nil, nil, 0, true)
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return
+}
+
+// writeTestRequest writes request to the connection
+func writeTestRequest(t *testing.T, conn *gotcpws.Conn, method, url string) {
+	t.Helper()
+
+	writeTestRequestWithId(t, conn, "", method, url)
+}
+
+// readTestResponse reads response from the connection
+func readTestResponse(t *testing.T, conn *gotcpws.Conn) map[string]interface{} {
+	t.Helper()
+
+	frame, err := conn.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(frame, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	return resp
+}
+
+func TestMuxHandler_KeepAlive(t *testing.T) {
+	mux := NewMuxHandler()
+	mux.KeepAlive(time.Second)
+
+	var calls int
+	mux.HandleFunc("GET", "/ping", func(resp *Response, req *Request) {
+		calls++
+		resp.StatusCode = http.StatusOK
+		resp.Body = req.Url
+	})
+
+	client, server := newTestConns(t)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.Serve(context.Background(), server)
+	}()
+
+	t.Run("check many requests on one connection", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			writeTestRequest(t, client, "GET", "/ping")
+			resp := readTestResponse(t, client)
+			assert.Equal(t, float64(http.StatusOK), resp["status_code"], "should be ok status")
+			assert.Equal(t, "/ping", resp["body"], "should be equal body")
+		}
+		assert.Equal(t, 3, calls, "should be called for each request")
+	})
+
+	t.Run("check close frame stops serving", func(t *testing.T) {
+		_ = client.Close()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("serve should return after close frame")
+		}
+	})
+}
+
+func TestMuxHandler_IdleTimeout(t *testing.T) {
+	mux := NewMuxHandler()
+	mux.KeepAlive(time.Millisecond * 50)
+
+	_, server := newTestConns(t)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.Serve(context.Background(), server)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("serve should return after idle timeout")
+	}
+}
+
+func TestMuxHandler_WithoutKeepAlive(t *testing.T) {
+	mux := NewMuxHandler()
+	mux.HandleFunc("GET", "/ping", func(resp *Response, req *Request) {
+		resp.StatusCode = http.StatusOK
+	})
+
+	client, server := newTestConns(t)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.Serve(context.Background(), server)
+	}()
+
+	writeTestRequest(t, client, "GET", "/ping")
+	resp := readTestResponse(t, client)
+	assert.Equal(t, float64(http.StatusOK), resp["status_code"], "should be ok status")
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("serve should return after first request")
+	}
+}
+
+func TestMuxHandler_Pipeline(t *testing.T) {
+	mux := NewMuxHandler()
+	mux.KeepAlive(time.Second)
+	mux.Pipeline(2)
+
+	release := make(chan struct{})
+	mux.HandleFunc("GET", "/slow", func(resp *Response, req *Request) {
+		<-release
+		resp.StatusCode = http.StatusOK
+	})
+	mux.HandleFunc("GET", "/fast", func(resp *Response, req *Request) {
+		resp.StatusCode = http.StatusOK
+	})
+
+	client, server := newTestConns(t)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.Serve(context.Background(), server)
+	}()
+	defer func() {
+		_ = client.Close()
+		<-done
+	}()
+
+	writeTestRequestWithId(t, client, "1", "GET", "/slow")
+	writeTestRequestWithId(t, client, "2", "GET", "/fast")
+
+	t.Run("check fast response is not blocked by slow one", func(t *testing.T) {
+		resp := readTestResponse(t, client)
+		assert.Equal(t, "2", resp["id"], "should be response for fast request")
+	})
+
+	t.Run("check slow response has its id", func(t *testing.T) {
+		close(release)
+		resp := readTestResponse(t, client)
+		assert.Equal(t, "1", resp["id"], "should be response for slow request")
+	})
+}
+
+// writeTestRequestWithId writes request with correlation id to the connection
+func writeTestRequestWithId(t *testing.T, conn *gotcpws.Conn, id, method, url string) {
+	t.Helper()
+
+	req, err := json.Marshal(map[string]interface{}{
+		"id":     id,
+		"method": method,
+		"url":    url,
+		"proto":  ProtoHTTP,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := conn.Write(req); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMuxHandler_NotFound(t *testing.T) {
+	mux := NewMuxHandler()
+	mux.KeepAlive(time.Second)
+	mux.HandleFunc("GET", "/user/{id}", func(resp *Response, req *Request) {
+		resp.StatusCode = http.StatusOK
+	})
+	mux.HandleFunc("DELETE", "/user/{id}", func(resp *Response, req *Request) {
+		resp.StatusCode = http.StatusOK
+	})
+
+	client, server := newTestConns(t)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.Serve(context.Background(), server)
+	}()
+	defer func() {
+		_ = client.Close()
+		<-done
+	}()
+
+	t.Run("check default not found", func(t *testing.T) {
+		writeTestRequest(t, client, "GET", "/unknown")
+		resp := readTestResponse(t, client)
+		assert.Equal(t, float64(http.StatusNotFound), resp["status_code"], "should be not found")
+
+		var body ErrorBody
+		_ = json.Unmarshal([]byte(resp["body"].(string)), &body)
+		assert.Equal(t, ErrCodeNotFound, body.Code, "should be not found code")
+	})
+
+	t.Run("check default method not allowed", func(t *testing.T) {
+		writeTestRequest(t, client, "POST", "/user/1")
+		resp := readTestResponse(t, client)
+		assert.Equal(
+			t,
+			float64(http.StatusMethodNotAllowed),
+			resp["status_code"],
+			"should be method not allowed",
+		)
+		assert.Equal(
+			t,
+			"DELETE, GET",
+			resp["header"].(map[string]interface{})["Allow"],
+			"should be allowed methods",
+		)
+
+		var body ErrorBody
+		_ = json.Unmarshal([]byte(resp["body"].(string)), &body)
+		assert.Equal(t, ErrCodeMethodNotAllowed, body.Code, "should be method not allowed code")
+	})
+
+	t.Run("check custom not found", func(t *testing.T) {
+		mux.NotFound(func(resp *Response, req *Request) {
+			resp.StatusCode = http.StatusTeapot
+		})
+
+		writeTestRequest(t, client, "GET", "/unknown")
+		resp := readTestResponse(t, client)
+		assert.Equal(t, float64(http.StatusTeapot), resp["status_code"], "should be custom status")
+	})
+}
+
+func TestMuxHandler_RejectedWS(t *testing.T) {
+	mux := NewMuxHandler()
+	mux.HandleFunc(ProtoWS, "/chat", func(resp *Response, req *Request) {
+		t.Error("handler should not be called")
+	}, func(next HandlerFunc) HandlerFunc {
+		return func(resp *Response, req *Request) {
+			resp.Error(http.StatusUnauthorized, ErrCodeUnauthorized, "unauthorized", nil)
+		}
+	})
+
+	client, server := newTestConns(t)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.Serve(context.Background(), server)
+	}()
+
+	writeTestWSRequest(t, client, "/chat")
+	resp := readTestResponse(t, client)
+	assert.Equal(t, float64(http.StatusUnauthorized), resp["status_code"], "should be unauthorized")
+	<-done
+}
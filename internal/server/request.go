@@ -3,14 +3,40 @@ package tcpws
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"strconv"
+	"strings"
 )
 
+var ErrParamNotFound = errors.New("param not found")
+
+// ParamError is error of converting url param or query value
+type ParamError struct {
+	Name  string
+	Value string
+	Err   error
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("invalid param %q with value %q: %s", e.Name, e.Value, e.Err.Error())
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
 // A Request represent a custom request received by a server
 type Request struct {
+	// ID specifies optional client id of the request that is echoed in response
+	ID string
+
 	// Method specifies a custom method
 	Method string
 
 	// Url and Proto specifies for what this request will be used
+	// Url can contain query string e.g. /api/v1/messages?limit=50
 	Url   string
 	Proto string
 
@@ -20,6 +46,9 @@ type Request struct {
 	// matches specifies params in url
 	matches []string
 
+	// allowed specifies methods that have pattern for url if method is not allowed
+	allowed []string
+
 	// Header specifies some values for the server e.g. session key
 	Header map[string]interface{}
 
@@ -33,6 +62,7 @@ type Request struct {
 // Create new request from bytes
 func newRequest(msg []byte) (*Request, error) {
 	type request struct {
+		ID     string `json:"id"`
 		Method string `json:"method"`
 		Url    string `json:"url"`
 		Proto  string `json:"proto"`
@@ -48,6 +78,7 @@ func newRequest(msg []byte) (*Request, error) {
 	}
 
 	return &Request{
+		ID:     req.ID,
 		Method: req.Method,
 		Url:    req.Url,
 		Proto:  req.Proto,
@@ -77,6 +108,9 @@ func (r *Request) WithContext(ctx context.Context) *Request {
 
 func (r *Request) Params() map[string]string {
 	params := make(map[string]string)
+	if r.pattern == nil {
+		return params
+	}
 
 	i := 0
 	for _, p := range r.pattern.segments {
@@ -90,6 +124,10 @@ func (r *Request) Params() map[string]string {
 }
 
 func (r *Request) ParamByName(name string) string {
+	if r.pattern == nil {
+		return ""
+	}
+
 	i := 0
 	for _, p := range r.pattern.segments {
 		if p.wild {
@@ -102,3 +140,52 @@ func (r *Request) ParamByName(name string) string {
 
 	return ""
 }
+
+// Pattern returns method and pattern of the route matched by the request
+// e.g. "GET /member/{id}", it is empty if no route is matched
+func (r *Request) Pattern() string {
+	if r.pattern == nil {
+		return ""
+	}
+
+	return r.pattern.method + " " + r.pattern.str
+}
+
+// AllowedMethods returns methods that have pattern for the url of the request,
+// it is set for MethodNotAllowed handler
+func (r *Request) AllowedMethods() []string {
+	return r.allowed
+}
+
+// Query returns parsed query values of the url, invalid pairs are skipped
+func (r *Request) Query() neturl.Values {
+	_, rawQuery, _ := strings.Cut(r.Url, "?")
+
+	values, _ := neturl.ParseQuery(rawQuery)
+	return values
+}
+
+// ParamInt64 returns url param converted to int64
+// Errors: *ParamError with ErrParamNotFound or strconv error
+func (r *Request) ParamInt64(name string) (int64, error) {
+	return parseInt64(name, r.ParamByName(name))
+}
+
+// QueryInt64 returns query value converted to int64
+// Errors: *ParamError with ErrParamNotFound or strconv error
+func (r *Request) QueryInt64(name string) (int64, error) {
+	return parseInt64(name, r.Query().Get(name))
+}
+
+func parseInt64(name, value string) (int64, error) {
+	if value == "" {
+		return 0, &ParamError{Name: name, Value: value, Err: ErrParamNotFound}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, &ParamError{Name: name, Value: value, Err: err}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,47 @@
+package tcpws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest_TypedParams(t *testing.T) {
+	p, err := parsePattern("GET", "/user/{id}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &Request{
+		Url:     "/user/42?limit=50&offset=abc",
+		pattern: p,
+		matches: []string{"42"},
+	}
+
+	t.Run("check param int64", func(t *testing.T) {
+		id, err := req.ParamInt64("id")
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, int64(42), id, "should be equal ids")
+	})
+
+	t.Run("check query", func(t *testing.T) {
+		assert.Equal(t, "50", req.Query().Get("limit"), "should be equal query values")
+
+		limit, err := req.QueryInt64("limit")
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, int64(50), limit, "should be equal limits")
+	})
+
+	t.Run("check invalid value", func(t *testing.T) {
+		_, err := req.QueryInt64("offset")
+		var paramErr *ParamError
+		if assert.ErrorAs(t, err, &paramErr, "should be param error") {
+			assert.Equal(t, "offset", paramErr.Name, "should be equal param names")
+		}
+	})
+
+	t.Run("check not found param", func(t *testing.T) {
+		_, err := req.ParamInt64("unknown")
+		assert.ErrorIs(t, err, ErrParamNotFound, "should be param not found error")
+	})
+}
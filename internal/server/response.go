@@ -2,6 +2,7 @@ package tcpws
 
 import (
 	"encoding/json"
+	"net/http"
 
 	gotcpws "github.com/sazonovItas/go-tcpws"
 )
@@ -18,6 +19,9 @@ type Response struct {
 
 	// Connection using for ws like communication
 	Conn *gotcpws.Conn
+
+	// written specifies that response was written to the connection
+	written bool
 }
 
 func newResponse(conn *gotcpws.Conn) *Response {
@@ -29,6 +33,7 @@ func newResponse(conn *gotcpws.Conn) *Response {
 
 func (resp *Response) Write() error {
 	type response struct {
+		ID         string `json:"id,omitempty"`
 		Status     string `json:"status"`
 		StatusCode int    `json:"status_code"`
 
@@ -39,6 +44,7 @@ func (resp *Response) Write() error {
 	resp.Header["Content-Length"] = len(resp.Body)
 
 	wrtResp := response{
+		ID:         resp.requestId(),
 		Status:     resp.Status,
 		StatusCode: resp.StatusCode,
 
@@ -46,6 +52,64 @@ func (resp *Response) Write() error {
 		Body:   resp.Body,
 	}
 
+	resp.written = true
 	err := json.NewEncoder(resp.Conn).Encode(wrtResp)
 	return err
 }
+
+// requestId returns id of the request that response is for
+func (resp *Response) requestId() string {
+	if resp.Req == nil {
+		return ""
+	}
+
+	return resp.Req.ID
+}
+
+// Error codes of the error body
+const (
+	ErrCodeBadRequest       = "bad_request"
+	ErrCodeUnauthorized     = "unauthorized"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeMethodNotAllowed = "method_not_allowed"
+	ErrCodeTimeout          = "timeout"
+	ErrCodeTooManyRequests  = "too_many_requests"
+	ErrCodeInternal         = "internal_error"
+)
+
+// HeaderRequestId is response header with id of the request given by server
+const HeaderRequestId = "Request-Id"
+
+// HeaderRetryAfter is response header with seconds to wait before retrying the request
+const HeaderRetryAfter = "Retry-After"
+
+// ErrorBody is machine-readable body of the response with error
+type ErrorBody struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	RequestId uint64      `json:"request_id,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+// Error sets status code and error body of the response,
+// request id is taken from HeaderRequestId of the response
+func (resp *Response) Error(statusCode int, code, message string, details interface{}) {
+	errBody := ErrorBody{
+		Code:    code,
+		Message: message,
+		Details: details,
+	}
+	if reqId, ok := resp.Header[HeaderRequestId].(uint64); ok {
+		errBody.RequestId = reqId
+	}
+
+	resp.StatusCode = statusCode
+	resp.Status = http.StatusText(statusCode)
+
+	body, err := json.Marshal(errBody)
+	if err != nil {
+		resp.Body = ""
+		return
+	}
+	resp.Body = string(body)
+}
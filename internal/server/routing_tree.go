@@ -2,10 +2,14 @@ package tcpws
 
 import (
 	"errors"
+	"sort"
 	"strings"
 )
 
-var errEmptyPattern = errors.New("empty pattern")
+var (
+	errEmptyPattern    = errors.New("empty pattern")
+	errCatchAllNotLast = errors.New("catch-all segment is not last")
+)
 
 // pattern is something that mathes a path for request
 type pattern struct {
@@ -18,9 +22,11 @@ type pattern struct {
 // segment is part of the pattern or url
 // /x => {str: x, wild: false}
 // /{x} => {str: x, wild true}
+// /{x...} => {str: x, wild: true, multi: true}
 type segment struct {
-	str  string
-	wild bool
+	str   string
+	wild  bool
+	multi bool
 }
 
 // parsePattern is creating pattern for given method and url
@@ -36,7 +42,7 @@ func parsePattern(method string, url string) (*pattern, error) {
 	segmentsStr := strings.Split(fixUrl, "/")
 
 	var segments []segment
-	for _, seg := range segmentsStr {
+	for i, seg := range segmentsStr {
 		seg = strings.TrimFunc(seg, func(c rune) bool {
 			return c == ' '
 		})
@@ -51,12 +57,20 @@ func parsePattern(method string, url string) (*pattern, error) {
 			str := strings.TrimFunc(seg, func(c rune) bool {
 				return c == '{' || c == '}'
 			})
+
+			multi := strings.HasSuffix(str, "...")
+			if multi && i != len(segmentsStr)-1 {
+				return nil, errCatchAllNotLast
+			}
+			str = strings.TrimSuffix(str, "...")
+
 			if len(str) == 0 {
 				str = "$"
 			}
 			segments = append(segments, segment{
-				str:  str,
-				wild: true,
+				str:   str,
+				wild:  true,
+				multi: multi,
 			})
 		default:
 			segments = append(segments, segment{
@@ -81,6 +95,7 @@ type routingNode struct {
 	// special children keys:
 	//    "/" - empty string pattern
 	//    "*" - for wild segment
+	//    "..." - for catch-all segment
 	children map[string]*routingNode
 }
 
@@ -98,9 +113,12 @@ func (n *routingNode) addSegments(segs []segment, p *pattern, h HandlerFunc) {
 	}
 
 	seg := segs[0]
-	if seg.wild {
+	switch {
+	case seg.multi:
+		n.addChild("...").addSegments(segs[1:], p, h)
+	case seg.wild:
 		n.addChild("*").addSegments(segs[1:], p, h)
-	} else {
+	default:
 		n.addChild(seg.str).addSegments(segs[1:], p, h)
 	}
 }
@@ -136,17 +154,35 @@ func (n *routingNode) set(p *pattern, h HandlerFunc) {
 }
 
 // match matches params and handler function for a method and url
+// Precedence of segments is defined for each segment from left to right:
+// literal segment, then wild segment, then catch-all segment, if the rest
+// of the url doesn't match with chosen segment, next one is tried.
+// Catch-all segment matches one or more the rest segments of the url
 func (root *routingNode) match(method, url string) (*routingNode, []string) {
-	if root == nil {
+	if root == nil || len(method) == 0 {
 		return nil, nil
 	}
 
-	p, err := parsePattern(method, url)
-	if err != nil {
-		return nil, nil
+	return root.findChild(method).matchPath(parseUrl(url), nil)
+}
+
+// allowedMethods returns sorted methods that have pattern for the url
+func (root *routingNode) allowedMethods(url string) []string {
+	if root == nil {
+		return nil
 	}
 
-	return root.findChild(p.method).matchPath(p.segments, nil)
+	segments := parseUrl(url)
+
+	var methods []string
+	for method, n := range root.children {
+		if l, _ := n.matchPath(segments, nil); l != nil {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods)
+
+	return methods
 }
 
 // matchPath mathes params and handler for a segments of pattern
@@ -169,12 +205,50 @@ func (n *routingNode) matchPath(segments []segment, matches []string) (*routingN
 
 	// check for wild key
 	if c := n.findChild("*"); c != nil {
-		matches = append(matches, seg.str)
-		if l, m := c.matchPath(segments[1:], matches); l != nil {
+		if l, m := c.matchPath(segments[1:], append(matches, seg.str)); l != nil {
 			return l, m
 		}
+	}
 
+	// check for catch-all key
+	if c := n.findChild("..."); c != nil && c.pattern != nil {
+		return c, append(matches, joinSegments(segments))
 	}
 
 	return nil, nil
 }
+
+// parseUrl splits url without query to segments, segments are not wild
+func parseUrl(url string) []segment {
+	url, _, _ = strings.Cut(url, "?")
+	url = strings.TrimFunc(url, func(c rune) bool {
+		return c == ' ' || c == '/'
+	})
+	if len(url) == 0 {
+		return []segment{{str: "/"}}
+	}
+
+	var segments []segment
+	for _, seg := range strings.Split(url, "/") {
+		if len(seg) == 0 {
+			seg = "/"
+		}
+		segments = append(segments, segment{str: seg})
+	}
+
+	return segments
+}
+
+// joinSegments joins segments matched by catch-all segment
+func joinSegments(segments []segment) string {
+	strs := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		if seg.str == "/" {
+			strs = append(strs, "")
+			continue
+		}
+		strs = append(strs, seg.str)
+	}
+
+	return strings.Join(strs, "/")
+}
@@ -176,4 +176,94 @@ func Test_addPattern_match(t *testing.T) {
 
 		root.addPattern(patterns[0], nil)
 	})
+
+	t.Run("check allowed methods", func(t *testing.T) {
+		assert.Equal(
+			t,
+			[]string{"GET"},
+			root.allowedMethods("/user/hello"),
+			"should be allowed methods",
+		)
+		assert.Equal(
+			t,
+			[]string(nil),
+			root.allowedMethods("/unknown"),
+			"should not be allowed methods",
+		)
+	})
+}
+
+func Test_parsePatternCatchAll(t *testing.T) {
+	t.Run("check catch-all segment", func(t *testing.T) {
+		p, err := parsePattern("GET", "/files/{path...}")
+		if assert.Equal(t, nil, err, "should not be error parsing pattern") {
+			assert.Equal(
+				t,
+				[]segment{{str: "files"}, {str: "path", wild: true, multi: true}},
+				p.segments,
+				"should be equal segments",
+			)
+		}
+	})
+
+	t.Run("check catch-all segment is not last", func(t *testing.T) {
+		_, err := parsePattern("GET", "/files/{path...}/info")
+		assert.Equal(t, errCatchAllNotLast, err, "should be catch-all not last error")
+	})
+}
+
+func Test_matchPrecedence(t *testing.T) {
+	urls := []string{
+		"/files/{path...}",
+		"/files/{id}",
+		"/files/readme",
+		"/files/{id}/info",
+		"/",
+	}
+
+	root := &routingNode{
+		children: map[string]*routingNode{},
+	}
+
+	patterns := map[string]*pattern{}
+	for _, url := range urls {
+		p, err := parsePattern("GET", url)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		patterns[url] = p
+		root.addPattern(p, nil)
+	}
+
+	tests := []struct {
+		url     string
+		pattern string
+		matches []string
+	}{
+		{url: "/files/readme", pattern: "/files/readme", matches: nil},
+		{url: "/files/123", pattern: "/files/{id}", matches: []string{"123"}},
+		{url: "/files/123/info", pattern: "/files/{id}/info", matches: []string{"123"}},
+		{url: "/files/readme/info", pattern: "/files/{id}/info", matches: []string{"readme"}},
+		{url: "/files/a/b/c", pattern: "/files/{path...}", matches: []string{"a/b/c"}},
+		{url: "/files/123/info/more", pattern: "/files/{path...}", matches: []string{"123/info/more"}},
+		{url: "/files/123?limit=50", pattern: "/files/{id}", matches: []string{"123"}},
+		{url: "/", pattern: "/", matches: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run("check GET "+tt.url, func(t *testing.T) {
+			n, m := root.match("GET", tt.url)
+			if !assert.NotNil(t, n, "should match pattern") {
+				return
+			}
+			assert.Equal(t, patterns[tt.pattern], n.pattern, "should be equal patterns")
+			assert.Equal(t, tt.matches, m, "should be equal matches")
+		})
+	}
+
+	t.Run("check catch-all doesn't match empty rest", func(t *testing.T) {
+		n, _ := root.match("GET", "/files")
+		assert.Nil(t, n, "should not match pattern")
+	})
 }
@@ -1,14 +1,21 @@
 package tcpws
 
 import (
+	"context"
+	"crypto/tls"
 	"errors"
 	"log"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	gotcpws "github.com/sazonovItas/go-tcpws"
 )
 
+// ErrServerClosed is returned by ListenAndServe after Shutdown call
+var ErrServerClosed = errors.New("tcpws: server closed")
+
 type HandlerFunc func(resp *Response, req *Request)
 
 func (hf HandlerFunc) Serve(resp *Response, req *Request) {
@@ -16,26 +23,27 @@ func (hf HandlerFunc) Serve(resp *Response, req *Request) {
 }
 
 // HandleFunc is interface for handle gotcpws connection
+// ctx is done when server is shutting down
 type HandleFunc interface {
-	Serve(conn *gotcpws.Conn)
+	Serve(ctx context.Context, conn *gotcpws.Conn)
 }
 
 // ListenAndServe creates new server
 func ListenAndServe(addr string, handler HandleFunc) error {
-	server := &Server{
-		Addr:    addr,
-		Handler: handler,
-		connwg:  &sync.WaitGroup{},
-	}
-	return server.ListenAndServe()
+	return NewServer(addr, handler).ListenAndServe()
 }
 
 // NewServer creates new server with handler
 func NewServer(addr string, handler HandleFunc) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &Server{
 		Addr:    addr,
 		Handler: handler,
 		connwg:  &sync.WaitGroup{},
+		conns:   map[*gotcpws.Conn]struct{}{},
+		ctx:     ctx,
+		cancel:  cancel,
 	}
 }
 
@@ -44,34 +52,104 @@ type Server struct {
 	Addr    string
 	Handler HandleFunc
 
+	// TLSConfig optionally provides tls configuration for ListenAndServeTLS
+	// e.g. client certificates verification
+	TLSConfig *tls.Config
+
 	// ln is listener for addr
 	ln net.Listener
 
 	// connwg wait group for waiting until all serving connections are done
 	connwg *sync.WaitGroup
+
+	// mu guards ln and conns
+	mu sync.Mutex
+
+	// conns specifies serving connections
+	conns map[*gotcpws.Conn]struct{}
+
+	// ctx is base context for serving connections, it is canceled on shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	inShutdown atomic.Bool
 }
 
 // ListenAndServe create listener on server addr, accepting
 // connections and serve connection in goroutine
-// TODO: remove log from the accpeting connection
+// After Shutdown returns ErrServerClosed
 func (srv *Server) ListenAndServe() error {
+	if srv.inShutdown.Load() {
+		return ErrServerClosed
+	}
+
 	// create new listener on addr
 	listener, err := net.Listen("tcp", srv.Addr)
 	if err != nil {
 		return err
 	}
+
+	return srv.serve(listener)
+}
+
+// ListenAndServeTLS acts like ListenAndServe, but accepts tls connections,
+// certificate and key are reloaded from files when they are changed,
+// if certFile and keyFile are empty, uses certificates from server TLSConfig
+func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	if srv.inShutdown.Load() {
+		return ErrServerClosed
+	}
+
+	var config *tls.Config
+	if srv.TLSConfig != nil {
+		config = srv.TLSConfig.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+
+	if certFile != "" || keyFile != "" {
+		reloader, err := newCertReloader(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		config.GetCertificate = reloader.GetCertificate
+	}
+
+	// create new listener on addr
+	listener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+
+	return srv.serve(tls.NewListener(listener, config))
+}
+
+// serve accepts connections on listener and serve connection in goroutine
+// TODO: remove log from the accpeting connection
+func (srv *Server) serve(listener net.Listener) error {
 	defer func() {
 		srv.connwg.Wait()
 
 		listener.Close()
 	}()
 
+	srv.mu.Lock()
 	srv.ln = listener
+	srv.mu.Unlock()
+
+	// shutdown could be called before listener is set
+	if srv.inShutdown.Load() {
+		return ErrServerClosed
+	}
+
 	for {
 		// Accept new connection if accuse error then check error
 		// if it isn't ErrClosed continue accepting other connections
-		c, err := srv.ln.Accept()
+		c, err := listener.Accept()
 		if err != nil {
+			if srv.inShutdown.Load() {
+				return ErrServerClosed
+			}
 			if errors.Is(err, net.ErrClosed) {
 				return err
 			}
@@ -84,15 +162,15 @@ func (srv *Server) ListenAndServe() error {
 		log.Println("accepted new connection:", conn.RemoteAddr())
 
 		// Serve connection
+		srv.trackConn(conn, true)
 		go func() {
 			defer func() {
-				srv.connwg.Done()
 				conn.Close()
+				srv.trackConn(conn, false)
 
 				log.Println("closed connection:", conn.RemoteAddr())
 			}()
 
-			srv.connwg.Add(1)
 			srv.Serve(conn)
 		}()
 	}
@@ -105,5 +183,76 @@ func (srv *Server) Serve(conn *gotcpws.Conn) {
 		panic("server handler is not set")
 	}
 
-	srv.Handler.Serve(conn)
+	srv.Handler.Serve(srv.ctx, conn)
+}
+
+// Shutdown gracefully shuts down the server, it stops accepting connections,
+// cancels context of serving connections, so ws handlers can send close event,
+// interrupts reading of idle connections and waits for handlers are done.
+// If ctx is done before, closes all connections and returns ctx error
+func (srv *Server) Shutdown(ctx context.Context) error {
+	srv.inShutdown.Store(true)
+
+	srv.mu.Lock()
+	var err error
+	if srv.ln != nil {
+		err = srv.ln.Close()
+	}
+	srv.mu.Unlock()
+
+	srv.cancel()
+	srv.interruptReads()
+
+	done := make(chan struct{})
+	go func() {
+		srv.connwg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		srv.closeConns()
+		return ctx.Err()
+	}
+}
+
+// trackConn adds or removes serving connection
+func (srv *Server) trackConn(conn *gotcpws.Conn, add bool) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if add {
+		srv.conns[conn] = struct{}{}
+		srv.connwg.Add(1)
+	} else {
+		delete(srv.conns, conn)
+		srv.connwg.Done()
+	}
+}
+
+// interruptReads unblocks reading of serving connections,
+// writing to connections is still available for handlers
+func (srv *Server) interruptReads() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	for conn := range srv.conns {
+		_ = conn.SetReadDeadline(time.Now())
+	}
+}
+
+// closeConns forces serving connections to stop by expiring
+// read and write deadlines, connections are closed after handlers return
+func (srv *Server) closeConns() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	for conn := range srv.conns {
+		_ = conn.SetDeadline(time.Now())
+	}
 }
@@ -0,0 +1,144 @@
+package tcpws
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	gotcpws "github.com/sazonovItas/go-tcpws"
+	"github.com/stretchr/testify/assert"
+)
+
+// startTestServer starts server with mux on free address and returns it's address
+func startTestServer(t *testing.T, mux *MuxHandler) (*Server, string, <-chan error) {
+	t.Helper()
+
+	return startTestServerWith(t, mux, nil, (*Server).ListenAndServe)
+}
+
+// startTestServerWith starts server with tls config and listen function
+func startTestServerWith(
+	t *testing.T,
+	mux *MuxHandler,
+	tlsConfig *tls.Config,
+	listen func(srv *Server) error,
+) (*Server, string, <-chan error) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	srv := NewServer(addr, mux)
+	srv.TLSConfig = tlsConfig
+	errch := make(chan error, 1)
+	go func() {
+		errch <- listen(srv)
+	}()
+
+	// wait for server is listening
+	for i := 0; i < 100; i++ {
+		c, err := net.Dial("tcp", addr)
+		if err == nil {
+			c.Close()
+			return srv, addr, errch
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	t.Fatal("server is not listening")
+	return nil, "", nil
+}
+
+// dialTestServer creates client connection to the server
+func dialTestServer(t *testing.T, addr string) *gotcpws.Conn {
+	t.Helper()
+
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := gotcpws.NewFrameConnection(c, nil, nil, 0, true)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	mux := NewMuxHandler()
+
+	started := make(chan struct{})
+	mux.HandleFunc(ProtoWS, "/chat", func(resp *Response, req *Request) {
+		close(started)
+
+		<-req.Ctx().Done()
+		_, _ = resp.Conn.Write([]byte("closing"))
+	})
+
+	srv, addr, errch := startTestServer(t, mux)
+
+	client := dialTestServer(t, addr)
+	writeTestWSRequest(t, client, "/chat")
+	<-started
+
+	t.Run("check shutdown waits for ws handler", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		err := srv.Shutdown(ctx)
+		assert.Equal(t, nil, err, "should not be error to shutdown server")
+	})
+
+	t.Run("check ws session receives close event", func(t *testing.T) {
+		frame, err := client.ReadFrame()
+		if assert.Equal(t, nil, err, "should not be error to read frame") {
+			assert.Equal(t, "closing", string(frame), "should be close event")
+		}
+	})
+
+	t.Run("check listen and serve returns server closed", func(t *testing.T) {
+		assert.Equal(t, ErrServerClosed, <-errch, "should be server closed error")
+	})
+
+	t.Run("check new connections are not accepted", func(t *testing.T) {
+		_, err := net.Dial("tcp", addr)
+		assert.Error(t, err, "should be error to connect after shutdown")
+	})
+}
+
+func TestServer_ShutdownDeadline(t *testing.T) {
+	mux := NewMuxHandler()
+
+	started := make(chan struct{})
+	mux.HandleFunc("GET", "/slow", func(resp *Response, req *Request) {
+		close(started)
+		time.Sleep(time.Millisecond * 300)
+	})
+
+	srv, addr, _ := startTestServer(t, mux)
+
+	client := dialTestServer(t, addr)
+	writeTestRequest(t, client, "GET", "/slow")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "should be deadline exceeded error")
+}
+
+// writeTestWSRequest writes ws request to the connection
+func writeTestWSRequest(t *testing.T, conn *gotcpws.Conn, url string) {
+	t.Helper()
+
+	if _, err := conn.Write([]byte(`{"method":"ws","proto":"ws","url":"` + url + `"}`)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,111 @@
+package tcpws
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrNoClientCAs = errors.New("no client certificates in file")
+
+// certReloader loads certificate and key from files and reloads
+// them when files are changed, so certificate could be rotated
+// without restarting the server
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTimes [2]time.Time
+}
+
+// newCertReloader creates cert reloader and loads certificate
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	cr := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	if err := cr.reload(); err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+// GetCertificate returns actual certificate, it is used in tls config,
+// if files were changed but can't be loaded, returns last loaded certificate
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cr.changed() {
+		_ = cr.reload()
+	}
+
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+
+	return cr.cert, nil
+}
+
+// changed checks modification time of certificate and key files
+func (cr *certReloader) changed() bool {
+	modTimes, err := cr.fileModTimes()
+	if err != nil {
+		return false
+	}
+
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+
+	return modTimes != cr.modTimes
+}
+
+// reload loads certificate and key from files
+func (cr *certReloader) reload() error {
+	modTimes, err := cr.fileModTimes()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cr.cert, cr.modTimes = &cert, modTimes
+	return nil
+}
+
+func (cr *certReloader) fileModTimes() (modTimes [2]time.Time, err error) {
+	for i, file := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+// LoadClientCAs loads pool of certificates from pem file to verify client certificates
+func LoadClientCAs(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("tcpws: read client ca file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, ErrNoClientCAs
+	}
+
+	return pool, nil
+}
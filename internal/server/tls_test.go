@@ -0,0 +1,230 @@
+package tcpws
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gotcpws "github.com/sazonovItas/go-tcpws"
+	"github.com/stretchr/testify/assert"
+)
+
+// testCert is generated at test time certificate signed by parent or self-signed
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth,
+		},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+
+	signCert, signKey := tmpl, key
+	if parent != nil {
+		signCert, signKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signCert, &key.PublicKey, signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// writeFiles writes certificate and key to the pem files
+func (tc *testCert) writeFiles(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+
+	keyDer, err := x509.MarshalECPrivateKey(tc.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tc.der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := os.WriteFile(certFile, certPem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tlsCertificate returns certificate for tls config
+func (tc *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{tc.der}, PrivateKey: tc.key}
+}
+
+// dialTestServerTLS creates client tls connection to the server
+func dialTestServerTLS(t *testing.T, addr string, config *tls.Config) (*gotcpws.Conn, error) {
+	t.Helper()
+
+	c, err := tls.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := gotcpws.NewFrameConnection(c, nil, nil, 0, true)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn, nil
+}
+
+func TestServer_ListenAndServeTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	serverCert := newTestCert(t, "gochat", nil, true)
+	serverCert.writeFiles(t, certFile, keyFile)
+
+	mux := NewMuxHandler()
+	mux.HandleFunc("GET", "/ping", func(resp *Response, req *Request) {
+		resp.StatusCode = http.StatusOK
+	})
+
+	_, addr, _ := startTestServerWith(t, mux, nil, func(srv *Server) error {
+		return srv.ListenAndServeTLS(certFile, keyFile)
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCert.cert)
+
+	t.Run("check request over tls", func(t *testing.T) {
+		client, err := dialTestServerTLS(t, addr, &tls.Config{RootCAs: roots})
+		if !assert.Equal(t, nil, err, "should not be error to dial tls server") {
+			return
+		}
+
+		writeTestRequest(t, client, "GET", "/ping")
+		resp := readTestResponse(t, client)
+		assert.Equal(t, float64(http.StatusOK), resp["status_code"], "should be ok status")
+	})
+
+	t.Run("check certificate hot reload", func(t *testing.T) {
+		newCert := newTestCert(t, "gochat-rotated", nil, true)
+		newCert.writeFiles(t, certFile, keyFile)
+
+		// modification time could be the same on coarse file systems
+		future := time.Now().Add(time.Minute)
+		_ = os.Chtimes(certFile, future, future)
+
+		newRoots := x509.NewCertPool()
+		newRoots.AddCert(newCert.cert)
+
+		client, err := dialTestServerTLS(t, addr, &tls.Config{RootCAs: newRoots})
+		if !assert.Equal(t, nil, err, "should not be error to dial with rotated certificate") {
+			return
+		}
+
+		writeTestRequest(t, client, "GET", "/ping")
+		resp := readTestResponse(t, client)
+		assert.Equal(t, float64(http.StatusOK), resp["status_code"], "should be ok status")
+	})
+}
+
+func TestServer_ListenAndServeMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	caFile := filepath.Join(dir, "ca.pem")
+
+	ca := newTestCert(t, "gochat-ca", nil, true)
+	ca.writeFiles(t, caFile, filepath.Join(dir, "ca-key.pem"))
+	newTestCert(t, "gochat", ca, false).writeFiles(t, certFile, keyFile)
+
+	clientCAs, err := LoadClientCAs(caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := NewMuxHandler()
+	mux.HandleFunc("GET", "/ping", func(resp *Response, req *Request) {
+		resp.StatusCode = http.StatusOK
+	})
+
+	_, addr, _ := startTestServerWith(
+		t,
+		mux,
+		&tls.Config{ClientCAs: clientCAs, ClientAuth: tls.RequireAndVerifyClientCert},
+		func(srv *Server) error {
+			return srv.ListenAndServeTLS(certFile, keyFile)
+		},
+	)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	t.Run("check client with certificate", func(t *testing.T) {
+		client, err := dialTestServerTLS(t, addr, &tls.Config{
+			RootCAs:      roots,
+			Certificates: []tls.Certificate{newTestCert(t, "client", ca, false).tlsCertificate()},
+		})
+		if !assert.Equal(t, nil, err, "should not be error to dial tls server") {
+			return
+		}
+
+		writeTestRequest(t, client, "GET", "/ping")
+		resp := readTestResponse(t, client)
+		assert.Equal(t, float64(http.StatusOK), resp["status_code"], "should be ok status")
+	})
+
+	t.Run("check client without certificate", func(t *testing.T) {
+		client, err := dialTestServerTLS(t, addr, &tls.Config{RootCAs: roots})
+		if err != nil {
+			return
+		}
+
+		// tls 1.3 reports client certificate error on first read
+		writeTestRequest(t, client, "GET", "/ping")
+		_, err = client.ReadFrame()
+		assert.Error(t, err, "should be error without client certificate")
+	})
+
+	t.Run("check load client cas from empty file", func(t *testing.T) {
+		emptyFile := filepath.Join(dir, "empty.pem")
+		_ = os.WriteFile(emptyFile, nil, 0o600)
+
+		_, err := LoadClientCAs(emptyFile)
+		assert.Equal(t, ErrNoClientCAs, err, "should be no client cas error")
+	})
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS chat.snapshots;
+DROP TABLE IF EXISTS chat.events;
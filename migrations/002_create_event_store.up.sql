@@ -0,0 +1,23 @@
+SET SEARCH_PATH TO chat;
+
+CREATE TABLE IF NOT EXISTS events (
+  stream_id         text          NOT NULL,
+  stream_name       text          NOT NULL,
+  stream_version    int           NOT NULL,
+  event_id          text          NOT NULL,
+  event_name        text          NOT NULL,
+  event_data        bytea         NOT NULL,
+  metadata          bytea,
+  occured_at        timestamptz   NOT NULL  DEFAULT NOW(),
+  PRIMARY KEY (stream_id, stream_name, stream_version)
+);
+
+CREATE TABLE IF NOT EXISTS snapshots (
+  stream_id         text          NOT NULL,
+  stream_name       text          NOT NULL,
+  stream_version    int           NOT NULL,
+  snapshot_name     text          NOT NULL,
+  snapshot_data     bytea         NOT NULL,
+  updated_at        timestamptz   NOT NULL  DEFAULT NOW(),
+  PRIMARY KEY (stream_id, stream_name)
+);
@@ -0,0 +1,9 @@
+SET SEARCH_PATH TO chat;
+
+DROP TABLE IF EXISTS last_messages;
+DROP TABLE IF EXISTS unread_counters;
+DROP TABLE IF EXISTS dead_letters;
+DROP TABLE IF EXISTS checkpoints;
+
+DROP INDEX IF EXISTS events_position_idx;
+ALTER TABLE events DROP COLUMN IF EXISTS position;
@@ -0,0 +1,42 @@
+SET SEARCH_PATH TO chat;
+
+-- position orders events of all streams for projections
+ALTER TABLE events ADD COLUMN IF NOT EXISTS position bigserial NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS events_position_idx ON events (position);
+
+CREATE TABLE IF NOT EXISTS checkpoints (
+  projection        text          NOT NULL,
+  position          bigint        NOT NULL,
+  updated_at        timestamptz   NOT NULL  DEFAULT NOW(),
+  PRIMARY KEY (projection)
+);
+
+CREATE TABLE IF NOT EXISTS dead_letters (
+  projection        text          NOT NULL,
+  position          bigint        NOT NULL,
+  event_id          text          NOT NULL,
+  event_name        text          NOT NULL,
+  error             text          NOT NULL,
+  failed_at         timestamptz   NOT NULL  DEFAULT NOW(),
+  PRIMARY KEY (projection, position)
+);
+
+-- read models of the projections
+CREATE TABLE IF NOT EXISTS unread_counters (
+  conversation_id   bigint        NOT NULL,
+  user_id           bigint        NOT NULL,
+  unread_count      int           NOT NULL  DEFAULT 0,
+  position          bigint        NOT NULL,
+  PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS last_messages (
+  conversation_id   bigint          NOT NULL,
+  id                uuid            NOT NULL,
+  sender_id         bigint          NOT NULL,
+  message_kind      int             NOT NULL,
+  message           VARCHAR(1024)   NOT NULL,
+  created_at        timestamptz     NOT NULL,
+  position          bigint          NOT NULL,
+  PRIMARY KEY (conversation_id)
+);
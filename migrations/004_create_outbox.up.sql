@@ -0,0 +1,13 @@
+SET SEARCH_PATH TO chat;
+
+CREATE TABLE IF NOT EXISTS outbox (
+  id                uuid          NOT NULL,
+  event_type        text          NOT NULL,
+  payload           bytea         NOT NULL,
+  created_at        timestamptz   NOT NULL  DEFAULT NOW(),
+  published_at      timestamptz,
+  PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS outbox_unpublished_idx ON outbox (created_at)
+  WHERE published_at IS NULL;
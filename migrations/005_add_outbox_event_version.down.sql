@@ -0,0 +1 @@
+ALTER TABLE chat.outbox DROP COLUMN IF EXISTS event_version;
@@ -0,0 +1 @@
+ALTER TABLE chat.outbox ADD COLUMN IF NOT EXISTS event_version int NOT NULL DEFAULT 1;
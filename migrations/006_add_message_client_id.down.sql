@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS chat.messages_sender_client_id_idx;
+
+ALTER TABLE chat.messages DROP COLUMN IF EXISTS client_id;
@@ -0,0 +1,4 @@
+ALTER TABLE chat.messages ADD COLUMN IF NOT EXISTS client_id VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS messages_sender_client_id_idx
+  ON chat.messages (sender_id, client_id) WHERE client_id IS NOT NULL;
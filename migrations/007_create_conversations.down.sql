@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS chat.messages_conversation_created_at_idx;
+ALTER TABLE chat.messages DROP COLUMN IF EXISTS conversation_id;
+
+DROP TABLE IF EXISTS chat.conversation_members;
+DROP TABLE IF EXISTS chat.conversations;
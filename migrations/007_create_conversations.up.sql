@@ -0,0 +1,39 @@
+SET SEARCH_PATH TO chat;
+
+CREATE TABLE IF NOT EXISTS conversations (
+  id                  bigserial     NOT NULL,
+  title               VARCHAR(40)   NOT NULL  DEFAULT '',
+  color               VARCHAR(7)    NOT NULL  DEFAULT '',
+  creator_id          bigint,
+  conversation_kind   int           NOT NULL,
+  -- direct_key is ordered pair of user ids of direct conversation,
+  -- so there is only one direct conversation of two users
+  direct_key          text,
+  created_at          timestamptz   NOT NULL  DEFAULT NOW(),
+  PRIMARY KEY (id),
+  FOREIGN KEY (creator_id) REFERENCES users (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS conversations_direct_key_idx ON conversations (direct_key)
+  WHERE direct_key IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS conversation_members (
+  conversation_id   bigint        NOT NULL,
+  user_id           bigint        NOT NULL,
+  joined_at         timestamptz   NOT NULL  DEFAULT NOW(),
+  PRIMARY KEY (conversation_id, user_id),
+  FOREIGN KEY (conversation_id) REFERENCES conversations (id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS conversation_members_user_idx ON conversation_members (user_id);
+
+-- common conversation of all users keeps messages created before conversations
+INSERT INTO conversations (id, title, conversation_kind) VALUES (0, 'Common', 1)
+  ON CONFLICT (id) DO NOTHING;
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS conversation_id bigint NOT NULL DEFAULT 0
+  REFERENCES conversations (id);
+
+CREATE INDEX IF NOT EXISTS messages_conversation_created_at_idx
+  ON messages (conversation_id, created_at);
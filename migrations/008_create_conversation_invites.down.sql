@@ -0,0 +1 @@
+DROP TABLE IF EXISTS chat.conversation_invites;
@@ -0,0 +1,14 @@
+SET SEARCH_PATH TO chat;
+
+CREATE TABLE IF NOT EXISTS conversation_invites (
+  conversation_id   bigint        NOT NULL,
+  user_id           bigint        NOT NULL,
+  inviter_id        bigint        NOT NULL,
+  created_at        timestamptz   NOT NULL  DEFAULT NOW(),
+  PRIMARY KEY (conversation_id, user_id),
+  FOREIGN KEY (conversation_id) REFERENCES conversations (id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users (id),
+  FOREIGN KEY (inviter_id) REFERENCES users (id)
+);
+
+CREATE INDEX IF NOT EXISTS conversation_invites_user_idx ON conversation_invites (user_id);
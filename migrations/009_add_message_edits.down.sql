@@ -0,0 +1,2 @@
+ALTER TABLE chat.messages DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE chat.messages DROP COLUMN IF EXISTS updated_at;
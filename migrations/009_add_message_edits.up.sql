@@ -0,0 +1,8 @@
+-- updated_at is time of the last edit, deleted messages are kept as tombstones
+-- with empty text, so history keeps their place
+ALTER TABLE chat.messages ADD COLUMN IF NOT EXISTS updated_at timestamptz;
+UPDATE chat.messages SET updated_at = created_at WHERE updated_at IS NULL;
+ALTER TABLE chat.messages ALTER COLUMN updated_at SET DEFAULT NOW();
+ALTER TABLE chat.messages ALTER COLUMN updated_at SET NOT NULL;
+
+ALTER TABLE chat.messages ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS chat.messages_thread_created_at_idx;
+
+ALTER TABLE chat.messages DROP COLUMN IF EXISTS last_reply_at;
+ALTER TABLE chat.messages DROP COLUMN IF EXISTS reply_count;
+ALTER TABLE chat.messages DROP COLUMN IF EXISTS thread_id;
+ALTER TABLE chat.messages DROP COLUMN IF EXISTS reply_to;
@@ -0,0 +1,9 @@
+-- reply_to is the message the reply is quoting, thread_id is the root message
+-- of the thread of the reply, roots keep count and time of the last reply
+ALTER TABLE chat.messages ADD COLUMN IF NOT EXISTS reply_to uuid REFERENCES chat.messages (id);
+ALTER TABLE chat.messages ADD COLUMN IF NOT EXISTS thread_id uuid REFERENCES chat.messages (id);
+ALTER TABLE chat.messages ADD COLUMN IF NOT EXISTS reply_count int NOT NULL DEFAULT 0;
+ALTER TABLE chat.messages ADD COLUMN IF NOT EXISTS last_reply_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS messages_thread_created_at_idx
+  ON chat.messages (thread_id, created_at) WHERE thread_id IS NOT NULL;
@@ -0,0 +1,11 @@
+SET SEARCH_PATH TO chat;
+
+CREATE TABLE IF NOT EXISTS message_reactions (
+  message_id        uuid          NOT NULL,
+  user_id           bigint        NOT NULL,
+  emoji             VARCHAR(32)   NOT NULL,
+  created_at        timestamptz   NOT NULL  DEFAULT NOW(),
+  PRIMARY KEY (message_id, user_id, emoji),
+  FOREIGN KEY (message_id) REFERENCES messages (id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1,100 @@
+package es
+
+import (
+	"fmt"
+
+	"github.com/sazonovItas/gochat-tcp/pkg/es/event"
+)
+
+// Metadata keys of the aggregate events
+const (
+	AggregateIDKey      = "aggregate-id"
+	AggregateNameKey    = "aggregate-name"
+	AggregateVersionKey = "aggregate-version"
+)
+
+type (
+	EventApplier interface {
+		// ApplyEvent changes state of the aggregate by the event
+		ApplyEvent(event.Event) error
+	}
+
+	EventSourcedAggregate interface {
+		event.IDer
+		EventApplier
+		AggregateName() string
+
+		// Events returns pending events
+		Events() []event.Event
+
+		// Version returns version of the last committed event
+		Version() int
+
+		// PendingVersion returns version of the last pending event
+		PendingVersion() int
+
+		// CommitEvents clears pending events and sets version to the pending version
+		CommitEvents()
+
+		setVersion(int)
+	}
+
+	// Aggregate is base of event sourced aggregates, it keeps
+	// version of the stream and pending events
+	Aggregate struct {
+		event.Entity
+		events  []event.Event
+		version int
+	}
+)
+
+func NewAggregate(id, name string) Aggregate {
+	return Aggregate{
+		Entity: event.NewEntity(id, name),
+	}
+}
+
+func (a Aggregate) AggregateName() string { return a.EntityName() }
+func (a Aggregate) Events() []event.Event { return a.events }
+func (a Aggregate) Version() int          { return a.version }
+func (a Aggregate) PendingVersion() int   { return a.version + len(a.events) }
+
+// AddEvent adds pending event with aggregate metadata and next version
+func (a *Aggregate) AddEvent(
+	name string,
+	payload event.EventPayload,
+	options ...event.EventOption,
+) {
+	options = append(options, event.Metadata{
+		AggregateIDKey:      a.ID(),
+		AggregateNameKey:    a.AggregateName(),
+		AggregateVersionKey: a.PendingVersion() + 1,
+	})
+
+	a.events = append(a.events, event.NewEvent(name, payload, options...))
+}
+
+func (a *Aggregate) CommitEvents() {
+	a.version = a.PendingVersion()
+	a.events = nil
+}
+
+func (a *Aggregate) setVersion(version int) { a.version = version }
+
+// Replay applies loaded events to the aggregate and sets version
+// of the aggregate to the version of the last event
+func Replay(aggregate EventSourcedAggregate, events ...event.Event) error {
+	for _, e := range events {
+		version, ok := e.Metadata().Get(AggregateVersionKey).(int)
+		if !ok {
+			return fmt.Errorf("event %s has no aggregate version", e.ID())
+		}
+
+		if err := aggregate.ApplyEvent(e); err != nil {
+			return err
+		}
+		aggregate.setVersion(version)
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package es
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sazonovItas/gochat-tcp/pkg/es/event"
+)
+
+const (
+	counterAggregate   = "counter"
+	counterAddedEvent  = "CounterAdded"
+	counterSnapshotV1  = "CounterV1"
+	counterInitialName = "initial"
+)
+
+type counterAdded struct {
+	Delta int `json:"delta"`
+}
+
+type counterSnapshot struct {
+	Value int `json:"value"`
+}
+
+func (counterSnapshot) SnapshotName() string { return counterSnapshotV1 }
+
+// counter is test aggregate that sums added deltas
+type counter struct {
+	Aggregate
+	value   int
+	applied int
+}
+
+func newCounter(id string) *counter {
+	return &counter{Aggregate: NewAggregate(id, counterAggregate)}
+}
+
+func (c *counter) Add(delta int) {
+	c.AddEvent(counterAddedEvent, counterAdded{Delta: delta})
+}
+
+func (c *counter) ApplyEvent(e event.Event) error {
+	payload, ok := e.Payload().(counterAdded)
+	if !ok {
+		return errors.New("unknown event")
+	}
+
+	c.value += payload.Delta
+	c.applied++
+	return nil
+}
+
+func (c *counter) ApplySnapshot(snapshot Snapshot) error {
+	s, ok := snapshot.(counterSnapshot)
+	if !ok {
+		return errors.New("unknown snapshot")
+	}
+
+	c.value = s.Value
+	return nil
+}
+
+func (c *counter) ToSnapshot() Snapshot {
+	return counterSnapshot{Value: c.value}
+}
+
+func TestAggregate_AddEvent(t *testing.T) {
+	c := newCounter("1")
+	c.Add(1)
+	c.Add(2)
+
+	assert.Equal(t, 0, c.Version(), "should be zero version")
+	assert.Equal(t, 2, c.PendingVersion(), "should be equal pending version")
+
+	metadata := c.Events()[1].Metadata()
+	assert.Equal(t, "1", metadata.Get(AggregateIDKey), "should be aggregate id")
+	assert.Equal(t, counterAggregate, metadata.Get(AggregateNameKey), "should be aggregate name")
+	assert.Equal(t, 2, metadata.Get(AggregateVersionKey), "should be event version")
+
+	c.CommitEvents()
+	assert.Equal(t, 2, c.Version(), "should be committed version")
+	assert.Equal(t, 0, len(c.Events()), "should be no pending events")
+}
+
+func TestAggregateRepository(t *testing.T) {
+	repo := NewAggregateRepository(newCounter, NewMemoryStore())
+
+	t.Run("check load of new aggregate", func(t *testing.T) {
+		c, err := repo.Load(context.Background(), "new")
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 0, c.Version(), "should be zero version")
+	})
+
+	t.Run("check save and replay", func(t *testing.T) {
+		c, _ := repo.Load(context.Background(), "replay")
+		c.Add(1)
+		c.Add(2)
+		if err := repo.Save(context.Background(), c); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, 3, c.value, "should apply events on save")
+
+		loaded, err := repo.Load(context.Background(), "replay")
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 3, loaded.value, "should replay events")
+		assert.Equal(t, 2, loaded.Version(), "should be version of the last event")
+	})
+
+	t.Run("check optimistic concurrency", func(t *testing.T) {
+		first, _ := repo.Load(context.Background(), "concurrent")
+		second, _ := repo.Load(context.Background(), "concurrent")
+
+		first.Add(1)
+		assert.Equal(t, nil, repo.Save(context.Background(), first), "should save first")
+
+		second.Add(2)
+		err := repo.Save(context.Background(), second)
+		assert.ErrorIs(t, err, ErrVersionConflict, "should be version conflict")
+		assert.Equal(t, 1, len(second.Events()), "should keep pending events")
+		assert.Equal(t, 0, second.value, "should not apply pending events")
+	})
+}
+
+// failingStore is aggregate store which saving always fails
+type failingStore struct {
+	AggregateStore
+}
+
+var errTestSave = errors.New("save failed")
+
+func (failingStore) Save(_ context.Context, _ EventSourcedAggregate) error {
+	return errTestSave
+}
+
+func TestAggregateRepository_SaveFailure(t *testing.T) {
+	repo := NewAggregateRepository(newCounter, failingStore{AggregateStore: NewMemoryStore()})
+
+	c, _ := repo.Load(context.Background(), "failure")
+	c.Add(1)
+
+	err := repo.Save(context.Background(), c)
+	assert.ErrorIs(t, err, errTestSave, "should be error of the store")
+	assert.Equal(t, 0, c.value, "should not apply events")
+	assert.Equal(t, 0, c.Version(), "should not change version")
+	assert.Equal(t, 1, len(c.Events()), "should keep pending events")
+}
+
+func TestSnapshotAggregateStore(t *testing.T) {
+	snapshots := NewMemorySnapshotStore()
+	repo := NewAggregateRepository(
+		newCounter,
+		NewSnapshotAggregateStore(NewMemoryStore(), snapshots, 3),
+	)
+
+	c, _ := repo.Load(context.Background(), counterInitialName)
+	for i := 1; i <= 4; i++ {
+		c.Add(i)
+		if err := repo.Save(context.Background(), c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("check snapshot is saved by frequency", func(t *testing.T) {
+		snapshot, version, err := snapshots.LoadSnapshot(
+			context.Background(),
+			counterAggregate,
+			counterInitialName,
+		)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 3, version, "should be snapshot of the third version")
+		assert.Equal(t, counterSnapshot{Value: 6}, snapshot, "should be equal snapshots")
+	})
+
+	t.Run("check load from snapshot", func(t *testing.T) {
+		loaded, err := repo.Load(context.Background(), counterInitialName)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 10, loaded.value, "should be equal values")
+		assert.Equal(t, 4, loaded.Version(), "should be version of the last event")
+		assert.Equal(t, 1, loaded.applied, "should replay only events after snapshot")
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	Register[counterAdded](registry, counterAddedEvent)
+
+	t.Run("check round trip", func(t *testing.T) {
+		data, err := registry.Serialize(counterAddedEvent, counterAdded{Delta: 5})
+		assert.Equal(t, nil, err, "should not be error")
+
+		v, err := registry.Deserialize(counterAddedEvent, data)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, counterAdded{Delta: 5}, v, "should be typed value")
+	})
+
+	t.Run("check unknown type", func(t *testing.T) {
+		_, err := registry.Deserialize("Unknown", []byte(`{}`))
+		assert.ErrorIs(t, err, ErrUnknownType, "should be unknown type")
+	})
+}
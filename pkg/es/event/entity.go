@@ -13,6 +13,10 @@ type Entity struct {
 	name string
 }
 
+func NewEntity(id, name string) Entity {
+	return Entity{id: id, name: name}
+}
+
 func (e Entity) ID() string         { return e.id }
 func (e Entity) EntityName() string { return e.name }
 func (e Entity) Equals(o IDer) bool { return e.id == o.ID() }
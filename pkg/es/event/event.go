@@ -1,6 +1,10 @@
 package event
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type (
 	EventPayload interface{}
@@ -13,6 +17,11 @@ type (
 		OccuredAt() time.Time
 	}
 
+	// EventOption configures event on creating
+	EventOption interface {
+		configureEvent(*event)
+	}
+
 	event struct {
 		Entity
 		payload   EventPayload
@@ -21,7 +30,25 @@ type (
 	}
 )
 
-func (e *event) EventName() string          { return e.name }
-func (e *event) EventPayload() EventPayload { return e.payload }
-func (e *event) EventMetadata() Metadata    { return e.metadata }
-func (e *event) OccuredAt() time.Time       { return e.occuredAt }
+var _ Event = (*event)(nil)
+
+// NewEvent creates event with a new id that occured now
+func NewEvent(name string, payload EventPayload, options ...EventOption) Event {
+	evt := &event{
+		Entity:    NewEntity(uuid.New().String(), name),
+		payload:   payload,
+		metadata:  make(Metadata),
+		occuredAt: time.Now(),
+	}
+
+	for _, option := range options {
+		option.configureEvent(evt)
+	}
+
+	return evt
+}
+
+func (e *event) EventName() string     { return e.name }
+func (e *event) Payload() EventPayload { return e.payload }
+func (e *event) Metadata() Metadata    { return e.metadata }
+func (e *event) OccuredAt() time.Time  { return e.occuredAt }
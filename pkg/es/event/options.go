@@ -0,0 +1,19 @@
+package event
+
+import "time"
+
+type (
+	// WithID sets id of the event e.g. if event is loaded from store
+	WithID string
+
+	// WithOccuredAt sets time of the event e.g. if event is loaded from store
+	WithOccuredAt time.Time
+)
+
+func (id WithID) configureEvent(e *event) {
+	e.setID(string(id))
+}
+
+func (t WithOccuredAt) configureEvent(e *event) {
+	e.occuredAt = time.Time(t)
+}
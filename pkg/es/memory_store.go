@@ -0,0 +1,181 @@
+package es
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sazonovItas/gochat-tcp/pkg/es/event"
+)
+
+type streamKey struct {
+	name string
+	id   string
+}
+
+// memoryStore represents in-memory event store and implements EventStore interface
+type memoryStore struct {
+	mu      sync.RWMutex
+	streams map[streamKey][]event.Event
+	all     []event.Event
+}
+
+// NewMemoryStore creates in-memory event store e.g. for tests
+func NewMemoryStore() EventStore {
+	return &memoryStore{
+		streams: make(map[streamKey][]event.Event),
+	}
+}
+
+// Load is implementing interface AggregateStore
+func (ms *memoryStore) Load(_ context.Context, aggregate EventSourcedAggregate) error {
+	ms.mu.RLock()
+	stream := ms.streams[streamKey{name: aggregate.AggregateName(), id: aggregate.ID()}]
+	var events []event.Event
+	if aggregate.Version() < len(stream) {
+		events = append(events, stream[aggregate.Version():]...)
+	}
+	ms.mu.RUnlock()
+
+	return Replay(aggregate, events...)
+}
+
+// Save is implementing interface AggregateStore
+func (ms *memoryStore) Save(_ context.Context, aggregate EventSourcedAggregate) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	key := streamKey{name: aggregate.AggregateName(), id: aggregate.ID()}
+	if len(ms.streams[key]) != aggregate.Version() {
+		return ErrVersionConflict
+	}
+
+	ms.streams[key] = append(ms.streams[key], aggregate.Events()...)
+	ms.all = append(ms.all, aggregate.Events()...)
+	return nil
+}
+
+// ReadEvents is implementing interface EventReader, position of the event is
+// its index in the store plus one
+func (ms *memoryStore) ReadEvents(
+	_ context.Context,
+	after int64,
+	limit int,
+) ([]RecordedEvent, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var events []RecordedEvent
+	for i := max(after, 0); i < int64(len(ms.all)) && len(events) < limit; i++ {
+		events = append(events, RecordedEvent{Event: ms.all[i], Position: i + 1})
+	}
+
+	return events, nil
+}
+
+type memorySnapshot struct {
+	version  int
+	snapshot Snapshot
+}
+
+// memorySnapshotStore represents in-memory snapshot store and implements SnapshotStore interface
+type memorySnapshotStore struct {
+	mu        sync.RWMutex
+	snapshots map[streamKey]memorySnapshot
+}
+
+// NewMemorySnapshotStore creates in-memory snapshot store e.g. for tests
+func NewMemorySnapshotStore() SnapshotStore {
+	return &memorySnapshotStore{
+		snapshots: make(map[streamKey]memorySnapshot),
+	}
+}
+
+// LoadSnapshot is implementing interface SnapshotStore
+func (ms *memorySnapshotStore) LoadSnapshot(
+	_ context.Context,
+	aggregateName, aggregateID string,
+) (Snapshot, int, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	s, ok := ms.snapshots[streamKey{name: aggregateName, id: aggregateID}]
+	if !ok {
+		return nil, 0, ErrSnapshotNotFound
+	}
+
+	return s.snapshot, s.version, nil
+}
+
+// SaveSnapshot is implementing interface SnapshotStore
+func (ms *memorySnapshotStore) SaveSnapshot(
+	_ context.Context,
+	aggregateName, aggregateID string,
+	version int,
+	snapshot Snapshot,
+) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.snapshots[streamKey{name: aggregateName, id: aggregateID}] = memorySnapshot{
+		version:  version,
+		snapshot: snapshot,
+	}
+	return nil
+}
+
+// MemoryCheckpointStore represents in-memory checkpoint and dead letter store
+// and implements CheckpointStore and DeadLetterStore interfaces
+type MemoryCheckpointStore struct {
+	mu          sync.RWMutex
+	checkpoints map[string]int64
+	deadLetters []DeadLetter
+}
+
+// NewMemoryCheckpointStore creates in-memory checkpoint store that also
+// keeps dead letters e.g. for tests
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{
+		checkpoints: make(map[string]int64),
+	}
+}
+
+// LoadCheckpoint is implementing interface CheckpointStore
+func (ms *MemoryCheckpointStore) LoadCheckpoint(
+	_ context.Context,
+	projection string,
+) (int64, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.checkpoints[projection], nil
+}
+
+// SaveCheckpoint is implementing interface CheckpointStore
+func (ms *MemoryCheckpointStore) SaveCheckpoint(
+	_ context.Context,
+	projection string,
+	position int64,
+) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.checkpoints[projection] = position
+	return nil
+}
+
+// SaveDeadLetter is implementing interface DeadLetterStore
+func (ms *MemoryCheckpointStore) SaveDeadLetter(_ context.Context, letter *DeadLetter) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.deadLetters = append(ms.deadLetters, *letter)
+	return nil
+}
+
+// DeadLetters returns saved dead letters
+func (ms *MemoryCheckpointStore) DeadLetters() []DeadLetter {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return append([]DeadLetter(nil), ms.deadLetters...)
+}
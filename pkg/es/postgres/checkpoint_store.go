@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/sazonovItas/gochat-tcp/pkg/es"
+)
+
+// checkpointStore represents postgres store of projection checkpoints and dead letters,
+// it implements es.CheckpointStore and es.DeadLetterStore interfaces
+type checkpointStore struct {
+	db                   *sqlx.DB
+	tableName            string
+	deadLettersTableName string
+}
+
+// CheckpointStore is both checkpoint and dead letter store
+type CheckpointStore interface {
+	es.CheckpointStore
+	es.DeadLetterStore
+}
+
+// NewCheckpointStore creates store of checkpoints in the table e.g. "chat.checkpoints"
+// and dead letters in the table e.g. "chat.dead_letters"
+func NewCheckpointStore(db *sqlx.DB, tableName, deadLettersTableName string) CheckpointStore {
+	return &checkpointStore{
+		db:                   db,
+		tableName:            tableName,
+		deadLettersTableName: deadLettersTableName,
+	}
+}
+
+// LoadCheckpoint is implementing interface es.CheckpointStore
+func (s *checkpointStore) LoadCheckpoint(ctx context.Context, projection string) (int64, error) {
+	const op = "pkg.es.postgres.checkpointStore.LoadCheckpoint"
+
+	var position int64
+	err := s.db.GetContext(
+		ctx,
+		&position,
+		fmt.Sprintf("SELECT position FROM %s WHERE projection = $1", s.tableName),
+		projection,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, nil
+		default:
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return position, nil
+}
+
+// SaveCheckpoint is implementing interface es.CheckpointStore
+func (s *checkpointStore) SaveCheckpoint(
+	ctx context.Context,
+	projection string,
+	position int64,
+) error {
+	const op = "pkg.es.postgres.checkpointStore.SaveCheckpoint"
+
+	_, err := s.db.ExecContext(
+		ctx,
+		fmt.Sprintf(
+			`INSERT INTO %s (projection, position, updated_at)
+			VALUES ($1, $2, NOW())
+			ON CONFLICT (projection)
+			DO UPDATE SET position = EXCLUDED.position, updated_at = EXCLUDED.updated_at`,
+			s.tableName,
+		),
+		projection,
+		position,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// SaveDeadLetter is implementing interface es.DeadLetterStore, event is referenced
+// by its position, so it could be handled again after fix of the projection
+func (s *checkpointStore) SaveDeadLetter(ctx context.Context, letter *es.DeadLetter) error {
+	const op = "pkg.es.postgres.checkpointStore.SaveDeadLetter"
+
+	_, err := s.db.ExecContext(
+		ctx,
+		fmt.Sprintf(
+			`INSERT INTO %s (projection, position, event_id, event_name, error, failed_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (projection, position)
+			DO UPDATE SET error = EXCLUDED.error, failed_at = EXCLUDED.failed_at`,
+			s.deadLettersTableName,
+		),
+		letter.Projection,
+		letter.Event.Position,
+		letter.Event.ID(),
+		letter.Event.EventName(),
+		letter.Error,
+		letter.FailedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/sazonovItas/gochat-tcp/pkg/es"
+	"github.com/sazonovItas/gochat-tcp/pkg/es/event"
+)
+
+// uniqueViolation is postgres error code of unique constraint violation
+const uniqueViolation = "23505"
+
+// eventStore represents append-only postgres event store and implements
+// es.EventStore interface, primary key of the table is
+// (stream_id, stream_name, stream_version), so concurrent appends
+// of the same version to the stream are conflicted
+//
+// Appends to the table are serialized by transaction advisory lock, so events
+// are committed in order of their positions and readers never skip an event
+// which position is taken by uncommitted transaction
+type eventStore struct {
+	db        *sqlx.DB
+	tableName string
+	registry  *es.Registry
+}
+
+type eventRow struct {
+	Position      int64     `db:"position"`
+	StreamID      string    `db:"stream_id"`
+	StreamName    string    `db:"stream_name"`
+	StreamVersion int       `db:"stream_version"`
+	EventID       string    `db:"event_id"`
+	EventName     string    `db:"event_name"`
+	EventData     []byte    `db:"event_data"`
+	Metadata      []byte    `db:"metadata"`
+	OccuredAt     time.Time `db:"occured_at"`
+}
+
+// NewEventStore creates event store in the table e.g. "chat.events",
+// payloads of events are serialized by the registry
+func NewEventStore(db *sqlx.DB, tableName string, registry *es.Registry) es.EventStore {
+	return &eventStore{
+		db:        db,
+		tableName: tableName,
+		registry:  registry,
+	}
+}
+
+// Load is implementing interface es.EventStore
+func (s *eventStore) Load(ctx context.Context, aggregate es.EventSourcedAggregate) error {
+	const op = "pkg.es.postgres.eventStore.Load"
+
+	query := fmt.Sprintf(
+		`SELECT position, stream_id, stream_name, stream_version,
+			event_id, event_name, event_data, metadata, occured_at
+		FROM %s
+		WHERE stream_id = $1 AND stream_name = $2 AND stream_version > $3
+		ORDER BY stream_version ASC`,
+		s.tableName,
+	)
+
+	var rows []eventRow
+	err := s.db.SelectContext(
+		ctx,
+		&rows,
+		query,
+		aggregate.ID(),
+		aggregate.AggregateName(),
+		aggregate.Version(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	events := make([]event.Event, 0, len(rows))
+	for _, row := range rows {
+		e, err := s.event(&row)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		events = append(events, e)
+	}
+
+	return es.Replay(aggregate, events...)
+}
+
+// Save is implementing interface es.EventStore
+func (s *eventStore) Save(ctx context.Context, aggregate es.EventSourcedAggregate) (err error) {
+	const op = "pkg.es.postgres.eventStore.Save"
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", s.tableName)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var version int
+	err = tx.GetContext(
+		ctx,
+		&version,
+		fmt.Sprintf(
+			`SELECT COALESCE(MAX(stream_version), 0)
+			FROM %s
+			WHERE stream_id = $1 AND stream_name = $2`,
+			s.tableName,
+		),
+		aggregate.ID(),
+		aggregate.AggregateName(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if version != aggregate.Version() {
+		return es.ErrVersionConflict
+	}
+
+	query := fmt.Sprintf(
+		`INSERT INTO %s
+		(stream_id, stream_name, stream_version,
+			event_id, event_name, event_data, metadata, occured_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		s.tableName,
+	)
+	for i, e := range aggregate.Events() {
+		data, err := s.registry.Serialize(e.EventName(), e.Payload())
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		metadata, err := json.Marshal(e.Metadata())
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			query,
+			aggregate.ID(),
+			aggregate.AggregateName(),
+			aggregate.Version()+i+1,
+			e.ID(),
+			e.EventName(),
+			data,
+			metadata,
+			e.OccuredAt(),
+		)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+				return es.ErrVersionConflict
+			}
+
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReadEvents is implementing interface es.EventStore
+func (s *eventStore) ReadEvents(
+	ctx context.Context,
+	after int64,
+	limit int,
+) ([]es.RecordedEvent, error) {
+	const op = "pkg.es.postgres.eventStore.ReadEvents"
+
+	query := fmt.Sprintf(
+		`SELECT position, stream_id, stream_name, stream_version,
+			event_id, event_name, event_data, metadata, occured_at
+		FROM %s
+		WHERE position > $1
+		ORDER BY position ASC
+		LIMIT $2`,
+		s.tableName,
+	)
+
+	var rows []eventRow
+	if err := s.db.SelectContext(ctx, &rows, query, after, limit); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	events := make([]es.RecordedEvent, 0, len(rows))
+	for _, row := range rows {
+		e, err := s.event(&row)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		events = append(events, es.RecordedEvent{Event: e, Position: row.Position})
+	}
+
+	return events, nil
+}
+
+// event creates event of the aggregate stream from the row
+func (s *eventStore) event(row *eventRow) (event.Event, error) {
+	payload, err := s.registry.Deserialize(row.EventName, row.EventData)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := make(event.Metadata)
+	if len(row.Metadata) > 0 {
+		if err := json.Unmarshal(row.Metadata, &metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	// numbers of json are float64, so aggregate metadata is taken from columns
+	metadata.Set(es.AggregateIDKey, row.StreamID)
+	metadata.Set(es.AggregateNameKey, row.StreamName)
+	metadata.Set(es.AggregateVersionKey, row.StreamVersion)
+
+	return event.NewEvent(
+		row.EventName,
+		payload,
+		event.WithID(row.EventID),
+		event.WithOccuredAt(row.OccuredAt),
+		metadata,
+	), nil
+}
@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/sazonovItas/gochat-tcp/pkg/es"
+)
+
+// snapshotStore represents postgres snapshot store and implements
+// es.SnapshotStore interface, only the last snapshot of the aggregate is kept
+type snapshotStore struct {
+	db        *sqlx.DB
+	tableName string
+	registry  *es.Registry
+}
+
+type snapshotRow struct {
+	StreamVersion int    `db:"stream_version"`
+	SnapshotName  string `db:"snapshot_name"`
+	SnapshotData  []byte `db:"snapshot_data"`
+}
+
+// NewSnapshotStore creates snapshot store in the table e.g. "chat.snapshots",
+// snapshots are serialized by the registry
+func NewSnapshotStore(db *sqlx.DB, tableName string, registry *es.Registry) es.SnapshotStore {
+	return &snapshotStore{
+		db:        db,
+		tableName: tableName,
+		registry:  registry,
+	}
+}
+
+// LoadSnapshot is implementing interface es.SnapshotStore
+func (s *snapshotStore) LoadSnapshot(
+	ctx context.Context,
+	aggregateName, aggregateID string,
+) (es.Snapshot, int, error) {
+	const op = "pkg.es.postgres.snapshotStore.LoadSnapshot"
+
+	var row snapshotRow
+	err := s.db.GetContext(
+		ctx,
+		&row,
+		fmt.Sprintf(
+			`SELECT stream_version, snapshot_name, snapshot_data
+			FROM %s
+			WHERE stream_id = $1 AND stream_name = $2`,
+			s.tableName,
+		),
+		aggregateID,
+		aggregateName,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, 0, es.ErrSnapshotNotFound
+		}
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	v, err := s.registry.Deserialize(row.SnapshotName, row.SnapshotData)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	snapshot, ok := v.(es.Snapshot)
+	if !ok {
+		return nil, 0, fmt.Errorf("%s: %T is not snapshot", op, v)
+	}
+
+	return snapshot, row.StreamVersion, nil
+}
+
+// SaveSnapshot is implementing interface es.SnapshotStore,
+// snapshot is not replaced by the older one
+func (s *snapshotStore) SaveSnapshot(
+	ctx context.Context,
+	aggregateName, aggregateID string,
+	version int,
+	snapshot es.Snapshot,
+) error {
+	const op = "pkg.es.postgres.snapshotStore.SaveSnapshot"
+
+	data, err := s.registry.Serialize(snapshot.SnapshotName(), snapshot)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.db.ExecContext(
+		ctx,
+		fmt.Sprintf(
+			`INSERT INTO %[1]s
+			(stream_id, stream_name, stream_version, snapshot_name, snapshot_data)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (stream_id, stream_name) DO UPDATE
+			SET stream_version = EXCLUDED.stream_version,
+				snapshot_name = EXCLUDED.snapshot_name,
+				snapshot_data = EXCLUDED.snapshot_data,
+				updated_at = NOW()
+			WHERE %[1]s.stream_version < EXCLUDED.stream_version`,
+			s.tableName,
+		),
+		aggregateID,
+		aggregateName,
+		version,
+		snapshot.SnapshotName(),
+		data,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
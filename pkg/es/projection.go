@@ -0,0 +1,273 @@
+package es
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Defaults of the projector options
+const (
+	DefaultBatchSize    = 100
+	DefaultPollInterval = time.Second
+	DefaultMaxRetries   = 3
+	DefaultRetryBackoff = 100 * time.Millisecond
+)
+
+var ErrUnknownProjection = errors.New("unknown projection")
+
+type (
+	// Projection builds read model from the events of the store,
+	// events are handled at least once, so handling should be idempotent
+	Projection interface {
+		// ProjectionName returns unique name of the projection that is used for checkpoints
+		ProjectionName() string
+
+		// Handle applies event to the read model
+		// Errors: unknown
+		Handle(ctx context.Context, e RecordedEvent) error
+
+		// Reset clears read model before it is rebuilt from zero
+		// Errors: unknown
+		Reset(ctx context.Context) error
+	}
+
+	CheckpointStore interface {
+		// LoadCheckpoint returns position of the last handled event of the projection,
+		// position is 0 if projection has not handled any event
+		// Errors: unknown
+		LoadCheckpoint(ctx context.Context, projection string) (int64, error)
+
+		// SaveCheckpoint saves position of the last handled event of the projection
+		// Errors: unknown
+		SaveCheckpoint(ctx context.Context, projection string, position int64) error
+	}
+
+	// DeadLetter represents event that projection failed to handle after all retries
+	DeadLetter struct {
+		Projection string
+		Event      RecordedEvent
+		Error      string
+		FailedAt   time.Time
+	}
+
+	DeadLetterStore interface {
+		// SaveDeadLetter saves event that projection failed to handle
+		// Errors: unknown
+		SaveDeadLetter(ctx context.Context, letter *DeadLetter) error
+	}
+
+	ProjectorOpts struct {
+		// Events is reader of the event store
+		Events EventReader
+
+		// Checkpoints keeps positions of projections
+		Checkpoints CheckpointStore
+
+		// DeadLetters keeps failed events, if it is nil,
+		// projection stops on the failed event until it is handled
+		DeadLetters DeadLetterStore
+
+		// Logger logs failed events, default is slog.Default()
+		Logger *slog.Logger
+
+		// BatchSize is count of events that are read at once, default is DefaultBatchSize
+		BatchSize int
+
+		// PollInterval is interval of reading new events, default is DefaultPollInterval
+		PollInterval time.Duration
+
+		// MaxRetries is count of retries of the failed event, default is DefaultMaxRetries,
+		// negative count disables retries
+		MaxRetries int
+
+		// RetryBackoff is delay before the first retry that is doubled on every next retry,
+		// default is DefaultRetryBackoff
+		RetryBackoff time.Duration
+	}
+
+	// projectionRunner keeps lock of the projection,
+	// so catching up and rebuilding are not running concurrently
+	projectionRunner struct {
+		mu         sync.Mutex
+		projection Projection
+	}
+
+	// Projector runs projections from their checkpoints
+	Projector struct {
+		opts        ProjectorOpts
+		projections map[string]*projectionRunner
+	}
+)
+
+// NewProjector creates projector of the projections,
+// projections should have unique names
+func NewProjector(opts *ProjectorOpts, projections ...Projection) *Projector {
+	p := &Projector{
+		opts:        *opts,
+		projections: make(map[string]*projectionRunner, len(projections)),
+	}
+
+	if p.opts.Logger == nil {
+		p.opts.Logger = slog.Default()
+	}
+	if p.opts.BatchSize <= 0 {
+		p.opts.BatchSize = DefaultBatchSize
+	}
+	if p.opts.PollInterval <= 0 {
+		p.opts.PollInterval = DefaultPollInterval
+	}
+	if p.opts.MaxRetries == 0 {
+		p.opts.MaxRetries = DefaultMaxRetries
+	}
+	if p.opts.RetryBackoff <= 0 {
+		p.opts.RetryBackoff = DefaultRetryBackoff
+	}
+
+	for _, projection := range projections {
+		p.projections[projection.ProjectionName()] = &projectionRunner{projection: projection}
+	}
+
+	return p
+}
+
+// Run catches up projections every poll interval until context is done,
+// errors of projections are logged and they are retried on the next poll
+func (p *Projector) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for name := range p.projections {
+			if _, err := p.CatchUp(ctx, name); err != nil && ctx.Err() == nil {
+				p.opts.Logger.Error(
+					"failed to catch up projection",
+					"projection", name,
+					"error", err.Error(),
+				)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CatchUp handles events after checkpoint of the projection until
+// the end of the store and returns count of handled events
+// Errors: ErrUnknownProjection, unknown
+func (p *Projector) CatchUp(ctx context.Context, name string) (int, error) {
+	runner, ok := p.projections[name]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownProjection, name)
+	}
+
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	return p.catchUp(ctx, runner.projection)
+}
+
+// Rebuild resets read model and checkpoint of the projection and
+// handles all events of the store from zero
+// Errors: ErrUnknownProjection, unknown
+func (p *Projector) Rebuild(ctx context.Context, name string) error {
+	runner, ok := p.projections[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownProjection, name)
+	}
+
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+
+	if err := runner.projection.Reset(ctx); err != nil {
+		return err
+	}
+
+	if err := p.opts.Checkpoints.SaveCheckpoint(ctx, name, 0); err != nil {
+		return err
+	}
+
+	_, err := p.catchUp(ctx, runner.projection)
+	return err
+}
+
+func (p *Projector) catchUp(ctx context.Context, projection Projection) (int, error) {
+	name := projection.ProjectionName()
+
+	position, err := p.opts.Checkpoints.LoadCheckpoint(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+
+	handled := 0
+	for {
+		events, err := p.opts.Events.ReadEvents(ctx, position, p.opts.BatchSize)
+		if err != nil {
+			return handled, err
+		}
+
+		for _, e := range events {
+			if err := p.handle(ctx, projection, e); err != nil {
+				return handled, err
+			}
+
+			// checkpoint is saved after every event, so only the last event
+			// could be handled twice if the checkpoint is failed to save
+			position = e.Position
+			if err := p.opts.Checkpoints.SaveCheckpoint(ctx, name, position); err != nil {
+				return handled, err
+			}
+			handled++
+		}
+
+		if len(events) < p.opts.BatchSize {
+			return handled, nil
+		}
+	}
+}
+
+// handle handles event with retries and saves it to dead letters if all retries failed
+func (p *Projector) handle(ctx context.Context, projection Projection, e RecordedEvent) error {
+	backoff := p.opts.RetryBackoff
+
+	err := projection.Handle(ctx, e)
+	for retry := 0; err != nil && retry < p.opts.MaxRetries; retry++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+		err = projection.Handle(ctx, e)
+	}
+	if err == nil {
+		return nil
+	}
+
+	if p.opts.DeadLetters == nil {
+		return err
+	}
+
+	p.opts.Logger.Error(
+		"event is moved to dead letters",
+		"projection", projection.ProjectionName(),
+		"position", e.Position,
+		"event", e.EventName(),
+		"error", err.Error(),
+	)
+
+	return p.opts.DeadLetters.SaveDeadLetter(ctx, &DeadLetter{
+		Projection: projection.ProjectionName(),
+		Event:      e,
+		Error:      err.Error(),
+		FailedAt:   time.Now(),
+	})
+}
@@ -0,0 +1,152 @@
+package es
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sumProjectionName = "sum"
+
+var errFailedDelta = errors.New("failed delta")
+
+// sumProjection is test projection that sums deltas of counters,
+// handling of the failing delta fails failures times
+type sumProjection struct {
+	sum          int
+	failingDelta int
+	failures     int
+	attempts     int
+}
+
+func (p *sumProjection) ProjectionName() string { return sumProjectionName }
+
+func (p *sumProjection) Handle(_ context.Context, e RecordedEvent) error {
+	payload, ok := e.Payload().(counterAdded)
+	if !ok {
+		return nil
+	}
+
+	if payload.Delta == p.failingDelta {
+		p.attempts++
+		if p.attempts <= p.failures {
+			return errFailedDelta
+		}
+	}
+
+	p.sum += payload.Delta
+	return nil
+}
+
+func (p *sumProjection) Reset(_ context.Context) error {
+	p.sum = 0
+	return nil
+}
+
+// newTestProjector creates projector of the projection with store of counters with deltas
+func newTestProjector(
+	t *testing.T,
+	projection Projection,
+	deltas ...int,
+) (*Projector, *MemoryCheckpointStore) {
+	store := NewMemoryStore()
+	repo := NewAggregateRepository(newCounter, store)
+	for i, delta := range deltas {
+		c := newCounter(string(rune('a' + i)))
+		c.Add(delta)
+		if err := repo.Save(context.Background(), c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkpoints := NewMemoryCheckpointStore()
+	return NewProjector(&ProjectorOpts{
+		Events:       store,
+		Checkpoints:  checkpoints,
+		DeadLetters:  checkpoints,
+		BatchSize:    2,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	}, projection), checkpoints
+}
+
+func TestProjector_CatchUp(t *testing.T) {
+	projection := &sumProjection{}
+	projector, checkpoints := newTestProjector(t, projection, 1, 2, 3)
+
+	handled, err := projector.CatchUp(context.Background(), sumProjectionName)
+	assert.Equal(t, nil, err, "should not be error")
+	assert.Equal(t, 3, handled, "should handle events of all batches")
+	assert.Equal(t, 6, projection.sum, "should be equal sums")
+
+	position, _ := checkpoints.LoadCheckpoint(context.Background(), sumProjectionName)
+	assert.Equal(t, int64(3), position, "should save checkpoint")
+
+	t.Run("check catch up from checkpoint", func(t *testing.T) {
+		handled, err := projector.CatchUp(context.Background(), sumProjectionName)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 0, handled, "should not handle events again")
+	})
+
+	t.Run("check unknown projection", func(t *testing.T) {
+		_, err := projector.CatchUp(context.Background(), "unknown")
+		assert.ErrorIs(t, err, ErrUnknownProjection, "should be unknown projection")
+	})
+}
+
+func TestProjector_Rebuild(t *testing.T) {
+	projection := &sumProjection{}
+	projector, _ := newTestProjector(t, projection, 1, 2, 3)
+
+	if _, err := projector.CatchUp(context.Background(), sumProjectionName); err != nil {
+		t.Fatal(err)
+	}
+	projection.sum = 100
+
+	err := projector.Rebuild(context.Background(), sumProjectionName)
+	assert.Equal(t, nil, err, "should not be error")
+	assert.Equal(t, 6, projection.sum, "should rebuild from zero")
+}
+
+func TestProjector_Retry(t *testing.T) {
+	t.Run("check retry of failed event", func(t *testing.T) {
+		projection := &sumProjection{failingDelta: 2, failures: 2}
+		projector, checkpoints := newTestProjector(t, projection, 1, 2, 3)
+
+		_, err := projector.CatchUp(context.Background(), sumProjectionName)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 6, projection.sum, "should handle event after retries")
+		assert.Equal(t, 0, len(checkpoints.DeadLetters()), "should be no dead letters")
+	})
+
+	t.Run("check dead letter", func(t *testing.T) {
+		projection := &sumProjection{failingDelta: 2, failures: 3}
+		projector, checkpoints := newTestProjector(t, projection, 1, 2, 3)
+
+		_, err := projector.CatchUp(context.Background(), sumProjectionName)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, 4, projection.sum, "should skip failed event")
+
+		letters := checkpoints.DeadLetters()
+		if assert.Equal(t, 1, len(letters), "should be one dead letter") {
+			assert.Equal(t, int64(2), letters[0].Event.Position, "should be failed event")
+			assert.Equal(t, errFailedDelta.Error(), letters[0].Error, "should be error")
+		}
+	})
+
+	t.Run("check stop without dead letters", func(t *testing.T) {
+		projection := &sumProjection{failingDelta: 2, failures: 3}
+		projector, checkpoints := newTestProjector(t, projection, 1, 2, 3)
+		projector.opts.DeadLetters = nil
+
+		handled, err := projector.CatchUp(context.Background(), sumProjectionName)
+		assert.ErrorIs(t, err, errFailedDelta, "should be error of the projection")
+		assert.Equal(t, 1, handled, "should handle events before failed")
+
+		position, _ := checkpoints.LoadCheckpoint(context.Background(), sumProjectionName)
+		assert.Equal(t, int64(1), position, "should keep checkpoint before failed event")
+	})
+}
@@ -0,0 +1,67 @@
+package es
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrUnknownType = errors.New("unknown type")
+
+// Registry represents registry of payload and snapshot types by name,
+// it is used by stores to serialize them
+type Registry struct {
+	mu    sync.RWMutex
+	types map[string]func(data []byte) (any, error)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		types: make(map[string]func(data []byte) (any, error)),
+	}
+}
+
+// Register registers type T by name, deserialized values have type T
+func Register[T any](r *Registry, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.types[name] = func(data []byte) (any, error) {
+		var v T
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+
+		return v, nil
+	}
+}
+
+// Serialize serializes value of the type registered by name
+// Errors: ErrUnknownType, unknown
+func (r *Registry) Serialize(name string, v any) ([]byte, error) {
+	if _, ok := r.deserializer(name); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, name)
+	}
+
+	return json.Marshal(v)
+}
+
+// Deserialize deserializes value of the type registered by name
+// Errors: ErrUnknownType, unknown
+func (r *Registry) Deserialize(name string, data []byte) (any, error) {
+	deserialize, ok := r.deserializer(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, name)
+	}
+
+	return deserialize(data)
+}
+
+func (r *Registry) deserializer(name string) (func(data []byte) (any, error), bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fn, ok := r.types[name]
+	return fn, ok
+}
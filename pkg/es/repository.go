@@ -0,0 +1,61 @@
+package es
+
+import "context"
+
+// AggregateRepository loads and saves aggregates of type T
+type AggregateRepository[T EventSourcedAggregate] struct {
+	newAggregate func(id string) T
+	store        AggregateStore
+}
+
+func NewAggregateRepository[T EventSourcedAggregate](
+	newAggregate func(id string) T,
+	store AggregateStore,
+) AggregateRepository[T] {
+	return AggregateRepository[T]{
+		newAggregate: newAggregate,
+		store:        store,
+	}
+}
+
+// Load creates aggregate and replays its events, aggregate without events has version 0
+// Errors: unknown
+func (r AggregateRepository[T]) Load(ctx context.Context, id string) (T, error) {
+	aggregate := r.newAggregate(id)
+	if err := r.store.Load(ctx, aggregate); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return aggregate, nil
+}
+
+// Save appends pending events to the store, commits them and applies them to the
+// aggregate, the aggregate is not changed if saving fails, so it keeps pending events.
+// Events are already saved if applying fails, so the aggregate should be loaded again
+// Errors: ErrVersionConflict, errors of applying events, unknown
+func (r AggregateRepository[T]) Save(ctx context.Context, aggregate T) error {
+	if len(aggregate.Events()) == 0 {
+		return nil
+	}
+
+	if err := r.store.Save(ctx, aggregate); err != nil {
+		return err
+	}
+
+	version := aggregate.Version()
+	events := aggregate.Events()
+	aggregate.CommitEvents()
+
+	for _, e := range events {
+		if err := aggregate.ApplyEvent(e); err != nil {
+			return err
+		}
+	}
+
+	if saver, ok := r.store.(snapshotSaver); ok {
+		saver.saveSnapshot(ctx, aggregate, version)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package es
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+type (
+	Snapshot interface {
+		SnapshotName() string
+	}
+
+	// Snapshotter is implemented by aggregates which state could be saved as snapshot
+	Snapshotter interface {
+		ApplySnapshot(snapshot Snapshot) error
+		ToSnapshot() Snapshot
+	}
+
+	SnapshotStore interface {
+		// LoadSnapshot returns the last snapshot of the aggregate and its version
+		// Errors: ErrSnapshotNotFound, unknown
+		LoadSnapshot(ctx context.Context, aggregateName, aggregateID string) (Snapshot, int, error)
+
+		// SaveSnapshot saves snapshot of the aggregate with version
+		// Errors: unknown
+		SaveSnapshot(
+			ctx context.Context,
+			aggregateName, aggregateID string,
+			version int,
+			snapshot Snapshot,
+		) error
+	}
+
+	// snapshotSaver is implemented by aggregate stores that save snapshots of
+	// aggregates after saved events are applied to them
+	snapshotSaver interface {
+		// saveSnapshot saves snapshot of the aggregate if needed, prevVersion is
+		// version of the aggregate before the saved events
+		saveSnapshot(ctx context.Context, aggregate EventSourcedAggregate, prevVersion int)
+	}
+
+	// snapshotAggregateStore loads aggregates from the last snapshot and
+	// saves snapshot when version of the aggregate passes multiple of frequency
+	snapshotAggregateStore struct {
+		AggregateStore
+		snapshots SnapshotStore
+		frequency int
+	}
+)
+
+// NewSnapshotAggregateStore wraps aggregate store with snapshots of aggregates
+// that implement Snapshotter, snapshot is saved by aggregate repository every
+// frequency events
+func NewSnapshotAggregateStore(
+	store AggregateStore,
+	snapshots SnapshotStore,
+	frequency int,
+) AggregateStore {
+	return &snapshotAggregateStore{
+		AggregateStore: store,
+		snapshots:      snapshots,
+		frequency:      max(frequency, 1),
+	}
+}
+
+// Load is implementing interface AggregateStore
+func (s *snapshotAggregateStore) Load(ctx context.Context, aggregate EventSourcedAggregate) error {
+	if snapshotter, ok := aggregate.(Snapshotter); ok {
+		snapshot, version, err := s.snapshots.LoadSnapshot(
+			ctx,
+			aggregate.AggregateName(),
+			aggregate.ID(),
+		)
+		switch {
+		case err == nil:
+			if err := snapshotter.ApplySnapshot(snapshot); err != nil {
+				return err
+			}
+			aggregate.setVersion(version)
+		case !errors.Is(err, ErrSnapshotNotFound):
+			return err
+		}
+	}
+
+	return s.AggregateStore.Load(ctx, aggregate)
+}
+
+// saveSnapshot is implementing interface snapshotSaver, snapshot is only an optimization
+// of loading and events are already saved, so errors of saving snapshot are ignored
+func (s *snapshotAggregateStore) saveSnapshot(
+	ctx context.Context,
+	aggregate EventSourcedAggregate,
+	prevVersion int,
+) {
+	snapshotter, ok := aggregate.(Snapshotter)
+	if !ok || aggregate.Version()/s.frequency == prevVersion/s.frequency {
+		return
+	}
+
+	_ = s.snapshots.SaveSnapshot(
+		ctx,
+		aggregate.AggregateName(),
+		aggregate.ID(),
+		aggregate.Version(),
+		snapshotter.ToSnapshot(),
+	)
+}
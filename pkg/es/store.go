@@ -0,0 +1,42 @@
+package es
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sazonovItas/gochat-tcp/pkg/es/event"
+)
+
+var ErrVersionConflict = errors.New("aggregate stream version conflict")
+
+type AggregateStore interface {
+	// Load replays events of the aggregate stream after version of the aggregate
+	// Errors: unknown
+	Load(ctx context.Context, aggregate EventSourcedAggregate) error
+
+	// Save appends pending events to the aggregate stream,
+	// version of the stream should be equal to version of the aggregate
+	// Errors: ErrVersionConflict, unknown
+	Save(ctx context.Context, aggregate EventSourcedAggregate) error
+}
+
+// RecordedEvent represents stored event with its position in the global order of the store
+type RecordedEvent struct {
+	event.Event
+
+	// Position is greater than positions of all events appended before, starts from 1
+	Position int64
+}
+
+// EventReader reads events of all streams in the order they were appended
+type EventReader interface {
+	// ReadEvents returns up to limit events after position
+	// Errors: unknown
+	ReadEvents(ctx context.Context, after int64, limit int) ([]RecordedEvent, error)
+}
+
+// EventStore is aggregate store which events could also be read in the global order
+type EventStore interface {
+	AggregateStore
+	EventReader
+}
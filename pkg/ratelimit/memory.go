@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryLimiter represents in-memory limiter and implements Limiter interface
+type memoryLimiter struct {
+	mu      sync.Mutex
+	limit   Limit
+	buckets map[string]*bucket
+
+	// now is used for getting current time
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryLimiter creates new in-memory limiter, buckets are kept
+// within the process and are not shared between instances of the app
+func NewMemoryLimiter(limit Limit) Limiter {
+	return newMemoryLimiter(limit, time.Now)
+}
+
+func newMemoryLimiter(limit Limit, now func() time.Time) *memoryLimiter {
+	return &memoryLimiter{
+		limit:     limit,
+		buckets:   make(map[string]*bucket),
+		now:       now,
+		lastSweep: now(),
+	}
+}
+
+// Allow is implementing interface Limiter
+func (ml *memoryLimiter) Allow(_ context.Context, key string) (Result, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	now := ml.now()
+	ml.sweep(now)
+
+	b, ok := ml.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(ml.limit.Burst), last: now}
+		ml.buckets[key] = b
+	}
+
+	return b.take(ml.limit, now), nil
+}
+
+// sweep deletes refilled buckets once per limit duration,
+// because they are the same as new ones
+func (ml *memoryLimiter) sweep(now time.Time) {
+	if now.Sub(ml.lastSweep) < ml.limit.Per {
+		return
+	}
+	ml.lastSweep = now
+
+	for key, b := range ml.buckets {
+		if b.full(ml.limit, now) {
+			delete(ml.buckets, key)
+		}
+	}
+}
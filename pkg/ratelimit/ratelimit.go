@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit represents token bucket that refills with Rate tokens every Per
+// duration and holds at most Burst tokens
+type Limit struct {
+	Rate  int
+	Per   time.Duration
+	Burst int
+}
+
+// PerMinute creates limit with rate tokens per minute and given burst
+func PerMinute(rate, burst int) Limit {
+	return Limit{Rate: rate, Per: time.Minute, Burst: burst}
+}
+
+// interval returns time to refill one token
+func (l Limit) interval() time.Duration {
+	if l.Rate <= 0 {
+		return l.Per
+	}
+
+	return l.Per / time.Duration(l.Rate)
+}
+
+// Result represents result of taking token from the bucket
+type Result struct {
+	// Allowed is true if the token was taken
+	Allowed bool
+
+	// Remaining is count of tokens left in the bucket
+	Remaining int
+
+	// RetryAfter is time to wait for the next token if it is not allowed
+	RetryAfter time.Duration
+}
+
+// Limiter is interface for rate limiting by a key
+type Limiter interface {
+	// Allow takes a token from the bucket of a key
+	// Errors: unknown
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// bucket represents state of the token bucket
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket by elapsed time and takes a token from it
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	interval := limit.interval()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+float64(elapsed)/float64(interval))
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return Result{
+			Allowed:    false,
+			Remaining:  0,
+			RetryAfter: time.Duration((1 - b.tokens) * float64(interval)),
+		}
+	}
+
+	b.tokens--
+	return Result{Allowed: true, Remaining: int(b.tokens)}
+}
+
+// full returns true if the bucket is refilled at the time
+func (b *bucket) full(limit Limit, now time.Time) bool {
+	return b.tokens+float64(now.Sub(b.last))/float64(limit.interval()) >= float64(limit.Burst)
+}
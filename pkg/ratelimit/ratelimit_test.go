@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testAddr     = ":6379"
+	testPassword = ""
+	testDB       = 0
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := newMemoryLimiter(Limit{Rate: 1, Per: time.Second, Burst: 2}, func() time.Time {
+		return now
+	})
+
+	t.Run("check burst is allowed", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			res, err := limiter.Allow(context.Background(), "key")
+			assert.Equal(t, nil, err, "should not be error")
+			assert.Equal(t, true, res.Allowed, "should be allowed")
+			assert.Equal(t, 1-i, res.Remaining, "should be equal remaining tokens")
+		}
+	})
+
+	t.Run("check over limit", func(t *testing.T) {
+		res, _ := limiter.Allow(context.Background(), "key")
+		assert.Equal(t, false, res.Allowed, "should not be allowed")
+		assert.Equal(t, time.Second, res.RetryAfter, "should be equal retry after")
+	})
+
+	t.Run("check other key has own bucket", func(t *testing.T) {
+		res, _ := limiter.Allow(context.Background(), "other")
+		assert.Equal(t, true, res.Allowed, "should be allowed")
+	})
+
+	t.Run("check refill", func(t *testing.T) {
+		now = now.Add(time.Millisecond * 500)
+		res, _ := limiter.Allow(context.Background(), "key")
+		assert.Equal(t, false, res.Allowed, "should not be allowed")
+		assert.Equal(t, time.Millisecond*500, res.RetryAfter, "should be equal retry after")
+
+		now = now.Add(time.Millisecond * 500)
+		res, _ = limiter.Allow(context.Background(), "key")
+		assert.Equal(t, true, res.Allowed, "should be allowed")
+	})
+
+	t.Run("check sweep of refilled buckets", func(t *testing.T) {
+		now = now.Add(time.Second * 5)
+		_, _ = limiter.Allow(context.Background(), "key")
+		assert.Equal(t, 1, len(limiter.buckets), "should be only used bucket")
+	})
+}
+
+func TestRedisLimiter(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     testAddr,
+		Password: testPassword,
+		DB:       testDB,
+	})
+
+	if client.Ping(context.Background()).Err() != nil {
+		return
+	}
+
+	limiter := NewRedisLimiter(&RedisOpts{
+		Client:    client,
+		KeyPrefix: "test_rate_limit",
+		Limit:     PerMinute(1, 2),
+	})
+	key := uuid.New().String()
+
+	t.Run("check burst is allowed", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			res, err := limiter.Allow(context.Background(), key)
+			assert.Equal(t, nil, err, "should not be error")
+			assert.Equal(t, true, res.Allowed, "should be allowed")
+		}
+	})
+
+	t.Run("check over limit", func(t *testing.T) {
+		res, err := limiter.Allow(context.Background(), key)
+		assert.Equal(t, nil, err, "should not be error")
+		assert.Equal(t, false, res.Allowed, "should not be allowed")
+		assert.Greater(t, res.RetryAfter, time.Duration(0), "should be retry after")
+	})
+}
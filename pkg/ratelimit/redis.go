@@ -0,0 +1,92 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript refills and takes token from the bucket atomically,
+// time of the redis server is used to be the same for all instances of the app
+//
+// KEYS[1] - key of the bucket
+// ARGV[1] - tokens per millisecond
+// ARGV[2] - burst
+//
+// Returns {allowed, remaining, retry after in milliseconds}
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+if now > ts then
+	tokens = math.min(burst, tokens + (now - ts) * rate)
+end
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate))
+
+return {allowed, math.floor(tokens), retry}
+`)
+
+// RedisOpts represents options for redis based limiter
+type RedisOpts struct {
+	Client *redis.Client
+
+	// key prefix using for avoid collision with other limiters
+	KeyPrefix string
+	Limit     Limit
+}
+
+// redisLimiter represents redis based limiter and implements Limiter interface
+type redisLimiter struct {
+	client *redis.Client
+
+	keyPrefix string
+	limit     Limit
+}
+
+// NewRedisLimiter creates new redis based limiter, buckets are shared
+// between instances of the app and expire after they are refilled
+func NewRedisLimiter(opts *RedisOpts) Limiter {
+	return &redisLimiter{
+		client:    opts.Client,
+		keyPrefix: opts.KeyPrefix,
+		limit:     opts.Limit,
+	}
+}
+
+// Allow is implementing interface Limiter
+func (rl *redisLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	if rl.keyPrefix != "" {
+		key = rl.keyPrefix + ":" + key
+	}
+
+	rate := float64(time.Millisecond) / float64(rl.limit.interval())
+	res, err := tokenBucketScript.Run(ctx, rl.client, []string{key}, rate, rl.limit.Burst).
+		Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Allowed:    res[0] == 1,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Millisecond,
+	}, nil
+}